@@ -5,19 +5,30 @@ import (
 	"log"
 
 	"github.com/Teneieiza/go-spinsolf-test/config"
+	"github.com/Teneieiza/go-spinsolf-test/controllers"
 	"github.com/Teneieiza/go-spinsolf-test/middleware"
+	"github.com/Teneieiza/go-spinsolf-test/repositories"
+	"github.com/Teneieiza/go-spinsolf-test/services"
 	"github.com/Teneieiza/go-spinsolf-test/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
 type ApplicationType struct {
-	fiber  *fiber.App
-	config *config.ConfigType
+	fiber   *fiber.App
+	config  *config.ConfigType
+	jobs    *services.ImportJobService
+	sources *services.ImportSourceService
+	purge   *services.StationPurgeService
 }
 
-func NewApplication(cfg *config.ConfigType) *ApplicationType {
+// NewApplication สร้าง fiber app และประกอบ service, controller เข้ากับ repository ที่ส่งเข้ามา
+func NewApplication(cfg *config.ConfigType, stationRepo repositories.StationRepository, sourceRepo repositories.ImportSourceRepository, changesetRepo repositories.ImportChangesetRepository, historyRepo repositories.StationHistoryRepository, importKey utils.StationKey, profiles map[string]*utils.MappingProfile) *ApplicationType {
 	//สร้าง fiber app พร้อมตั้งค่า error handler
+	//รับไฟล์ upload ได้ใหญ่สุด IMPORT_MAX_UPLOAD_MB และอ่าน body แบบ stream
+	//ไฟล์ใน multipart ที่ใหญ่จะถูกเขียนลง disk แทนการเก็บทั้งก้อนไว้ใน memory
 	app := fiber.New(fiber.Config{
+		BodyLimit:         cfg.IMPORT_MAX_UPLOAD * 1024 * 1024,
+		StreamRequestBody: true,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 			message := "Internal Server Error"
@@ -32,9 +43,13 @@ func NewApplication(cfg *config.ConfigType) *ApplicationType {
 		},
 	})
 
+	// worker pool สำหรับ import แบบ async
+	jobs := services.NewImportJobService(cfg.IMPORT_WORKERS, cfg.IMPORT_QUEUE_SIZE, cfg.IMPORT_JOB_TIMEOUT)
+
 	application := &ApplicationType{
 		fiber:  app,
 		config: cfg,
+		jobs:   jobs,
 	}
 
 	// Setup CORS middleware
@@ -43,8 +58,45 @@ func NewApplication(cfg *config.ConfigType) *ApplicationType {
 	// Setup API Key middleware สำหรับทุก route /api
 	app.Use("/api", middleware.APIKeyMiddleware)
 
+	// สร้าง service และ controller โดยส่ง repository ต่อเข้าไป
+	// ประวัติของสถานี ทุก service ที่เขียนข้อมูลสถานีใช้ตัวเดียวกัน
+	stationHistory := services.NewStationHistoryService(historyRepo, stationRepo)
+	stationService := services.NewStationService(stationRepo, stationHistory)
+
+	// ค่าที่ใช้ค้นหาแบบไม่เข้มงวดของสถานีที่เขียนก่อนมี field เหล่านี้
+	if n, err := stationService.RefreshSearchKeys(); err != nil {
+		log.Printf("failed to refresh station search keys: %v", err)
+	} else if n > 0 {
+		log.Printf("refreshed search keys of %d stations", n)
+	}
+
+	// ลบจริงสถานีที่ถูกลบนานเกิน STATION_PURGE_AFTER
+	application.purge = services.NewStationPurgeService(stationRepo, stationHistory, cfg.STATION_PURGE_AFTER, cfg.STATION_PURGE_INTERVAL, cfg.IMPORT_BATCH_SIZE)
+	if cfg.STATION_PURGE_AFTER > 0 {
+		application.purge.Start()
+	}
+	fetcher := services.NewURLFetcher(cfg.IMPORT_URL_SCHEMES, cfg.IMPORT_URL_ALLOWED_HOSTS, cfg.IMPORT_URL_ALLOW_PRIVATE,
+		int64(cfg.IMPORT_URL_MAX_SIZE)*1024*1024, cfg.IMPORT_URL_RETRIES, cfg.IMPORT_URL_TIMEOUT)
+	importService := services.NewImportStationService(stationRepo, changesetRepo, stationHistory, importKey, cfg.IMPORT_BATCH_SIZE, cfg.GTFS_ID_STRATEGY, profiles, fetcher,
+		float64(cfg.IMPORT_SYNC_MAX_REMOVE_PERCENT))
+
+	historyService := services.NewImportHistoryService(changesetRepo, stationRepo, stationHistory, importKey, cfg.IMPORT_BATCH_SIZE)
+
+	// import source ที่ scheduler ดึงและ import ให้ตาม cron ของแต่ละ source
+	sourceService := services.NewImportSourceService(sourceRepo, importService, jobs, cfg.IMPORT_SCHEDULER_TICK)
+	application.sources = sourceService
+	if cfg.IMPORT_SCHEDULER_ENABLED {
+		sourceService.Start()
+	}
+
 	// Setup API routes
-	RegisterRoutes(app)
+	RegisterRoutes(app, &Controllers{
+		Station: controllers.NewStationController(stationService),
+		Import:  controllers.NewImportStationController(importService, jobs),
+		Jobs:    controllers.NewImportJobController(jobs),
+		Sources: controllers.NewImportSourceController(sourceService),
+		History: controllers.NewImportHistoryController(historyService),
+	})
 
 	return application
 }
@@ -57,5 +109,13 @@ func (app *ApplicationType) Start() error {
 
 func (app *ApplicationType) Shutdown() error {
 	log.Println("Gracefully shutting down Fiber server...")
-	return app.fiber.Shutdown()
+	err := app.fiber.Shutdown()
+
+	// หยุด scheduler ก่อน จะได้ไม่มี job ใหม่เข้าคิวระหว่างปิด
+	app.sources.Shutdown()
+	app.purge.Shutdown()
+
+	// ยกเลิก import job ที่ยังรันค้างอยู่
+	app.jobs.Shutdown()
+	return err
 }
\ No newline at end of file
@@ -7,14 +7,55 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func RegisterRoutes(app *fiber.App) {
+// Controllers รวม controller ทั้งหมดที่ใช้ผูกกับ route
+type Controllers struct {
+	Station *controllers.StationController
+	Import  *controllers.ImportStationController
+	Jobs    *controllers.ImportJobController
+	Sources *controllers.ImportSourceController
+	History *controllers.ImportHistoryController
+}
+
+func RegisterRoutes(app *fiber.App, ctl *Controllers) {
 	api := app.Group("/api")
 
 	// Stations
-	api.Get("/stations/nearby", controllers.GetNearbyStations)
-	api.Get("/stations/nearbypage", controllers.GetNearbyStationsPage)
-	api.Post("/stations/import/url", controllers.ImportUrlStations)
-	api.Post("/stations/import/file", controllers.ImportFileStations)
+	api.Get("/stations/nearby", ctl.Station.GetNearbyStations)
+	api.Get("/stations/nearbypage", ctl.Station.GetNearbyStationsPage)
+	api.Get("/stations/search", ctl.Station.SearchStations)
+	api.Post("/stations/import/url", ctl.Import.ImportUrlStations)
+	api.Post("/stations/import/file", ctl.Import.ImportFileStations)
+	api.Get("/stations/export/gtfs", ctl.Station.ExportGTFSStops)
+	api.Post("/stations", ctl.Station.CreateStation)
+	api.Get("/stations/:station_code", ctl.Station.GetStation)
+	api.Put("/stations/:station_code", ctl.Station.UpdateStation)
+	api.Patch("/stations/:station_code", ctl.Station.PatchStation)
+	api.Delete("/stations/:station_code", ctl.Station.DeleteStation)
+	api.Post("/stations/:station_code/restore", ctl.Station.RestoreStation)
+	api.Get("/stations/:station_code/history", ctl.Station.GetStationHistory)
+
+	// Import mapping profiles
+	api.Get("/import-profiles", ctl.Import.ListProfiles)
+
+	// Import jobs
+	api.Get("/import-jobs", ctl.Jobs.ListImportJobs)
+	api.Get("/import-jobs/:id", ctl.Jobs.GetImportJob)
+	api.Post("/import-jobs/:id/cancel", ctl.Jobs.CancelImportJob)
+	api.Post("/import-jobs/:id/rollback", ctl.History.RollbackImportJob)
+
+	// Import history (changeset ของแต่ละการ import)
+	api.Get("/import-changesets", ctl.History.ListChangesets)
+	api.Get("/import-changesets/:id", ctl.History.GetChangeset)
+	api.Get("/import-changesets/:id/changes", ctl.History.ListChangesetChanges)
+
+	// Import sources (import ตามตารางเวลา)
+	api.Get("/import-sources", ctl.Sources.ListImportSources)
+	api.Post("/import-sources", ctl.Sources.CreateImportSource)
+	api.Get("/import-sources/:id", ctl.Sources.GetImportSource)
+	api.Put("/import-sources/:id", ctl.Sources.UpdateImportSource)
+	api.Delete("/import-sources/:id", ctl.Sources.DeleteImportSource)
+	api.Post("/import-sources/:id/run", ctl.Sources.RunImportSource)
+	api.Get("/import-sources/:id/runs", ctl.Sources.ListImportSourceRuns)
 
 	// health check
 	api.Get("/health", func(c *fiber.Ctx) error {
@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -13,6 +16,45 @@ type ConfigType struct {
 	DB_NAME         string
 	COLLECTION_NAME string
 	API_KEY         string
+	STORAGE_DRIVER  string
+
+	// import
+	IMPORT_KEY         string
+	IMPORT_BATCH_SIZE  int
+	IMPORT_MAX_UPLOAD  int // MB
+	IMPORT_WORKERS     int
+	IMPORT_QUEUE_SIZE  int
+	IMPORT_JOB_TIMEOUT time.Duration
+	GTFS_ID_STRATEGY   string
+	// % สูงสุดของสถานีที่ active ที่ mode=sync ปิดได้ในครั้งเดียว
+	IMPORT_SYNC_MAX_REMOVE_PERCENT int
+	// ไฟล์ JSON ของ mapping profile (ว่างคือไม่มี profile)
+	MAPPING_PROFILES_FILE string
+
+	// import ผ่าน URL
+	IMPORT_URL_SCHEMES       []string
+	IMPORT_URL_ALLOWED_HOSTS []string // ว่างคือยอมทุก host ที่ไม่ใช่ IP ภายใน
+	IMPORT_URL_ALLOW_PRIVATE bool
+	IMPORT_URL_MAX_SIZE      int // MB
+	IMPORT_URL_RETRIES       int
+	IMPORT_URL_TIMEOUT       time.Duration
+
+	// import source ที่รันตามตารางเวลา
+	IMPORT_SCHEDULER_ENABLED      bool
+	IMPORT_SCHEDULER_TICK         time.Duration
+	IMPORT_SOURCES_COLLECTION     string
+	IMPORT_SOURCE_RUNS_COLLECTION string
+
+	// ประวัติการ import
+	IMPORT_CHANGESETS_COLLECTION string
+	IMPORT_CHANGES_COLLECTION    string
+
+	// ประวัติการเปลี่ยนแปลงของแต่ละสถานี
+	STATION_HISTORY_COLLECTION string
+
+	// สถานีที่ถูกลบ (soft delete) จะถูกลบจริงเมื่อเกิน STATION_PURGE_AFTER (ค่าเริ่มต้น 0 คือไม่ลบ ต้องเปิดเอง)
+	STATION_PURGE_AFTER    time.Duration
+	STATION_PURGE_INTERVAL time.Duration
 }
 
 //สร้าง LoadConfig เพื่อโหลดค่าต่างๆจาก .env
@@ -27,6 +69,39 @@ func LoadConfig() *ConfigType {
 		DB_NAME:         getEnv("DB_NAME", "location"),
 		COLLECTION_NAME: getEnv("COLLECTION_NAME", "station"),
 		API_KEY:         getEnv("API_KEY", "-"),
+		STORAGE_DRIVER:  getEnv("STORAGE_DRIVER", "mongo"),
+
+		IMPORT_KEY:         getEnv("IMPORT_KEY", "station_code"),
+		IMPORT_BATCH_SIZE:  getEnvInt("IMPORT_BATCH_SIZE", 1000),
+		IMPORT_MAX_UPLOAD:  getEnvInt("IMPORT_MAX_UPLOAD_MB", 512),
+		IMPORT_WORKERS:     getEnvInt("IMPORT_WORKERS", 2),
+		IMPORT_QUEUE_SIZE:  getEnvInt("IMPORT_QUEUE_SIZE", 100),
+		IMPORT_JOB_TIMEOUT: getEnvDuration("IMPORT_JOB_TIMEOUT", 30*time.Minute),
+		GTFS_ID_STRATEGY:   getEnv("GTFS_ID_STRATEGY", "numeric"),
+
+		IMPORT_SYNC_MAX_REMOVE_PERCENT: getEnvInt("IMPORT_SYNC_MAX_REMOVE_PERCENT", 10),
+
+		MAPPING_PROFILES_FILE: getEnv("MAPPING_PROFILES_FILE", ""),
+
+		IMPORT_URL_SCHEMES:       getEnvList("IMPORT_URL_SCHEMES", "https,http"),
+		IMPORT_URL_ALLOWED_HOSTS: getEnvList("IMPORT_URL_ALLOWED_HOSTS", ""),
+		IMPORT_URL_ALLOW_PRIVATE: getEnvBool("IMPORT_URL_ALLOW_PRIVATE", false),
+		IMPORT_URL_MAX_SIZE:      getEnvInt("IMPORT_URL_MAX_MB", 100),
+		IMPORT_URL_RETRIES:       getEnvInt("IMPORT_URL_RETRIES", 3),
+		IMPORT_URL_TIMEOUT:       getEnvDuration("IMPORT_URL_TIMEOUT", 2*time.Minute),
+
+		IMPORT_SCHEDULER_ENABLED:      getEnvBool("IMPORT_SCHEDULER_ENABLED", true),
+		IMPORT_SCHEDULER_TICK:         getEnvDuration("IMPORT_SCHEDULER_TICK", 30*time.Second),
+		IMPORT_SOURCES_COLLECTION:     getEnv("IMPORT_SOURCES_COLLECTION", "import_sources"),
+		IMPORT_SOURCE_RUNS_COLLECTION: getEnv("IMPORT_SOURCE_RUNS_COLLECTION", "import_source_runs"),
+
+		IMPORT_CHANGESETS_COLLECTION: getEnv("IMPORT_CHANGESETS_COLLECTION", "import_changesets"),
+		IMPORT_CHANGES_COLLECTION:    getEnv("IMPORT_CHANGES_COLLECTION", "import_changes"),
+
+		STATION_HISTORY_COLLECTION: getEnv("STATION_HISTORY_COLLECTION", "station_history"),
+
+		STATION_PURGE_AFTER:    getEnvDuration("STATION_PURGE_AFTER", 0),
+		STATION_PURGE_INTERVAL: getEnvDuration("STATION_PURGE_INTERVAL", time.Hour),
 	}
 }
 
@@ -36,4 +111,48 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+//getEnvInt เหมือน getEnv แต่แปลงเป็น int ถ้าแปลงไม่ได้จะใช้ defaultValue
+func getEnvInt(key string, defaultValue int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		log.Printf("invalid %s=%q, using default %d", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+//getEnvBool เหมือน getEnv แต่แปลงเป็น bool เช่น true, false, 1, 0
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+		log.Printf("invalid %s=%q, using default %t", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+//getEnvList เหมือน getEnv แต่แยกค่าด้วย , และตัดช่องว่าง
+func getEnvList(key, defaultValue string) []string {
+	var list []string
+	for _, v := range strings.Split(getEnv(key, defaultValue), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+//getEnvDuration เหมือน getEnv แต่แปลงเป็น time.Duration เช่น 30m, 1h
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		log.Printf("invalid %s=%q, using default %s", key, value, defaultValue)
+	}
+	return defaultValue
+}
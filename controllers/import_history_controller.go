@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Teneieiza/go-spinsolf-test/repositories"
+	"github.com/Teneieiza/go-spinsolf-test/services"
+	"github.com/Teneieiza/go-spinsolf-test/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ImportHistoryController รวม handler สำหรับดูประวัติการ import และ rollback
+type ImportHistoryController struct {
+	service *services.ImportHistoryService
+}
+
+func NewImportHistoryController(service *services.ImportHistoryService) *ImportHistoryController {
+	return &ImportHistoryController{service: service}
+}
+
+// ListChangesets ดึง changeset ล่าสุด (limit= ค่าเริ่มต้น 20)
+func (ctl *ImportHistoryController) ListChangesets(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 20)
+	if limit <= 0 {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "limit must be a positive integer")
+	}
+
+	list, err := ctl.service.List(limit)
+	if err != nil {
+		return historyErrorResponse(c, err)
+	}
+	return c.JSON(list)
+}
+
+// GetChangeset ดึง changeset ตาม id
+func (ctl *ImportHistoryController) GetChangeset(c *fiber.Ctx) error {
+	cs, err := ctl.service.Get(c.Params("id"))
+	if err != nil {
+		return historyErrorResponse(c, err)
+	}
+	return c.JSON(cs)
+}
+
+// ListChangesetChanges ดึงค่าก่อน/หลังของสถานีที่เปลี่ยนใน changeset (page= และ limit= ค่าเริ่มต้น 1 และ 100)
+func (ctl *ImportHistoryController) ListChangesetChanges(c *fiber.Ctx) error {
+	page, limit := c.QueryInt("page", 1), c.QueryInt("limit", 100)
+	if page <= 0 || limit <= 0 || limit > 1000 {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "page must be positive and limit must be between 1 and 1000")
+	}
+
+	changes, err := ctl.service.Changes(c.Params("id"), page, limit)
+	if err != nil {
+		return historyErrorResponse(c, err)
+	}
+	return c.JSON(changes)
+}
+
+// RollbackImportJob คืนสถานีที่ import job เปลี่ยนกลับไปเป็นค่าก่อน import
+// ถ้ามีสถานีที่ถูกแก้ไขหลัง import จะตอบ 409 พร้อมรายการ ใส่ force=true เพื่อ rollback ทับ
+func (ctl *ImportHistoryController) RollbackImportJob(c *fiber.Ctx) error {
+	res, err := ctl.service.Rollback(c.Params("id"), requestActor(c), c.QueryBool("force", false))
+	if errors.Is(err, services.ErrRollbackConflict) {
+		return c.Status(http.StatusConflict).JSON(res)
+	}
+	if err != nil {
+		return historyErrorResponse(c, err)
+	}
+	return c.JSON(res)
+}
+
+// requestActor คนที่สั่งงานจาก header X-Actor ถ้าไม่ใส่ถือว่าเป็น api (มีแค่ API key)
+// ต้อง clone เพราะ string จาก fiber ชี้ไปที่ buffer ของ request ซึ่งถูกใช้ซ้ำหลังตอบกลับ แต่ actor ถูกใช้ต่อใน job
+func requestActor(c *fiber.Ctx) string {
+	if actor := strings.TrimSpace(c.Get("X-Actor")); actor != "" {
+		return strings.Clone(actor)
+	}
+	return "api"
+}
+
+// historyErrorResponse แปลง error ของ import history service เป็น status code ที่เหมาะสม
+func historyErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, repositories.ErrChangesetNotFound):
+		return utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrChangesetRolledBack), errors.Is(err, services.ErrChangesetRunning):
+		return utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	default:
+		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"github.com/Teneieiza/go-spinsolf-test/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ImportJobController รวม handler สำหรับดูสถานะและยกเลิก import job
+type ImportJobController struct {
+	jobs *services.ImportJobService
+}
+
+func NewImportJobController(jobs *services.ImportJobService) *ImportJobController {
+	return &ImportJobController{jobs: jobs}
+}
+
+// ListImportJobs ดึงสถานะของทุก job ที่ยังเก็บไว้
+func (ctl *ImportJobController) ListImportJobs(c *fiber.Ctx) error {
+	return c.JSON(ctl.jobs.List())
+}
+
+// GetImportJob ดึงสถานะ ความคืบหน้า และผลลัพธ์ของ job
+func (ctl *ImportJobController) GetImportJob(c *fiber.Ctx) error {
+	job, err := ctl.jobs.Get(c.Params("id"))
+	if err != nil {
+		return jobErrorResponse(c, err)
+	}
+	return c.JSON(job)
+}
+
+// CancelImportJob ยกเลิก job ที่ยังรอคิวหรือกำลังรันอยู่
+func (ctl *ImportJobController) CancelImportJob(c *fiber.Ctx) error {
+	job, err := ctl.jobs.Cancel(c.Params("id"))
+	if err != nil {
+		return jobErrorResponse(c, err)
+	}
+	return c.JSON(job)
+}
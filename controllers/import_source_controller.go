@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Teneieiza/go-spinsolf-test/dto"
+	"github.com/Teneieiza/go-spinsolf-test/repositories"
+	"github.com/Teneieiza/go-spinsolf-test/services"
+	"github.com/Teneieiza/go-spinsolf-test/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ImportSourceController รวม handler สำหรับจัดการ import source ที่รันตามตารางเวลา
+type ImportSourceController struct {
+	service *services.ImportSourceService
+}
+
+func NewImportSourceController(service *services.ImportSourceService) *ImportSourceController {
+	return &ImportSourceController{service: service}
+}
+
+// ListImportSources ดึง source ทั้งหมด
+func (ctl *ImportSourceController) ListImportSources(c *fiber.Ctx) error {
+	list, err := ctl.service.List()
+	if err != nil {
+		return sourceErrorResponse(c, err)
+	}
+	return c.JSON(list)
+}
+
+// GetImportSource ดึง source ตาม id
+func (ctl *ImportSourceController) GetImportSource(c *fiber.Ctx) error {
+	src, err := ctl.service.Get(c.Params("id"))
+	if err != nil {
+		return sourceErrorResponse(c, err)
+	}
+	return c.JSON(src)
+}
+
+// CreateImportSource สร้าง source ใหม่
+func (ctl *ImportSourceController) CreateImportSource(c *fiber.Ctx) error {
+	var req dto.ImportSourceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "invalid request body")
+	}
+
+	src, err := ctl.service.Create(req)
+	if err != nil {
+		return sourceErrorResponse(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(src)
+}
+
+// UpdateImportSource แก้ไข source ทั้งก้อน (ถ้าไม่ส่ง auth มาจะใช้ค่าเดิม)
+func (ctl *ImportSourceController) UpdateImportSource(c *fiber.Ctx) error {
+	var req dto.ImportSourceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "invalid request body")
+	}
+
+	src, err := ctl.service.Update(c.Params("id"), req)
+	if err != nil {
+		return sourceErrorResponse(c, err)
+	}
+	return c.JSON(src)
+}
+
+// DeleteImportSource ลบ source และประวัติการรัน
+func (ctl *ImportSourceController) DeleteImportSource(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if err := ctl.service.Delete(id); err != nil {
+		return sourceErrorResponse(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  http.StatusOK,
+		"message": fmt.Sprintf("import source %s deleted", id),
+	})
+}
+
+// RunImportSource สั่งรัน source ทันที ตอบ job id กลับไป
+func (ctl *ImportSourceController) RunImportSource(c *fiber.Ctx) error {
+	job, err := ctl.service.RunNow(c.Params("id"), requestActor(c))
+	if err != nil {
+		return sourceErrorResponse(c, err)
+	}
+	return c.Status(http.StatusAccepted).JSON(job)
+}
+
+// ListImportSourceRuns ดึงประวัติการรันของ source (limit= ค่าเริ่มต้น 20)
+func (ctl *ImportSourceController) ListImportSourceRuns(c *fiber.Ctx) error {
+	limit := 20
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return utils.ErrorResponse(c, http.StatusBadRequest, "limit must be a positive integer")
+		}
+		limit = n
+	}
+
+	runs, err := ctl.service.Runs(c.Params("id"), limit)
+	if err != nil {
+		return sourceErrorResponse(c, err)
+	}
+	return c.JSON(runs)
+}
+
+// sourceErrorResponse แปลง error ของ import source service เป็น status code ที่เหมาะสม
+func sourceErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, repositories.ErrImportSourceNotFound):
+		return utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidImportSource):
+		return utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrImportSourceRunning):
+		return utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	default:
+		return jobErrorResponse(c, err)
+	}
+}
@@ -1,78 +1,180 @@
 package controllers
 
 import (
-	"fmt"
-	"io"
+	"context"
+	"errors"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/Teneieiza/go-spinsolf-test/dto"
+	"github.com/Teneieiza/go-spinsolf-test/parsers"
 	"github.com/Teneieiza/go-spinsolf-test/services"
 	"github.com/Teneieiza/go-spinsolf-test/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
+// ImportStationController รวม handler ของการ import ข้อมูลสถานี
+// การ import ทุกแบบจะถูกส่งเข้า job แล้วตอบ job id กลับไปทันที
+type ImportStationController struct {
+	service *services.ImportStationService
+	jobs    *services.ImportJobService
+}
+
+func NewImportStationController(service *services.ImportStationService, jobs *services.ImportJobService) *ImportStationController {
+	return &ImportStationController{service: service, jobs: jobs}
+}
+
 // Import ข้อมูลผ่านไฟล์
-func ImportFileStations(c *fiber.Ctx) error {
+func (ctl *ImportStationController) ImportFileStations(c *fiber.Ctx) error {
 	//ดึงไฟล์จาก from-data
 	//ถ้าไม่มีไฟล์ให้ส่งกลับ 400 Bad Request
 	file, err := c.FormFile("file")
 	if err != nil {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "file is required")
 	}
-	//เปิดไฟล์เพื่ออ่านข้อมูล
-	f, err := file.Open()
+	//บันทึกไฟล์ไว้ที่ไฟล์ชั่วคราวก่อน เพราะ job จะรันหลังจาก request นี้จบไปแล้ว
+	//job จะอ่านไฟล์แบบ stream และลบไฟล์ทิ้งเมื่อจบ
+	//ถ้ามี error ให้ส่งกลับ 500 Internal Server Error
+	tmp, err := os.CreateTemp("", "station-upload-*")
 	if err != nil {
 		return utils.ErrorResponse(c, fiber.StatusInternalServerError, err.Error())
 	}
-	defer f.Close()
-
-	//อ่านข้อมูลทั้งหมดจากไฟล์
-	//แล้วส่งข้อมูลไปที่ service ImportFileStations
-	//ถ้ามี error ให้ส่งกลับ 500 Internal Server Error
-	data, err := io.ReadAll(f)
-	if err != nil {
+	path := tmp.Name()
+	tmp.Close()
+	if err := c.SaveFile(file, path); err != nil {
+		os.Remove(path)
 		return utils.ErrorResponse(c, fiber.StatusInternalServerError, err.Error())
 	}
 
-	inserted, updated, corrupted,totalImported, err := services.ImportFileStations(file.Filename, data)
+	opts, err := ctl.importOptions(c)
 	if err != nil {
-		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		os.Remove(path)
+		return utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 	}
 
-	message := fmt.Sprintf("Imported %d new, updated %d existing, %d records corrupted", inserted, updated, corrupted)
+	//ส่งงาน import เข้า job แล้วตอบ job id กลับไปทันที
+	filename, contentType := file.Filename, file.Header.Get("Content-Type")
+	job, err := ctl.jobs.Submit("file", filename, func(ctx context.Context, progress *services.ImportProgress) (*dto.ImportStationResponse, error) {
+		return ctl.service.ImportFileStations(ctx, filename, contentType, path, opts, progress)
+	}, func() { os.Remove(path) })
+	if err != nil {
+		return jobErrorResponse(c, err)
+	}
 
-	//ส่งกลับจำนวนข้อมูลที่ import ได้ พร้อม message ในรูปแบบ JSON
-	return c.JSON(dto.ImportStationResponse{
-		Status:  200,
-		Message: message,
-		Count:   inserted + updated,
-		TotalImport:  totalImported,
-	})
+	return c.Status(http.StatusAccepted).JSON(job)
 }
 
 // Import ข้อมูลผ่าน URL
-func ImportUrlStations(c *fiber.Ctx) error {
-	//ดึงค่า url จาก query param
+func (ctl *ImportStationController) ImportUrlStations(c *fiber.Ctx) error {
+	//ดึงค่า url จาก query param หรือจาก body (JSON) ซึ่งใส่ auth ของ feed ได้
 	//ถ้าไม่มี url ให้ส่งกลับ 400 Bad Request
-	apiURL := c.Query("url")
+	var body dto.ImportURLRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return utils.ErrorResponse(c, http.StatusBadRequest, "invalid request body")
+		}
+	}
+	//clone เพราะ string จาก fiber ชี้ไปที่ buffer ของ request แต่ค่านี้ถูกใช้ต่อใน job
+	apiURL := strings.Clone(c.Query("url", body.URL))
 	if apiURL == "" {
 		return utils.ErrorResponse(c, http.StatusBadRequest, "url is required")
 	}
+	//ตรวจ allowlist ก่อนส่งเข้า job จะได้ตอบ 400 ทันที
+	if err := ctl.service.CheckURL(apiURL); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
 
-	//เรียกใช้งาน service ImportUrlStations แล้วส่ง url เข้าไป
-	//ถ้ามี error ให้ส่งกลับ 500 Internal Server Error
-	inserted, updated, corrupted, totalImported, err := services.ImportUrlStations(apiURL)
+	opts, err := ctl.importOptions(c)
 	if err != nil {
-		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 	}
 
-	message := fmt.Sprintf("Imported %d new, updated %d existing, %d records corrupted", inserted, updated, corrupted)
+	//ส่งงาน import เข้า job แล้วตอบ job id กลับไปทันที
+	//ไม่แสดงรหัสผ่านที่อยู่ใน URL ในรายการ job
+	auth, source := body.Auth, apiURL
+	if u, err := url.Parse(apiURL); err == nil {
+		source = u.Redacted()
+	}
+	job, err := ctl.jobs.Submit("url", source, func(ctx context.Context, progress *services.ImportProgress) (*dto.ImportStationResponse, error) {
+		return ctl.service.ImportUrlStations(ctx, apiURL, auth, opts, progress)
+	}, nil)
+	if err != nil {
+		return jobErrorResponse(c, err)
+	}
 
-	//ส่งกลับจำนวนข้อมูลที่ import ได้ พร้อม message ในรูปแบบ JSON
-	return c.JSON(dto.ImportStationResponse{
-		Status:  200,
-		Message: message,
-		Count:   inserted + updated,
-		TotalImport:  totalImported,
-	})
+	return c.Status(http.StatusAccepted).JSON(job)
+}
+
+// importOptions อ่านตัวเลือกของการ import จาก query param
+// dry_run=true จะเปรียบเทียบกับข้อมูลเดิมอย่างเดียวไม่เขียนลง database
+// strict=true ถ้ามีแถวไหน validate ไม่ผ่านจะไม่ import เลยทั้งไฟล์
+// profile=ชื่อ ใช้ mapping profile แปลงชื่อ column และค่าก่อน import
+// sheet=ชื่อหรือลำดับ เลือก sheet ของ xlsx/xls/ods, all_sheets=true import ทุก sheet
+// encoding= และ delimiter= กำหนด encoding และตัวคั่นของ csv (ไม่ใส่คือเดาจากไฟล์)
+// mode=sync ปิดสถานีที่ไม่มีในไฟล์ max_remove_percent= กำหนด % สูงสุดที่ปิดได้ (ไม่ใส่คือใช้ค่าจาก config)
+func (ctl *ImportStationController) importOptions(c *fiber.Ctx) (services.ImportOptions, error) {
+	opts := services.ImportOptions{
+		DryRun:    c.QueryBool("dry_run", false),
+		Strict:    c.QueryBool("strict", false),
+		Sheet:     strings.Clone(c.Query("sheet")),
+		AllSheets: c.QueryBool("all_sheets", false),
+		Actor:     requestActor(c),
+	}
+	if opts.Sheet != "" && opts.AllSheets {
+		return opts, errors.New("use either sheet or all_sheets, not both")
+	}
+	enc, err := parsers.ParseEncoding(c.Query("encoding"))
+	if err != nil {
+		return opts, err
+	}
+	if enc != parsers.EncodingAuto {
+		opts.Encoding = enc
+	}
+	if opts.Delimiter, err = parsers.ParseDelimiter(c.Query("delimiter")); err != nil {
+		return opts, err
+	}
+	switch c.Query("mode", services.ImportModeUpsert) {
+	case services.ImportModeUpsert:
+	case services.ImportModeSync:
+		opts.Sync = true
+	default:
+		return opts, errors.New("mode must be upsert or sync")
+	}
+	if s := c.Query("max_remove_percent"); s != "" {
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil || n <= 0 || n > 100 {
+			return opts, errors.New("max_remove_percent must be a number between 0 and 100")
+		}
+		opts.MaxRemovePercent = n
+	}
+	if name := c.Query("profile"); name != "" {
+		profile, err := ctl.service.Profile(name)
+		if err != nil {
+			return opts, err
+		}
+		opts.Profile = profile
+	}
+	return opts, nil
+}
+
+// ListProfiles ดู mapping profile ทั้งหมดที่ใช้กับ profile= ได้
+func (ctl *ImportStationController) ListProfiles(c *fiber.Ctx) error {
+	return c.JSON(ctl.service.Profiles())
+}
+
+// jobErrorResponse แปลง error ของ job service เป็น status code ที่เหมาะสม
+func jobErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		return utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrJobFinished):
+		return utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrJobQueueFull), errors.Is(err, services.ErrJobServiceClosed):
+		return utils.ErrorResponse(c, http.StatusServiceUnavailable, err.Error())
+	default:
+		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
 }
@@ -1,15 +1,30 @@
 package controllers
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Teneieiza/go-spinsolf-test/repositories"
 	"github.com/Teneieiza/go-spinsolf-test/services"
 	"github.com/Teneieiza/go-spinsolf-test/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
-func GetNearbyStations(c *fiber.Ctx) error {
+// StationController รวม handler ของการค้นหาสถานี
+type StationController struct {
+	service *services.StationService
+}
+
+func NewStationController(service *services.StationService) *StationController {
+	return &StationController{service: service}
+}
+
+func (ctl *StationController) GetNearbyStations(c *fiber.Ctx) error {
 	// แปลง lat เป็น float64
 	lat, err := strconv.ParseFloat(c.Query("lat"), 64)
 	if err != nil {
@@ -29,9 +44,18 @@ func GetNearbyStations(c *fiber.Ctx) error {
 		limit = 10
 	}
 
+	// as_of ค้นหาจากข้อมูล ณ เวลาที่ระบุ (ไม่ส่งมาคือข้อมูลปัจจุบัน)
+	asOf, err := parseAsOf(c.Query("as_of"))
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
 	// เรียกใช้งาน service GetNearbyStations แล้วส่ง lat, long, limit เข้าไป
-	stations, err := services.GetNearbyStations(lat, long, limit)
+	stations, err := ctl.service.GetNearbyStations(lat, long, limit, asOf)
 	if err != nil {
+		if errors.Is(err, services.ErrAsOfTooOld) {
+			return utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		}
 		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 	}
 
@@ -43,7 +67,7 @@ func GetNearbyStations(c *fiber.Ctx) error {
 
 // GetNearbyStations ดึงสถานีที่ใกล้ที่สุดตามพิกัดที่ระบุ
 // กำหนด context ของ fiber เพื่อใช้ดึง query param body มาใช้งาน
-func GetNearbyStationsPage(c *fiber.Ctx) error {
+func (ctl *StationController) GetNearbyStationsPage(c *fiber.Ctx) error {
 	// แปลง lat เป็น float64
 	lat, err := strconv.ParseFloat(c.Query("lat"), 64)
 	if err != nil {
@@ -70,12 +94,215 @@ func GetNearbyStationsPage(c *fiber.Ctx) error {
 		limit = 10
 	}
 
+	asOf, err := parseAsOf(c.Query("as_of"))
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
 //เรียกใช้งาน service GetNearbyStations แล้วส่ง lat long page limit เข้าไป
-	stations, err := services.GetNearbyStationsPage(lat, long, page, limit)
+	stations, err := ctl.service.GetNearbyStationsPage(lat, long, page, limit, asOf)
 	if err != nil {
+		if errors.Is(err, services.ErrAsOfTooOld) {
+			return utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		}
 		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 	}
 
 	// ส่งกลับข้อมูลในรูปแบบ JSON
 	return c.JSON(stations)
 }
+
+// maxSearchLimit จำนวนผลค้นหาสูงสุดต่อครั้ง
+const maxSearchLimit = 100
+
+// SearchStations ค้นหาสถานีด้วยชื่อหรือชื่อย่อ (q=) ใช้กับช่อง typeahead ได้ เพราะจับคู่แบบ prefix
+// limit ค่าเริ่มต้น 10 สูงสุด maxSearchLimit
+func (ctl *StationController) SearchStations(c *fiber.Ctx) error {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "q is required")
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "10"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	results, err := ctl.service.SearchStations(q, limit)
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(results)
+}
+
+// GetStation ดึงสถานีตาม station_code ใน path
+func (ctl *StationController) GetStation(c *fiber.Ctx) error {
+	code, err := strconv.Atoi(c.Params("station_code"))
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "invalid station_code")
+	}
+
+	station, err := ctl.service.GetStation(code)
+	if err != nil {
+		return stationErrorResponse(c, err)
+	}
+
+	return c.JSON(station)
+}
+
+// CreateStation เพิ่มสถานีใหม่ body เป็น JSON ที่ใช้ชื่อ field แบบเดียวกับไฟล์ import
+func (ctl *StationController) CreateStation(c *fiber.Ctx) error {
+	var body map[string]interface{}
+	if err := c.BodyParser(&body); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "invalid body")
+	}
+
+	station, err := ctl.service.CreateStation(body, requestActor(c))
+	if err != nil {
+		return stationErrorResponse(c, err)
+	}
+
+	return c.Status(http.StatusCreated).JSON(station)
+}
+
+// UpdateStation แทนที่ข้อมูลสถานีทั้งหมด (PUT)
+func (ctl *StationController) UpdateStation(c *fiber.Ctx) error {
+	code, err := strconv.Atoi(c.Params("station_code"))
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "invalid station_code")
+	}
+
+	var body map[string]interface{}
+	if err := c.BodyParser(&body); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "invalid body")
+	}
+
+	station, err := ctl.service.ReplaceStation(code, body, requestActor(c))
+	if err != nil {
+		return stationErrorResponse(c, err)
+	}
+
+	return c.JSON(station)
+}
+
+// PatchStation แก้ไขเฉพาะ field ที่ส่งมา (PATCH)
+func (ctl *StationController) PatchStation(c *fiber.Ctx) error {
+	code, err := strconv.Atoi(c.Params("station_code"))
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "invalid station_code")
+	}
+
+	var body map[string]interface{}
+	if err := c.BodyParser(&body); err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "invalid body")
+	}
+
+	station, err := ctl.service.PatchStation(code, body, requestActor(c))
+	if err != nil {
+		return stationErrorResponse(c, err)
+	}
+
+	return c.JSON(station)
+}
+
+// DeleteStation ลบสถานีตาม station_code
+func (ctl *StationController) DeleteStation(c *fiber.Ctx) error {
+	code, err := strconv.Atoi(c.Params("station_code"))
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "invalid station_code")
+	}
+
+	if err := ctl.service.DeleteStation(code, requestActor(c)); err != nil {
+		return stationErrorResponse(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  http.StatusOK,
+		"message": fmt.Sprintf("station %d deleted", code),
+	})
+}
+
+// RestoreStation คืนสถานีที่ถูกลบกลับมา
+func (ctl *StationController) RestoreStation(c *fiber.Ctx) error {
+	code, err := strconv.Atoi(c.Params("station_code"))
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "invalid station_code")
+	}
+
+	station, err := ctl.service.RestoreStation(code, requestActor(c))
+	if err != nil {
+		return stationErrorResponse(c, err)
+	}
+
+	return c.JSON(station)
+}
+
+// GetStationHistory ดึงประวัติการเปลี่ยนแปลงของสถานีเรียงจากใหม่ไปเก่า (page= ค่าเริ่มต้น 1, limit= ค่าเริ่มต้น 20)
+func (ctl *StationController) GetStationHistory(c *fiber.Ctx) error {
+	code, err := strconv.Atoi(c.Params("station_code"))
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, "invalid station_code")
+	}
+
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(c.Query("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+
+	history, err := ctl.service.GetStationHistory(code, page, limit)
+	if err != nil {
+		return stationErrorResponse(c, err)
+	}
+
+	return c.JSON(history)
+}
+
+// ExportGTFSStops ดาวน์โหลดสถานีทั้งหมดเป็นไฟล์ stops.txt ของ GTFS
+func (ctl *StationController) ExportGTFSStops(c *fiber.Ctx) error {
+	var buf bytes.Buffer
+	if err := ctl.service.ExportGTFSStops(&buf); err != nil {
+		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="stops.txt"`)
+	return c.Send(buf.Bytes())
+}
+
+// parseAsOf แปลงค่า as_of เป็นเวลา รับ RFC3339 (เช่น 2024-05-01T08:00:00+07:00) หรือวันที่ 2024-05-01
+// ถ้าเป็นวันที่อย่างเดียวจะใช้ข้อมูล ณ สิ้นวันนั้น (UTC) ค่าว่างคืน zero time
+func parseAsOf(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if d, err := time.Parse("2006-01-02", s); err == nil {
+		return d.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid as_of, use RFC3339 (2006-01-02T15:04:05Z07:00) or a date (2006-01-02)")
+}
+
+// stationErrorResponse แปลง error จาก service เป็น status code ที่เหมาะสม
+func stationErrorResponse(c *fiber.Ctx, err error) error {
+	var validationErr *services.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, repositories.ErrStationNotFound):
+		return utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, repositories.ErrStationExists), errors.Is(err, services.ErrStationDeleted):
+		return utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	default:
+		return utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+}
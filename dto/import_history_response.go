@@ -0,0 +1,23 @@
+package dto
+
+// RollbackResponse ผลของการ rollback import job
+// Conflicts คือสถานีที่ถูกแก้ไขหรือลบไปหลังจาก import นั้น (แสดงสูงสุด MaxReportedRowErrors รายการ)
+type RollbackResponse struct {
+	Status              int                `json:"status"`
+	Message             string             `json:"message"`
+	JobID               string             `json:"job_id"`
+	ChangesetID         string             `json:"changeset_id"`
+	RollbackChangesetID string             `json:"rollback_changeset_id,omitempty"`
+	Restored            int                `json:"restored"`
+	Deleted             int                `json:"deleted"`
+	Skipped             int                `json:"skipped"`
+	Conflicts           []RollbackConflict `json:"conflicts"`
+	ConflictCount       int                `json:"conflict_count"`
+}
+
+// RollbackConflict สถานีที่ค่าปัจจุบันไม่ตรงกับค่าหลัง import แล้ว
+type RollbackConflict struct {
+	StationCode int    `json:"station_code"`
+	Action      string `json:"action"`
+	Reason      string `json:"reason"`
+}
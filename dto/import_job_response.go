@@ -0,0 +1,23 @@
+package dto
+
+import "time"
+
+// ImportProgress ความคืบหน้าของ import job
+type ImportProgress struct {
+	RowsParsed  int64 `json:"rows_parsed"`
+	RowsWritten int64 `json:"rows_written"`
+}
+
+// ImportJobResponse สถานะของ import job ที่ส่งกลับให้ client
+type ImportJobResponse struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Source     string                 `json:"source"`
+	State      string                 `json:"state"`
+	Progress   ImportProgress         `json:"progress"`
+	Result     *ImportStationResponse `json:"result,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+	StartedAt  *time.Time             `json:"started_at,omitempty"`
+	FinishedAt *time.Time             `json:"finished_at,omitempty"`
+}
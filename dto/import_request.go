@@ -0,0 +1,10 @@
+package dto
+
+import "github.com/Teneieiza/go-spinsolf-test/models"
+
+// ImportURLRequest body (ไม่บังคับ) ของ POST /stations/import/url
+// ใส่ url ใน body แทน query param ได้ และใส่ auth สำหรับ feed ที่ต้อง login
+type ImportURLRequest struct {
+	URL  string            `json:"url"`
+	Auth *models.FetchAuth `json:"auth,omitempty"`
+}
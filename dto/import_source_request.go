@@ -0,0 +1,23 @@
+package dto
+
+import "github.com/Teneieiza/go-spinsolf-test/models"
+
+// ImportSourceRequest body ของ POST/PUT /import-sources
+// Enabled ไม่ใส่คือ true ตอนสร้าง และไม่เปลี่ยนตอนแก้ไข
+// Auth ไม่ใส่ตอนแก้ไขคือใช้ค่าเดิม ใส่ {"type":"none"} เพื่อลบ
+type ImportSourceRequest struct {
+	Name             string            `json:"name"`
+	URL              string            `json:"url"`
+	Format           string            `json:"format"`
+	Profile          string            `json:"profile"`
+	Schedule         string            `json:"schedule"`
+	Enabled          *bool             `json:"enabled"`
+	Strict           bool              `json:"strict"`
+	Sheet            string            `json:"sheet"`
+	AllSheets        bool              `json:"all_sheets"`
+	Encoding         string            `json:"encoding"`
+	Delimiter        string            `json:"delimiter"`
+	Mode             string            `json:"mode"`
+	MaxRemovePercent float64           `json:"max_remove_percent"`
+	Auth             *models.FetchAuth `json:"auth"`
+}
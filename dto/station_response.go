@@ -26,4 +26,109 @@ type ImportStationResponse struct {
 	Message string `json:"message"`
 	Count   int    `json:"count"`
 	TotalImport int `json:"totalimported"`
+	Inserted    int `json:"inserted"`
+	Updated     int `json:"updated"`
+	Corrupted   int `json:"corrupted"`
+	Unchanged   int `json:"unchanged"`
+	Rejected    int `json:"rejected"`
+
+	// Errors รายการ error ของแต่ละแถว (แสดงสูงสุด MaxReportedRowErrors รายการ) ErrorCount คือจำนวนทั้งหมด
+	Errors     []RowError `json:"errors"`
+	ErrorCount int        `json:"error_count"`
+
+	// HeaderRow แถวที่ใช้เป็น header (เริ่มที่ 1) Headers คือผลการจับคู่ชื่อ column กับ field
+	// มีเฉพาะไฟล์ที่มีแถว header เช่น csv และ xlsx
+	HeaderRow int             `json:"header_row,omitempty"`
+	Headers   []HeaderMapping `json:"headers,omitempty"`
+
+	// Sheets สรุปของแต่ละ sheet ที่อ่าน (เฉพาะ xlsx, xls และ ods)
+	Sheets []SheetSummary `json:"sheets,omitempty"`
+
+	// Format รูปแบบไฟล์ที่ตรวจพบและใช้เลือก parser
+	Format string `json:"format,omitempty"`
+
+	// Encoding และ Delimiter ที่ใช้อ่านไฟล์ csv (ที่เลือกมาหรือเดาจากไฟล์)
+	Encoding  string `json:"encoding,omitempty"`
+	Delimiter string `json:"delimiter,omitempty"`
+
+	// Mode วิธี import (upsert หรือ sync)
+	// sync จะปิด (active = 0) สถานีที่ active อยู่แต่ไม่มีในไฟล์ จำนวนอยู่ใน Deactivated และรายการอยู่ใน DeactivatedStations
+	Mode                string        `json:"mode,omitempty"`
+	Deactivated         int           `json:"deactivated"`
+	DeactivatedStations []StationDiff `json:"deactivated_stations,omitempty"`
+	// UnkeyedRows แถวที่ถูกปฏิเสธเพราะอ่าน key ไม่ได้ (sync ไม่รู้ว่าเป็นสถานีไหน จึงไม่ปิดสถานีใดเลย)
+	UnkeyedRows []RowError `json:"unkeyed_rows,omitempty"`
+
+	// ChangesetID changeset ที่บันทึกค่าก่อน/หลังของการ import ครั้งนี้ (ไม่มีตอน dry run)
+	ChangesetID string `json:"changeset_id,omitempty"`
+
+	// Timings เวลาที่ใช้ในแต่ละขั้น (มิลลิวินาที)
+	Timings *ImportTimings `json:"timings,omitempty"`
+
+	// DryRun เป็น true เมื่อไม่ได้เขียนลง database จริง ผลการเปรียบเทียบอยู่ใน Diff
+	DryRun bool        `json:"dry_run,omitempty"`
+	Diff   *ImportDiff `json:"diff,omitempty"`
+}
+
+// ImportTimings เวลาที่ใช้ในแต่ละขั้นของการ import หน่วยเป็นมิลลิวินาที
+type ImportTimings struct {
+	ParseMS    int64 `json:"parse_ms"`
+	ValidateMS int64 `json:"validate_ms"`
+	PrefetchMS int64 `json:"prefetch_ms"`
+	DiffMS     int64 `json:"diff_ms"`
+	WriteMS    int64 `json:"write_ms"`
+	TotalMS    int64 `json:"total_ms"`
+}
+
+// HeaderMapping ผลการจับคู่ column 1 ตัว
+// Method คือวิธีที่ใช้จับคู่ (profile, exact, normalized, synonym, duplicate, unmapped)
+type HeaderMapping struct {
+	Column string `json:"column"`
+	Field  string `json:"field,omitempty"`
+	Method string `json:"method"`
+}
+
+// SheetSummary สรุปของ sheet 1 sheet ใน xlsx
+// Rows คือจำนวนแถวข้อมูลที่อ่านได้ sheet ที่ว่างจะมี Rows เป็น 0 และไม่มี header
+type SheetSummary struct {
+	Name      string          `json:"name"`
+	HeaderRow int             `json:"header_row,omitempty"`
+	Headers   []HeaderMapping `json:"headers,omitempty"`
+	Rows      int             `json:"rows"`
+}
+
+// MaxReportedRowErrors จำนวน row error สูงสุดที่ส่งกลับใน response กัน response ใหญ่เกินไป
+const MaxReportedRowErrors = 1000
+
+// RowError error ของ field ในแถวที่ import
+// Row คือลำดับของแถวข้อมูลในไฟล์ เริ่มที่ 1 (ไม่นับแถว header) ถ้าเป็น xlsx นับแยกแต่ละ sheet และมี Sheet บอกชื่อ sheet
+type RowError struct {
+	Sheet  string      `json:"sheet,omitempty"`
+	Row    int         `json:"row"`
+	Column string      `json:"column"`
+	Value  interface{} `json:"value"`
+	Reason string      `json:"reason"`
+}
+
+// ImportDiff รายงานว่าไฟล์ที่ import จะเปลี่ยนข้อมูลสถานีอะไรบ้าง
+type ImportDiff struct {
+	Inserted  []StationDiff `json:"inserted"`
+	Updated   []StationDiff `json:"updated"`
+	Unchanged []StationDiff `json:"unchanged"`
+	Corrupted []StationDiff `json:"corrupted"`
+}
+
+// StationDiff ผลเปรียบเทียบของสถานี 1 แห่ง
+// Changes มีเฉพาะรายการ updated บอกค่าเก่า -> ค่าใหม่ของแต่ละ field
+type StationDiff struct {
+	StationCode int                    `json:"station_code"`
+	Name        string                 `json:"name"`
+	Changes     map[string]FieldChange `json:"changes,omitempty"`
+	Comment     string                 `json:"comment,omitempty"`
+}
+
+// FieldChange ค่าเก่าและค่าใหม่ของ field ที่เปลี่ยน
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
 }
@@ -0,0 +1,23 @@
+package dto
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// StationSearchResult สถานี 1 แห่งในผลค้นหาด้วยข้อความ
+// Score ยิ่งมากยิ่งตรง MatchedField คือ field ที่ได้คะแนนนั้น
+type StationSearchResult struct {
+	ID           primitive.ObjectID `json:"id"`
+	StationCode  int                `json:"station_code"`
+	Name         string             `json:"name"`
+	EnName       string             `json:"en_name"`
+	ChName       string             `json:"chname"`
+	ThShort      string             `json:"th_short"`
+	EnShort      string             `json:"en_short"`
+	Active       int                `json:"active"`
+	Lat          float64            `json:"lat"`
+	Long         float64            `json:"long"`
+	Score        int                `json:"score"`
+	MatchedField string             `json:"matched_field"`
+
+	// EnNameGenerated ชื่อไทยที่ถอดเป็นอักษรโรมัน มีค่าเฉพาะสถานีที่ไม่มี en_name
+	EnNameGenerated string `json:"en_name_generated,omitempty"`
+}
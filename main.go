@@ -5,10 +5,14 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/Teneieiza/go-spinsolf-test/app"
 	"github.com/Teneieiza/go-spinsolf-test/config"
+	"github.com/Teneieiza/go-spinsolf-test/parsers"
+	"github.com/Teneieiza/go-spinsolf-test/repositories"
+	"github.com/Teneieiza/go-spinsolf-test/utils"
 )
 
 func main() {
@@ -17,21 +21,86 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := config.InitDatabase(ctx, cfg); err != nil {
+	//เลือกที่เก็บข้อมูลตาม STORAGE_DRIVER
+	//memory ใช้สำหรับ demo ไม่ต้องต่อ MongoDB
+	var stationRepo repositories.StationRepository
+	var sourceRepo repositories.ImportSourceRepository
+	var changesetRepo repositories.ImportChangesetRepository
+	var historyRepo repositories.StationHistoryRepository
+	switch cfg.STORAGE_DRIVER {
+	case "memory":
+		log.Println("Using in-memory storage, data will be lost on shutdown")
+		stationRepo = repositories.NewMemoryStationRepository()
+		sourceRepo = repositories.NewMemoryImportSourceRepository()
+		changesetRepo = repositories.NewMemoryImportChangesetRepository()
+		historyRepo = repositories.NewMemoryStationHistoryRepository()
+	default:
+		if err := config.InitDatabase(ctx, cfg); err != nil {
+			log.Fatal(err)
+		}
+		defer config.DB.Close(context.Background())
+		stationRepo = repositories.NewMongoStationRepository(config.DB.Collection)
+		sourceRepo = repositories.NewMongoImportSourceRepository(
+			config.DB.DBName.Collection(cfg.IMPORT_SOURCES_COLLECTION),
+			config.DB.DBName.Collection(cfg.IMPORT_SOURCE_RUNS_COLLECTION),
+		)
+		changesetRepo = repositories.NewMongoImportChangesetRepository(
+			config.DB.DBName.Collection(cfg.IMPORT_CHANGESETS_COLLECTION),
+			config.DB.DBName.Collection(cfg.IMPORT_CHANGES_COLLECTION),
+		)
+		historyRepo = repositories.NewMongoStationHistoryRepository(config.DB.DBName.Collection(cfg.STATION_HISTORY_COLLECTION))
+	}
+
+	//key ที่ใช้ระบุตัวสถานีตอน import เช่น station_code, id หรือ station_code,id
+	importKey, err := utils.ParseStationKey(cfg.IMPORT_KEY)
+	if err != nil {
 		log.Fatal(err)
 	}
-	defer config.DB.Close(context.Background())
 
-	app := app.NewApplication(cfg)
+	//วิธีแปลง stop_id/stop_code ของ GTFS เป็น station_code
+	if _, err := parsers.ParseGTFSIDStrategy(cfg.GTFS_ID_STRATEGY); err != nil {
+		log.Fatal(err)
+	}
+
+	//mapping profile สำหรับไฟล์ของ partner ที่ชื่อ column ไม่ตรงกับของเรา
+	profiles, err := utils.LoadMappingProfiles(cfg.MAPPING_PROFILES_FILE)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	if err := app.Start(); err != nil {
-		log.Fatalf("Error starting server: %v", err)
+	if err := stationRepo.EnsureIndexes(ctx, importKey.Fields); err != nil {
+		log.Printf("failed to create indexes: %v", err)
 	}
+	if err := changesetRepo.EnsureIndexes(ctx); err != nil {
+		log.Printf("failed to create changeset indexes: %v", err)
+	}
+	if err := historyRepo.EnsureIndexes(ctx); err != nil {
+		log.Printf("failed to create station history indexes: %v", err)
+	}
+
+	app := app.NewApplication(cfg, stationRepo, sourceRepo, changesetRepo, historyRepo, importKey, profiles)
+
+	//Start จะ block อยู่ใน Listen จนกว่า server จะหยุด จึงรันแยกแล้วรอ signal ที่นี่
+	//เพื่อให้ Shutdown ได้รัน (รอ job ที่ค้าง ลบไฟล์ชั่วคราว หยุด scheduler และรอบ purge)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- app.Start()
+	}()
 
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	<-quit
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	var startErr error
+	select {
+	case startErr = <-serverErr:
+		log.Printf("Error starting server: %v", startErr)
+	case <-quit:
+	}
 
 	log.Println("Shutting down server...")
-	app.Shutdown()
+	if err := app.Shutdown(); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+	if startErr != nil {
+		os.Exit(1)
+	}
 }
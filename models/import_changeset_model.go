@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// สถานะของ changeset
+const (
+	ChangesetRunning    = "running"     // import ยังเขียนข้อมูลอยู่
+	ChangesetApplied    = "applied"     // import จบแล้ว
+	ChangesetFailed     = "failed"      // import ล้มเหลวกลางทาง อาจเขียนไปแล้วบางส่วน (rollback ได้)
+	ChangesetRolledBack = "rolled_back" // ถูก rollback แล้ว
+)
+
+// การเปลี่ยนแปลงของสถานี 1 แห่งใน changeset
+const (
+	ChangeInsert     = "insert"
+	ChangeUpdate     = "update"
+	ChangeDeactivate = "deactivate"
+	ChangeDelete     = "delete"
+)
+
+// ImportChangeset การ import 1 ครั้ง (หรือการ rollback) ที่เปลี่ยนข้อมูลสถานี เก็บไว้ใน collection import_changesets
+// รายการสถานีที่เปลี่ยนเก็บแยกเป็น StationChange จะได้ไม่ติดขนาดสูงสุดของ document
+type ImportChangeset struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	JobID  string             `bson:"job_id,omitempty" json:"job_id,omitempty"`
+	Kind   string             `bson:"kind" json:"kind"` // file, url, source หรือ rollback
+	Source string             `bson:"source" json:"source"`
+	Actor  string             `bson:"actor" json:"actor"`
+	Mode   string             `bson:"mode,omitempty" json:"mode,omitempty"`
+	Status string             `bson:"status" json:"status"`
+	Error  string             `bson:"error,omitempty" json:"error,omitempty"`
+
+	Inserted    int `bson:"inserted" json:"inserted"`
+	Updated     int `bson:"updated" json:"updated"`
+	Deactivated int `bson:"deactivated" json:"deactivated"`
+	Deleted     int `bson:"deleted" json:"deleted"`
+	Changes     int `bson:"changes" json:"changes"` // จำนวน StationChange ทั้งหมด
+
+	// RollbackOf คือ changeset ที่ถูก rollback (เฉพาะ kind rollback)
+	// RolledBackBy คือ changeset ของการ rollback ครั้งนี้ (เฉพาะ changeset ที่ถูก rollback แล้ว)
+	RollbackOf   *primitive.ObjectID `bson:"rollback_of,omitempty" json:"rollback_of,omitempty"`
+	RolledBackBy *primitive.ObjectID `bson:"rolled_back_by,omitempty" json:"rolled_back_by,omitempty"`
+
+	CreatedAt  time.Time  `bson:"created_at" json:"created_at"`
+	FinishedAt *time.Time `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
+
+// StationChange ค่าก่อนและหลังของสถานี 1 แห่งใน changeset เก็บไว้ใน collection import_changes
+// Key คือ filter ที่ใช้หาสถานี (ตาม IMPORT_KEY) Before และ After มีเฉพาะ field ที่เปลี่ยน
+// insert ไม่มี Before และ delete มี Before เป็น document ทั้งหมด
+type StationChange struct {
+	ID          primitive.ObjectID     `bson:"_id,omitempty" json:"-"`
+	ChangesetID primitive.ObjectID     `bson:"changeset_id" json:"changeset_id"`
+	Seq         int                    `bson:"seq" json:"seq"`
+	StationCode int                    `bson:"station_code" json:"station_code"`
+	Key         map[string]interface{} `bson:"key" json:"key"`
+	Action      string                 `bson:"action" json:"action"`
+	Before      map[string]interface{} `bson:"before,omitempty" json:"before,omitempty"`
+	After       map[string]interface{} `bson:"after,omitempty" json:"after,omitempty"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ImportSource แหล่งข้อมูลที่ import ตามเวลาที่ตั้งไว้ (cron) เก็บไว้ใน collection import_sources
+type ImportSource struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name     string             `bson:"name" json:"name"`
+	URL      string             `bson:"url" json:"url"`
+	Format   string             `bson:"format,omitempty" json:"format,omitempty"` // ว่างคือหาจากข้อมูลเอง
+	Profile  string             `bson:"profile,omitempty" json:"profile,omitempty"`
+	Schedule string             `bson:"schedule" json:"schedule"` // cron 5 ช่อง หรือ @hourly, @daily, @every 15m
+	Enabled  bool               `bson:"enabled" json:"enabled"`
+
+	// ตัวเลือกของการ import เหมือน query param ของ import ผ่าน URL
+	Strict    bool   `bson:"strict,omitempty" json:"strict,omitempty"`
+	Sheet     string `bson:"sheet,omitempty" json:"sheet,omitempty"`
+	AllSheets bool   `bson:"all_sheets,omitempty" json:"all_sheets,omitempty"`
+	Encoding  string `bson:"encoding,omitempty" json:"encoding,omitempty"`
+	Delimiter string `bson:"delimiter,omitempty" json:"delimiter,omitempty"`
+	// Mode upsert หรือ sync (ปิดสถานีที่ไม่มีในข้อมูล) MaxRemovePercent 0 คือใช้ค่าจาก config
+	Mode             string  `bson:"mode,omitempty" json:"mode,omitempty"`
+	MaxRemovePercent float64 `bson:"max_remove_percent,omitempty" json:"max_remove_percent,omitempty"`
+
+	Auth *FetchAuth `bson:"auth,omitempty" json:"auth,omitempty"`
+
+	// ETag และ LastModified ของ response ที่ import สำเร็จครั้งล่าสุด ใช้ถามว่าข้อมูลเปลี่ยนหรือยัง
+	ETag         string `bson:"etag,omitempty" json:"etag,omitempty"`
+	LastModified string `bson:"last_modified,omitempty" json:"last_modified,omitempty"`
+
+	LastRunAt  *time.Time `bson:"last_run_at,omitempty" json:"last_run_at,omitempty"`
+	LastStatus string     `bson:"last_status,omitempty" json:"last_status,omitempty"`
+	NextRunAt  *time.Time `bson:"next_run_at,omitempty" json:"next_run_at,omitempty"`
+	CreatedAt  time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time  `bson:"updated_at" json:"updated_at"`
+}
+
+// FetchAuth ข้อมูล auth ที่ส่งไปกับ request ตอนดึงข้อมูลจาก URL
+// Type เป็น bearer (ใช้ Token) หรือ basic (ใช้ Username และ Password)
+type FetchAuth struct {
+	Type     string `bson:"type" json:"type"`
+	Token    string `bson:"token,omitempty" json:"token,omitempty"`
+	Username string `bson:"username,omitempty" json:"username,omitempty"`
+	Password string `bson:"password,omitempty" json:"password,omitempty"`
+}
+
+// Redacted คืนสำเนาที่ซ่อน token และรหัสผ่าน ใช้ตอนส่งกลับให้ client
+func (a *FetchAuth) Redacted() *FetchAuth {
+	if a == nil {
+		return nil
+	}
+	r := *a
+	if r.Token != "" {
+		r.Token = "***"
+	}
+	if r.Password != "" {
+		r.Password = "***"
+	}
+	return &r
+}
+
+// ImportSourceRun ประวัติการรันของ ImportSource 1 ครั้ง เก็บไว้ใน collection import_source_runs
+type ImportSourceRun struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SourceID    primitive.ObjectID `bson:"source_id" json:"source_id"`
+	JobID       string             `bson:"job_id,omitempty" json:"job_id,omitempty"`
+	Trigger     string             `bson:"trigger" json:"trigger"` // schedule หรือ manual
+	Status      string             `bson:"status" json:"status"`   // succeeded, not_modified, failed, cancelled
+	Error       string             `bson:"error,omitempty" json:"error,omitempty"`
+	Inserted    int                `bson:"inserted" json:"inserted"`
+	Updated     int                `bson:"updated" json:"updated"`
+	Unchanged   int                `bson:"unchanged" json:"unchanged"`
+	Rejected    int                `bson:"rejected" json:"rejected"`
+	Deactivated int                `bson:"deactivated" json:"deactivated"`
+	StartedAt   time.Time          `bson:"started_at" json:"started_at"`
+	FinishedAt  time.Time          `bson:"finished_at" json:"finished_at"`
+}
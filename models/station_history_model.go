@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ที่มาของการเปลี่ยนแปลงใน StationVersion
+const (
+	HistorySourceImport   = "import"   // import จากไฟล์, url หรือ import source
+	HistorySourceRollback = "rollback" // rollback import job
+	HistorySourceAPI      = "api"      // แก้ไขผ่าน /api/stations โดยตรง
+	HistorySourcePurge    = "purge"    // ลบจริงเมื่อถูก soft delete นานเกินระยะเวลาที่เก็บไว้
+)
+
+// action ที่มีเฉพาะในประวัติของสถานี (import ไม่ลบหรือ restore สถานี)
+// Before และ After มีเฉพาะ deleted_at และ deleted_by
+const (
+	ChangeSoftDelete = "soft_delete"
+	ChangeRestore    = "restore"
+)
+
+// StationVersion การเปลี่ยนแปลงของสถานี 1 แห่ง 1 ครั้ง เก็บไว้ใน collection station_history แบบเพิ่มอย่างเดียว ไม่แก้ไขหรือลบ
+// Action ใช้ค่าเดียวกับ StationChange รวมกับ ChangeSoftDelete และ ChangeRestore Before และ After มีเฉพาะ field ที่เปลี่ยน
+// insert ไม่มี Before และ delete มี Before เป็น document ทั้งหมด จึงย้อนสถานะของสถานีกลับไปเวลาไหนก็ได้
+type StationVersion struct {
+	ID          primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	StationCode int                    `bson:"station_code" json:"station_code"`
+	Action      string                 `bson:"action" json:"action"`
+	Before      map[string]interface{} `bson:"before,omitempty" json:"before,omitempty"`
+	After       map[string]interface{} `bson:"after,omitempty" json:"after,omitempty"`
+
+	Source      string              `bson:"source" json:"source"`
+	Actor       string              `bson:"actor" json:"actor"`
+	JobID       string              `bson:"job_id,omitempty" json:"job_id,omitempty"`
+	ChangesetID *primitive.ObjectID `bson:"changeset_id,omitempty" json:"changeset_id,omitempty"`
+	ChangedAt   time.Time           `bson:"changed_at" json:"changed_at"`
+}
@@ -1,6 +1,10 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Station struct {
 	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
@@ -23,6 +27,19 @@ type Station struct {
 	DualTrack     int                `bson:"dual_track" json:"dual_track"`
 	Comment       string             `bson:"comment" json:"comment"`
 	Location      map[string]interface{} `bson:"location" json:"location"`
+
+	// EnNameGenerated ชื่อไทยที่ถอดเป็นอักษรโรมัน (RTGS) มีค่าเฉพาะสถานีที่ไม่มี en_name
+	// แยกจาก en_name เพราะเป็นค่าที่โปรแกรมสร้าง อาจไม่ตรงกับชื่อทางการ
+	EnNameGenerated string `bson:"en_name_generated" json:"en_name_generated,omitempty"`
+	// FuzzyName FuzzyEnName FuzzyRoman คำนวณจากชื่อตอนเขียน (utils.ApplySearchKeys) ใช้กับการค้นหาแบบไม่เข้มงวด
+	FuzzyName   string `bson:"fuzzy_name" json:"-"`
+	FuzzyEnName string `bson:"fuzzy_en_name" json:"-"`
+	FuzzyRoman  string `bson:"fuzzy_roman" json:"-"`
+
+	// DeletedAt และ DeletedBy มีค่าเมื่อสถานีถูกลบ (soft delete) สถานีที่ถูกลบจะไม่แสดงในทุก query จนกว่าจะ restore
+	// ต่างจาก active = 0 ที่หมายถึงปิดชั่วคราว
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy string     `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
 }
 
 
@@ -45,4 +62,7 @@ var FieldTypes = map[string]string{
 	"giveway":         "int",
 	"dual_track":      "int",
 	"comment":         "string",
-}
\ No newline at end of file
+}
+
+// SearchFields field ที่ใช้ค้นหาสถานีด้วยข้อความ (ชื่อทุกภาษาและชื่อย่อ)
+var SearchFields = []string{"name", "en_name", "chname", "th_short", "en_short"}
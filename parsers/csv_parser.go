@@ -1,15 +1,27 @@
 package parsers
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/csv"
 	"errors"
+	"io"
 	"reflect"
 	"strconv"
 	"strings"
+
+	"github.com/Teneieiza/go-spinsolf-test/utils"
 )
 
-type CSVParser struct{}
+// CSVParser อ่านไฟล์ csv
+// ถ้าตั้ง Headers จะหาแถว header เองและจับคู่ชื่อ column กับ field ด้วย resolver (ใช้ตอนอ่านแบบ stream)
+type CSVParser struct {
+	Headers *utils.HeaderResolver
+	// Encoding ชื่อ encoding จาก ParseEncoding ว่างหรือ auto คือเดาจากไฟล์
+	Encoding string
+	// Delimiter ตัวคั่น column 0 คือเดาจากไฟล์ (, ; tab หรือ |)
+	Delimiter rune
+}
 
 //Parse แปลงข้อมูล csv
 func (p *CSVParser) Parse(data []byte, target interface{}) error {
@@ -57,3 +69,94 @@ func setFieldValue(f reflect.Value, v string) {
 		f.SetString(v)
 	}
 }
+
+// NewRowReader อ่าน csv ทีละแถวจาก r
+// แปลงข้อความเป็น UTF-8 ก่อน (ดู decodeText) และเดาตัวคั่นจากแถวแรกๆ ถ้าไม่ได้กำหนด
+// ถ้าไม่มี Headers ใช้แถวแรกเป็น header ถ้ามีจะหาแถว header จากแถวแรกๆ (ดู detectHeader)
+func (p *CSVParser) NewRowReader(r io.Reader) (RowReader, error) {
+	enc := p.Encoding
+	if enc == "" {
+		enc = EncodingAuto
+	}
+	text, enc, err := decodeText(r, enc)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReaderSize(text, csvSniffSize)
+	delimiter := p.Delimiter
+	if delimiter == 0 {
+		sample, _ := br.Peek(csvSniffSize)
+		delimiter = sniffDelimiter(sample)
+	}
+
+	cr := csv.NewReader(br)
+	cr.Comma = delimiter
+	cr.FieldsPerRecord = -1 // ยอมให้แต่ละแถวมีจำนวน column ไม่เท่ากัน
+
+	rr := &csvRowReader{r: cr, encoding: enc, delimiter: delimiter}
+	headers, pending, info, err := detectHeader(rr.read, p.Headers)
+	if err == io.EOF {
+		return nil, errors.New("empty CSV")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rr.headers, rr.pending, rr.headerInfo = headers, pending, info
+	return rr, nil
+}
+
+// csvSniffSize จำนวน byte แรก (หลังแปลงเป็น UTF-8) ที่ใช้เดาตัวคั่น
+const csvSniffSize = 64 << 10
+
+type csvRowReader struct {
+	r       *csv.Reader
+	headers []string
+	pending []sourceRow // แถวข้อมูลที่อ่านมาแล้วตอนหา header
+	headerInfo
+	encoding  string
+	delimiter rune
+}
+
+// EncodingInfo บอก encoding และตัวคั่นที่ใช้อ่านไฟล์ (ว่างถ้าไม่ได้เดา เช่น GTFS)
+func (cr *csvRowReader) EncodingInfo() (string, string) {
+	if cr.delimiter == 0 {
+		return cr.encoding, ""
+	}
+	return cr.encoding, string(cr.delimiter)
+}
+
+// read อ่าน record ถัดไป เลขแถวคือบรรทัดที่ record เริ่ม (csv ข้ามบรรทัดว่าง และค่าในเครื่องหมายคำพูดขึ้นบรรทัดใหม่ได้)
+// บรรทัดที่มีแต่ตัวคั่นหรือช่องว่างจะถูกข้ามไปเหมือนบรรทัดว่าง
+func (cr *csvRowReader) read() (sourceRow, error) {
+	for {
+		cells, err := cr.r.Read()
+		if err != nil {
+			return sourceRow{}, err
+		}
+		if isEmptyRow(cells) {
+			continue
+		}
+		line, _ := cr.r.FieldPos(0)
+		return sourceRow{cells: cells, num: line}, nil
+	}
+}
+
+func (cr *csvRowReader) Next() (map[string]interface{}, error) {
+	if len(cr.pending) > 0 {
+		row := cr.pending[0]
+		cr.pending = cr.pending[1:]
+		return rowToMap(cr.headers, row), nil
+	}
+
+	row, err := cr.read()
+	if err != nil {
+		return nil, err
+	}
+	return rowToMap(cr.headers, row), nil
+}
+
+func (cr *csvRowReader) Close() error {
+	return nil
+}
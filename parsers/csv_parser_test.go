@@ -0,0 +1,68 @@
+package parsers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSVRowReaderSkipsBlankRows(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		codes []string
+		rows  []int
+	}{
+		{
+			name:  "no blank rows",
+			input: "station_code,name\nA1,Alpha\nB2,Beta\n",
+			codes: []string{"A1", "B2"},
+			rows:  []int{2, 3},
+		},
+		{
+			name:  "empty line in the middle",
+			input: "station_code,name\nA1,Alpha\n\nB2,Beta\n",
+			codes: []string{"A1", "B2"},
+			rows:  []int{2, 4},
+		},
+		{
+			name:  "delimiter-only lines",
+			input: "station_code,name\nA1,Alpha\n,\n , \nB2,Beta\n,,\n",
+			codes: []string{"A1", "B2"},
+			rows:  []int{2, 5},
+		},
+		{
+			name:  "semicolon padding before the header",
+			input: ";;\n;;\nstation_code;name\nA1;Alpha\n;;\n",
+			codes: []string{"A1"},
+			rows:  []int{4},
+		},
+	}
+	for _, tt := range tests {
+		p := &CSVParser{Headers: testResolver()}
+		rr, err := p.NewRowReader(strings.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("%s: NewRowReader: %v", tt.name, err)
+			continue
+		}
+		rows := readAllRows(t, rr)
+		var codes []string
+		for _, m := range rows {
+			code, _ := m["station_code"].(string)
+			codes = append(codes, code)
+		}
+		if !reflect.DeepEqual(codes, tt.codes) {
+			t.Errorf("%s: codes = %v, want %v", tt.name, codes, tt.codes)
+		}
+		if got := rowNums(rows); !reflect.DeepEqual(got, tt.rows) {
+			t.Errorf("%s: rows = %v, want %v", tt.name, got, tt.rows)
+		}
+	}
+}
+
+func TestCSVRowReaderOnlyBlankRows(t *testing.T) {
+	p := &CSVParser{Headers: testResolver()}
+	if _, err := p.NewRowReader(strings.NewReader(",,\n\n , \n")); err == nil {
+		t.Error("NewRowReader on a file with only blank rows: want error")
+	}
+}
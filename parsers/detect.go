@@ -0,0 +1,225 @@
+package parsers
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"strings"
+)
+
+// รูปแบบไฟล์ที่ import ได้
+const (
+	FormatCSV     = "csv"
+	FormatJSON    = "json"
+	FormatGeoJSON = "geojson"
+	FormatKML     = "kml"
+	FormatKMZ     = "kmz"
+	FormatGTFS    = "gtfs"
+	FormatXLSX    = "xlsx"
+	FormatXLS     = "xls"
+	FormatODS     = "ods"
+)
+
+// Formats รูปแบบไฟล์ทั้งหมดที่รองรับ
+var Formats = []string{FormatCSV, FormatJSON, FormatGeoJSON, FormatKML, FormatKMZ, FormatGTFS, FormatXLSX, FormatXLS, FormatODS}
+
+// IsFormat ตรวจว่า name เป็นรูปแบบที่รองรับหรือไม่
+func IsFormat(name string) bool {
+	for _, f := range Formats {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// detectSniffSize จำนวน byte แรกของไฟล์ที่ใช้ดู magic bytes
+const detectSniffSize = 8 << 10
+
+var (
+	zipMagic = []byte("PK\x03\x04")
+	oleMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1} // xls (OLE compound file)
+)
+
+// contentTypeFormats รูปแบบไฟล์ตาม Content-Type (ไม่รวม parameter เช่น charset)
+var contentTypeFormats = map[string]string{
+	"text/csv":                             FormatCSV,
+	"application/csv":                      FormatCSV,
+	"text/tab-separated-values":            FormatCSV,
+	"application/json":                     FormatJSON,
+	"text/json":                            FormatJSON,
+	"application/x-ndjson":                 FormatJSON,
+	"application/ndjson":                   FormatJSON,
+	"application/geo+json":                 FormatGeoJSON,
+	"application/vnd.geo+json":             FormatGeoJSON,
+	"application/vnd.google-earth.kml+xml": FormatKML,
+	"application/vnd.google-earth.kmz":     FormatKMZ,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": FormatXLSX,
+	"application/vnd.ms-excel":                       FormatXLS,
+	"application/vnd.oasis.opendocument.spreadsheet": FormatODS,
+}
+
+// extensionFormats รูปแบบไฟล์ตามนามสกุล
+var extensionFormats = map[string]string{
+	".csv":     FormatCSV,
+	".tsv":     FormatCSV,
+	".json":    FormatJSON,
+	".ndjson":  FormatJSON,
+	".geojson": FormatGeoJSON,
+	".kml":     FormatKML,
+	".kmz":     FormatKMZ,
+	".zip":     FormatGTFS,
+	".txt":     FormatGTFS,
+	".xlsx":    FormatXLSX,
+	".xls":     FormatXLS,
+	".ods":     FormatODS,
+}
+
+// DetectFileFormat หารูปแบบของไฟล์ที่ path
+// ดูจากเนื้อไฟล์ก่อน (magic bytes และรายชื่อไฟล์ใน zip) ถ้าบอกไม่ได้ค่อยดู contentType แล้วจึงดูนามสกุลของ filename
+// คืน "" ถ้าหาไม่ได้
+func DetectFileFormat(filePath, contentType, filename string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, detectSniffSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	head = head[:n]
+
+	//Content-Type ก่อนนามสกุล แต่ใช้ได้เฉพาะ hint ที่เข้ากับเนื้อไฟล์
+	//(เช่น browser บางตัวส่ง Content-Type ของ Excel มากับไฟล์ .csv)
+	hints := []string{formatFromContentType(contentType), formatFromFilename(filename)}
+
+	switch {
+	case bytes.HasPrefix(head, zipMagic):
+		if format := detectZipFormat(filePath); format != "" {
+			return format, nil
+		}
+		return firstHint(hints, FormatKMZ, FormatGTFS, FormatXLSX, FormatODS), nil
+	case bytes.HasPrefix(head, oleMagic):
+		return FormatXLS, nil
+	}
+
+	textHint := firstHint(hints, FormatCSV, FormatJSON, FormatGeoJSON, FormatKML, FormatGTFS)
+	if format := detectTextFormat(head, textHint); format != "" {
+		return format, nil
+	}
+	if textHint != "" {
+		return textHint, nil
+	}
+	//ไม่มีอะไรบอกเลย ถ้าเป็นข้อความถือว่าเป็น csv
+	if len(head) > 0 && bytes.IndexByte(head, 0) < 0 {
+		return FormatCSV, nil
+	}
+	return "", nil
+}
+
+// firstHint คืน hint ตัวแรกที่เป็นหนึ่งใน allowed คืน "" ถ้าไม่มี
+func firstHint(hints []string, allowed ...string) string {
+	for _, h := range hints {
+		for _, a := range allowed {
+			if h != "" && h == a {
+				return h
+			}
+		}
+	}
+	return ""
+}
+
+// detectZipFormat ดูรายชื่อไฟล์ใน zip ว่าเป็น xlsx, ods, kmz หรือ GTFS
+func detectZipFormat(filePath string) string {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return ""
+	}
+	defer zr.Close()
+
+	var hasKML, hasStops, hasODSContent bool
+	for _, f := range zr.File {
+		name := strings.ToLower(f.Name)
+		switch {
+		case name == "[content_types].xml" || strings.HasPrefix(name, "xl/"):
+			return FormatXLSX
+		case name == "mimetype":
+			if rc, err := f.Open(); err == nil {
+				b, _ := io.ReadAll(io.LimitReader(rc, 128))
+				rc.Close()
+				if strings.Contains(string(b), "opendocument.spreadsheet") {
+					return FormatODS
+				}
+			}
+		case name == "content.xml":
+			hasODSContent = true
+		case strings.HasSuffix(name, ".kml"):
+			hasKML = true
+		case path.Base(name) == "stops.txt":
+			hasStops = true
+		}
+	}
+	switch {
+	case hasKML:
+		return FormatKMZ
+	case hasStops:
+		return FormatGTFS
+	case hasODSContent:
+		return FormatODS
+	}
+	return ""
+}
+
+// detectTextFormat ดูตัวอักษรแรกของไฟล์ข้อความ { หรือ [ คือ JSON, <kml คือ KML
+// header ที่มี stop_id และ stop_lat คือ stops.txt ของ GTFS คืน "" ถ้าบอกไม่ได้
+func detectTextFormat(head []byte, hint string) string {
+	head = bytes.TrimPrefix(head, []byte{0xEF, 0xBB, 0xBF})
+	text := bytes.TrimSpace(head)
+	if len(text) == 0 {
+		return ""
+	}
+
+	switch text[0] {
+	case '{', '[':
+		//GeoJSON ก็เป็น JSON ดูจาก type ของ object หรือ hint
+		if hint == FormatGeoJSON || bytes.Contains(text, []byte(`"FeatureCollection"`)) {
+			return FormatGeoJSON
+		}
+		return FormatJSON
+	case '<':
+		if bytes.Contains(text, []byte("<kml")) {
+			return FormatKML
+		}
+		return ""
+	}
+
+	firstLine := string(text)
+	if i := strings.IndexAny(firstLine, "\r\n"); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	if strings.Contains(firstLine, "stop_id") && strings.Contains(firstLine, "stop_lat") {
+		return FormatGTFS
+	}
+	return ""
+}
+
+func formatFromContentType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return contentTypeFormats[mediaType]
+}
+
+func formatFromFilename(filename string) string {
+	return extensionFormats[strings.ToLower(path.Ext(filename))]
+}
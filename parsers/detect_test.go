@@ -0,0 +1,103 @@
+package parsers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectFileFormat(t *testing.T) {
+	const xlsxType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	xlsx := buildZip(t, []string{"[Content_Types].xml", "xl/workbook.xml"}, map[string]string{
+		"[Content_Types].xml": "<Types/>",
+		"xl/workbook.xml":     "<workbook/>",
+	})
+	ods := buildZip(t, []string{"mimetype", "content.xml"}, map[string]string{
+		"mimetype":    "application/vnd.oasis.opendocument.spreadsheet",
+		"content.xml": "<office:document-content/>",
+	})
+	kmz := buildZip(t, []string{"doc.kml", "files/icon.png"}, map[string]string{
+		"doc.kml":        kmlDoc(),
+		"files/icon.png": "png",
+	})
+	gtfs := buildZip(t, []string{"feed/agency.txt", "feed/stops.txt"}, map[string]string{
+		"feed/agency.txt": "agency_id\n1\n",
+		"feed/stops.txt":  "stop_id,stop_name,stop_lat,stop_lon\n1,Siam,13.7,100.5\n",
+	})
+	unknownZip := buildZip(t, []string{"readme.txt"}, map[string]string{"readme.txt": "hello"})
+	xls := append([]byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, make([]byte, 32)...)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		filename    string
+		want        string
+	}{
+		// zip ดูจากรายชื่อไฟล์ข้างใน ไม่ใช่นามสกุล
+		{name: "xlsx", data: xlsx, filename: "stations.zip", want: FormatXLSX},
+		{name: "ods from mimetype", data: ods, filename: "stations.xlsx", want: FormatODS},
+		{
+			name: "ods without mimetype",
+			data: buildZip(t, []string{"content.xml"}, map[string]string{"content.xml": "<office:document-content/>"}),
+			want: FormatODS,
+		},
+		{name: "kmz", data: kmz, contentType: "application/zip", want: FormatKMZ},
+		{name: "gtfs feed with a folder", data: gtfs, filename: "feed.kmz", want: FormatGTFS},
+		{name: "unknown zip uses a zip hint", data: unknownZip, filename: "stations.kmz", want: FormatKMZ},
+		{name: "unknown zip ignores a text hint", data: unknownZip, filename: "stations.csv", want: ""},
+		{name: "broken zip uses a zip hint", data: []byte("PK\x03\x04broken"), contentType: xlsxType, want: FormatXLSX},
+		{name: "xls by magic bytes", data: xls, filename: "stations.csv", want: FormatXLS},
+
+		// ไฟล์ข้อความดูจากตัวอักษรแรก
+		{name: "json object", data: []byte(` {"station_code": 1}`), want: FormatJSON},
+		{name: "json array with bom", data: []byte("\xEF\xBB\xBF[{\"station_code\": 1}]"), want: FormatJSON},
+		{name: "geojson by type", data: []byte(`{"type": "FeatureCollection", "features": []}`), filename: "stations.json", want: FormatGeoJSON},
+		{name: "geojson by hint", data: []byte(`{"type": "Feature"}`), contentType: "application/geo+json", want: FormatGeoJSON},
+		{name: "kml", data: []byte(kmlDoc()), filename: "stations.xml", want: FormatKML},
+		{name: "other xml uses the hint", data: []byte("<Document/>"), filename: "stations.kml", want: FormatKML},
+		{name: "gtfs stops.txt header", data: []byte("stop_id,stop_name,stop_lat,stop_lon\r\n1,Siam,13.7,100.5\r\n"), filename: "upload", want: FormatGTFS},
+		{name: "csv", data: []byte("station_code,name\n1,Siam\n"), filename: "stations.csv", want: FormatCSV},
+		{name: "tsv extension is csv", data: []byte("station_code\tname\n"), filename: "STATIONS.TSV", want: FormatCSV},
+		{name: "excel content type on a csv file", data: []byte("station_code,name\n"), contentType: "application/vnd.ms-excel", want: FormatCSV},
+		{name: "content type wins over extension", data: []byte("station_code,name\n"), contentType: "application/json; charset=utf-8", filename: "stations.csv", want: FormatJSON},
+		{name: "bad content type falls back to extension", data: []byte("station_code,name\n"), contentType: "text/", filename: "stations.tsv", want: FormatCSV},
+		{name: "text without hints is csv", data: []byte("station_code,name\n"), want: FormatCSV},
+		{name: "binary without hints", data: []byte{0x00, 0x01, 0x02}, want: ""},
+		{name: "empty file without hints", data: nil, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "upload")
+			if err := os.WriteFile(path, tt.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := DetectFileFormat(path, tt.contentType, tt.filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("DetectFileFormat = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectFileFormatMissingFile(t *testing.T) {
+	if _, err := DetectFileFormat(filepath.Join(t.TempDir(), "missing"), "", "stations.csv"); err == nil {
+		t.Error("DetectFileFormat on a missing file: want error")
+	}
+}
+
+func TestIsFormat(t *testing.T) {
+	for _, f := range Formats {
+		if !IsFormat(f) {
+			t.Errorf("IsFormat(%q) = false, want true", f)
+		}
+	}
+	for _, f := range []string{"", "CSV", "xml", "zip"} {
+		if IsFormat(f) {
+			t.Errorf("IsFormat(%q) = true, want false", f)
+		}
+	}
+}
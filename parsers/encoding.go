@@ -0,0 +1,221 @@
+package parsers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// ชื่อ encoding ที่รองรับ (ใช้กับ encoding= และรายงานผล)
+const (
+	EncodingAuto       = "auto"
+	EncodingUTF8       = "utf-8"
+	EncodingUTF16LE    = "utf-16le"
+	EncodingUTF16BE    = "utf-16be"
+	EncodingWindows874 = "windows-874"
+)
+
+// encodingAliases ชื่ออื่นที่ใช้เรียก encoding เดียวกัน
+// TIS-620 และ ISO-8859-11 อ่านด้วย Windows-874 ได้เพราะ Windows-874 ครอบคลุมตัวอักษรทั้งหมดของทั้งสองแบบ
+var encodingAliases = map[string]string{
+	"":            EncodingAuto,
+	"auto":        EncodingAuto,
+	"utf8":        EncodingUTF8,
+	"utf-8":       EncodingUTF8,
+	"utf-16le":    EncodingUTF16LE,
+	"utf16le":     EncodingUTF16LE,
+	"utf-16be":    EncodingUTF16BE,
+	"utf16be":     EncodingUTF16BE,
+	"tis-620":     EncodingWindows874,
+	"tis620":      EncodingWindows874,
+	"iso-8859-11": EncodingWindows874,
+	"windows-874": EncodingWindows874,
+	"cp874":       EncodingWindows874,
+}
+
+// encodingSniffSize จำนวน byte แรกที่ใช้เดา encoding และตัวคั่น
+const encodingSniffSize = 1 << 20
+
+// ParseEncoding แปลงชื่อ encoding จาก query param เป็นชื่อมาตรฐาน
+func ParseEncoding(name string) (string, error) {
+	if enc, ok := encodingAliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return enc, nil
+	}
+	return "", fmt.Errorf("unsupported encoding %q use auto, utf-8, utf-16le, utf-16be, tis-620 or windows-874", name)
+}
+
+// ParseDelimiter แปลงตัวคั่นจาก query param ว่างหรือ auto คือเดาจากไฟล์ (คืน 0)
+func ParseDelimiter(s string) (rune, error) {
+	switch strings.ToLower(s) {
+	case "", "auto":
+		return 0, nil
+	case ",", "comma":
+		return ',', nil
+	case ";", "semicolon":
+		return ';', nil
+	case "\t", "tab", `\t`:
+		return '\t', nil
+	case "|", "pipe":
+		return '|', nil
+	}
+	return 0, fmt.Errorf("unsupported delimiter %q use comma, semicolon, tab or pipe", s)
+}
+
+// EncodingReporter RowReader ที่บอกได้ว่าอ่านไฟล์ด้วย encoding และตัวคั่นอะไร
+type EncodingReporter interface {
+	EncodingInfo() (encoding string, delimiter string)
+}
+
+// decodeText ตัด BOM และแปลงข้อความจาก r เป็น UTF-8 คืน reader ใหม่และชื่อ encoding ที่ใช้
+// enc เป็นชื่อจาก ParseEncoding ถ้าเป็น auto จะดูจาก BOM ก่อน ถ้าไม่มีจะดูว่า byte แรกๆ เป็น UTF-8 ที่ถูกต้องหรือไม่
+// ถ้าไม่ใช่ถือว่าเป็น Windows-874 (TIS-620) ซึ่งเป็น encoding เก่าที่ไฟล์ภาษาไทยใช้กัน
+func decodeText(r io.Reader, enc string) (io.Reader, string, error) {
+	br := bufio.NewReaderSize(r, encodingSniffSize)
+	sample, err := br.Peek(encodingSniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", err
+	}
+	complete := err == io.EOF
+
+	//BOM บอก encoding ได้แน่นอน ใช้ BOM ก่อนค่าที่เลือก ยกเว้นเลือกไว้ชัดเจนว่าเป็นอย่างอื่น
+	bom := ""
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xEF, 0xBB, 0xBF}):
+		bom = EncodingUTF8
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		bom = EncodingUTF16LE
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		bom = EncodingUTF16BE
+	}
+	if enc == EncodingAuto || enc == bom {
+		if bom != "" {
+			enc = bom
+		} else if validUTF8Prefix(sample, complete) {
+			enc = EncodingUTF8
+		} else {
+			enc = EncodingWindows874
+		}
+	}
+
+	var decoder *encoding.Decoder
+	switch enc {
+	case EncodingUTF8:
+		if bom == EncodingUTF8 {
+			br.Discard(3)
+		}
+		return br, enc, nil
+	case EncodingUTF16LE:
+		decoder = unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder()
+	case EncodingUTF16BE:
+		decoder = unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewDecoder()
+	case EncodingWindows874:
+		decoder = charmap.Windows874.NewDecoder()
+	default:
+		return nil, "", fmt.Errorf("unsupported encoding %q", enc)
+	}
+	return transform.NewReader(br, decoder), enc, nil
+}
+
+// validUTF8Prefix ตรวจว่า b เป็น UTF-8 ที่ถูกต้อง
+// ถ้ายังอ่านไม่จบไฟล์ (complete เป็น false) ตัวอักษรสุดท้ายอาจถูกตัดครึ่ง จึงไม่นับ byte ท้ายที่ยังไม่ครบตัว
+func validUTF8Prefix(b []byte, complete bool) bool {
+	if !complete {
+		for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+			if r, _ := utf8.DecodeLastRune(b); r != utf8.RuneError {
+				break
+			}
+			b = b[:len(b)-1]
+		}
+	}
+	return utf8.Valid(b)
+}
+
+// delimiterCandidates ตัวคั่นที่ลองเดา เรียงตามลำดับที่เลือกเมื่อคะแนนเท่ากัน
+var delimiterCandidates = []rune{',', ';', '\t', '|'}
+
+// sniffDelimiter เดาตัวคั่นจากแถวแรกๆ ของ sample
+// เลือกตัวที่พบในทุกแถวด้วยจำนวนเท่ากันมากที่สุด ถ้าไม่มีตัวไหนสม่ำเสมอเลือกตัวที่พบมากที่สุดในแถวแรก
+// ไม่นับตัวที่อยู่ในเครื่องหมายคำพูด ถ้าไม่พบเลยใช้ ,
+func sniffDelimiter(sample []byte) rune {
+	lines := splitSampleLines(sample, maxHeaderScan+5)
+	if len(lines) == 0 {
+		return ','
+	}
+
+	best, bestScore := ',', 0
+	for _, d := range delimiterCandidates {
+		first := countOutsideQuotes(lines[0], d)
+		if first == 0 {
+			continue
+		}
+		score := first
+		for _, line := range lines[1:] {
+			if countOutsideQuotes(line, d) != first {
+				score = 0
+				break
+			}
+		}
+		if score > bestScore {
+			best, bestScore = d, score
+		}
+	}
+	if bestScore > 0 {
+		return best
+	}
+
+	//ไม่มีตัวไหนสม่ำเสมอ (เช่นมีแถวชื่อรายงานอยู่ด้านบน) ใช้ตัวที่พบมากที่สุดรวมทุกแถว
+	bestCount := 0
+	for _, d := range delimiterCandidates {
+		n := 0
+		for _, line := range lines {
+			n += countOutsideQuotes(line, d)
+		}
+		if n > bestCount {
+			best, bestCount = d, n
+		}
+	}
+	return best
+}
+
+// splitSampleLines แบ่ง sample เป็นแถวที่ไม่ว่าง ไม่เกิน max แถว ไม่ใช้แถวสุดท้ายถ้าอาจถูกตัดครึ่ง
+func splitSampleLines(sample []byte, max int) []string {
+	text := string(sample)
+	complete := strings.HasSuffix(text, "\n")
+	parts := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if !complete && len(parts) > 1 {
+		parts = parts[:len(parts)-1]
+	}
+
+	var lines []string
+	for _, p := range parts {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		lines = append(lines, p)
+		if len(lines) == max {
+			break
+		}
+	}
+	return lines
+}
+
+func countOutsideQuotes(line string, d rune) int {
+	n, quoted := 0, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == d && !quoted:
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,178 @@
+package parsers
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// encodeText แปลงข้อความ UTF-8 เป็น byte ของ encoding ที่ส่งมา
+func encodeText(t *testing.T, enc encoding.Encoding, s string) []byte {
+	t.Helper()
+	b, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecodeText(t *testing.T) {
+	const text = "station_code,name\n1,สยาม\n"
+	utf8BOM := append([]byte("\xEF\xBB\xBF"), text...)
+	tis620 := encodeText(t, charmap.Windows874, text)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		enc     string
+		want    string
+		wantEnc string
+	}{
+		{name: "plain utf-8", data: []byte(text), enc: EncodingAuto, want: text, wantEnc: EncodingUTF8},
+		{name: "utf-8 bom is removed", data: utf8BOM, enc: EncodingAuto, want: text, wantEnc: EncodingUTF8},
+		{name: "utf-8 bom with utf-8 chosen", data: utf8BOM, enc: EncodingUTF8, want: text, wantEnc: EncodingUTF8},
+		{
+			name:    "utf-16le bom",
+			data:    encodeText(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), text),
+			enc:     EncodingAuto,
+			want:    text,
+			wantEnc: EncodingUTF16LE,
+		},
+		{
+			name:    "utf-16be bom",
+			data:    encodeText(t, unicode.UTF16(unicode.BigEndian, unicode.UseBOM), text),
+			enc:     EncodingAuto,
+			want:    text,
+			wantEnc: EncodingUTF16BE,
+		},
+		{name: "invalid utf-8 falls back to windows-874", data: tis620, enc: EncodingAuto, want: text, wantEnc: EncodingWindows874},
+		{name: "windows-874 chosen", data: tis620, enc: EncodingWindows874, want: text, wantEnc: EncodingWindows874},
+		{
+			name:    "explicit encoding wins over a different bom",
+			data:    append([]byte("\xEF\xBB\xBF"), tis620...),
+			enc:     EncodingWindows874,
+			want:    "๏ปฟ" + text,
+			wantEnc: EncodingWindows874,
+		},
+		{name: "ascii only is utf-8", data: []byte("a;b\n1;2\n"), enc: EncodingAuto, want: "a;b\n1;2\n", wantEnc: EncodingUTF8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, enc, err := decodeText(bytes.NewReader(tt.data), tt.enc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if enc != tt.wantEnc {
+				t.Errorf("encoding = %q, want %q", enc, tt.wantEnc)
+			}
+			if string(got) != tt.want {
+				t.Errorf("text = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidUTF8Prefix(t *testing.T) {
+	siam := []byte("สยาม")
+	tests := []struct {
+		name     string
+		data     []byte
+		complete bool
+		want     bool
+	}{
+		{name: "whole text", data: siam, complete: true, want: true},
+		{name: "last rune cut in the sample", data: siam[:len(siam)-1], complete: false, want: true},
+		{name: "last rune cut at end of file", data: siam[:len(siam)-1], complete: true, want: false},
+		{name: "windows-874 bytes", data: []byte("1,\xCA\xC2\xD2\xC1\n2,\xCA\xC2\xD2\xC1"), complete: false, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validUTF8Prefix(tt.data, tt.complete); got != tt.want {
+				t.Errorf("validUTF8Prefix(%q, %v) = %v, want %v", tt.data, tt.complete, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSniffDelimiter(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample string
+		want   rune
+	}{
+		{name: "comma", sample: "station_code,name,lat\n1,Siam,13.7\n", want: ','},
+		{name: "semicolon", sample: "station_code;name;lat\n1;Siam;13,7\n", want: ';'},
+		{name: "tab", sample: "station_code\tname\n1\tSiam, Bangkok\n", want: '\t'},
+		{name: "pipe", sample: "station_code|name\n1|Siam\n", want: '|'},
+		{name: "delimiters inside quotes do not count", sample: "station_code;name\n1;\"Siam, Bangkok, Thailand\"\n", want: ';'},
+		{name: "consistent count wins over a larger one", sample: "a,b;c;d\n1,2;3\n4,5;6;7\n", want: ','},
+		{name: "tie goes to the earlier candidate", sample: "a;b,c\n1;2,3\n", want: ','},
+		{name: "no consistent delimiter uses the most common", sample: "Report: stations\na;b;c\n1;2;3\nx,y\n", want: ';'},
+		{name: "half cut last line is ignored", sample: "a|b\n1|2\n3", want: '|'},
+		{name: "crlf and blank lines", sample: "a;b\r\n\r\n1;2\r\n", want: ';'},
+		{name: "no delimiter defaults to comma", sample: "station_code\n1\n", want: ','},
+		{name: "empty sample", sample: "", want: ','},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sniffDelimiter([]byte(tt.sample)); got != tt.want {
+				t.Errorf("sniffDelimiter(%q) = %q, want %q", tt.sample, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", EncodingAuto, false},
+		{" AUTO ", EncodingAuto, false},
+		{"UTF8", EncodingUTF8, false},
+		{"utf-16le", EncodingUTF16LE, false},
+		{"UTF16BE", EncodingUTF16BE, false},
+		{"TIS-620", EncodingWindows874, false},
+		{"iso-8859-11", EncodingWindows874, false},
+		{"cp874", EncodingWindows874, false},
+		{"latin1", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseEncoding(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseEncoding(%q) = %q, %v, want %q, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    rune
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"Auto", 0, false},
+		{",", ',', false},
+		{"semicolon", ';', false},
+		{`\t`, '\t', false},
+		{"TAB", '\t', false},
+		{"pipe", '|', false},
+		{":", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDelimiter(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseDelimiter(%q) = %q, %v, want %q, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
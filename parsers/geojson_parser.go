@@ -0,0 +1,156 @@
+package parsers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+)
+
+// GeoJSONParser อ่าน GeoJSON FeatureCollection ที่แต่ละ feature เป็น Point
+// พิกัดของ geometry จะถูกใช้เป็น lat/long ส่วน properties ใช้เฉพาะ field ที่อยู่ใน models.FieldTypes
+type GeoJSONParser struct{}
+
+func (p *GeoJSONParser) Parse(data []byte, target interface{}) error {
+	return collectRows(p, data, target)
+}
+
+// NewRowReader อ่าน features ทีละตัวจาก r โดยไม่ต้องโหลดทั้งไฟล์
+func (p *GeoJSONParser) NewRowReader(r io.Reader) (RowReader, error) {
+	br := bufio.NewReader(r)
+	if _, err := peekNonSpace(br); err == io.EOF {
+		return nil, errors.New("empty GeoJSON")
+	}
+
+	dec := json.NewDecoder(br)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, errors.New("GeoJSON must be a FeatureCollection object")
+	}
+
+	//อ่าน key ของ object ชั้นนอกไปเรื่อยๆ จนเจอ features แล้วหยุดไว้ตรงนั้น
+	gr := &geoJSONRowReader{dec: dec}
+	found, err := gr.scanKeys()
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("GeoJSON has no features")
+	}
+	return gr, nil
+}
+
+type geoJSONRowReader struct {
+	dec  *json.Decoder
+	done bool
+}
+
+// geoJSONFeature โครงสร้างของ feature 1 ตัว
+type geoJSONFeature struct {
+	Type     string `json:"type"`
+	Geometry *struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+func (gr *geoJSONRowReader) Next() (map[string]interface{}, error) {
+	if gr.done {
+		return nil, io.EOF
+	}
+	if !gr.dec.More() {
+		//อ่าน ] ของ features แล้วอ่าน key ที่เหลือให้จบ (เพื่อตรวจ type ที่อาจอยู่หลัง features)
+		if _, err := gr.dec.Token(); err != nil {
+			return nil, err
+		}
+		if _, err := gr.scanKeys(); err != nil {
+			return nil, err
+		}
+		gr.done = true
+		return nil, io.EOF
+	}
+
+	var f geoJSONFeature
+	if err := gr.dec.Decode(&f); err != nil {
+		return nil, err
+	}
+
+	//feature ที่ใช้ไม่ได้ให้คืน RowError จะถูกปฏิเสธเฉพาะแถวนี้
+	if f.Type != "Feature" {
+		return nil, &RowError{Column: "type", Value: f.Type, Reason: "must be Feature"}
+	}
+	if f.Geometry == nil {
+		return nil, &RowError{Column: "geometry", Value: nil, Reason: "geometry is missing"}
+	}
+	if f.Geometry.Type != "Point" {
+		return nil, &RowError{Column: "geometry", Value: f.Geometry.Type, Reason: "geometry type must be Point"}
+	}
+	var coords []float64
+	if err := json.Unmarshal(f.Geometry.Coordinates, &coords); err != nil || len(coords) < 2 {
+		return nil, &RowError{Column: "geometry.coordinates", Value: string(f.Geometry.Coordinates), Reason: "must be [long, lat]"}
+	}
+
+	m := make(map[string]interface{}, len(f.Properties)+2)
+	for k, v := range f.Properties {
+		if _, ok := models.FieldTypes[k]; ok {
+			m[k] = v
+		}
+	}
+	//พิกัดของ geometry มาก่อนค่า lat/long ใน properties เสมอ
+	m["long"] = coords[0]
+	m["lat"] = coords[1]
+	return m, nil
+}
+
+func (gr *geoJSONRowReader) Close() error {
+	return nil
+}
+
+// scanKeys อ่าน key ของ object ชั้นนอก ข้ามค่าที่ไม่ได้ใช้
+// คืน true เมื่อเจอ features (ตัวอ่านจะอยู่ที่ element แรกของ array) คืน false เมื่อจบ object
+func (gr *geoJSONRowReader) scanKeys() (bool, error) {
+	for gr.dec.More() {
+		tok, err := gr.dec.Token()
+		if err != nil {
+			return false, err
+		}
+		switch tok {
+		case "type":
+			var t string
+			if err := gr.dec.Decode(&t); err != nil {
+				return false, err
+			}
+			if t != "FeatureCollection" {
+				return false, fmt.Errorf("GeoJSON type %q is not supported, use FeatureCollection", t)
+			}
+		case "features":
+			if err := expectDelim(gr.dec, '['); err != nil {
+				return false, errors.New("GeoJSON features must be an array")
+			}
+			return true, nil
+		default:
+			var skip json.RawMessage
+			if err := gr.dec.Decode(&skip); err != nil {
+				return false, err
+			}
+		}
+	}
+	//อ่าน } ของ object ชั้นนอก
+	_, err := gr.dec.Token()
+	return false, err
+}
+
+// expectDelim อ่าน token ถัดไปและตรวจว่าเป็นวงเล็บที่ต้องการ
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != want {
+		return fmt.Errorf("expected %q", want)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package parsers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGeoJSONRowReader(t *testing.T) {
+	point := func(code string, long, lat string) string {
+		return `{"type":"Feature","geometry":{"type":"Point","coordinates":[` + long + `,` + lat + `]},"properties":{"station_code":` + code + `,"name":"S` + code + `","color":"red"}}`
+	}
+	tests := []struct {
+		name    string
+		input   string
+		rows    []map[string]interface{}
+		reasons []string
+	}{
+		{
+			name:  "points",
+			input: `{"type":"FeatureCollection","features":[` + point("1001", "100.5", "13.7") + `,` + point("1002", "100.6", "13.8") + `]}`,
+			rows: []map[string]interface{}{
+				{"station_code": 1001.0, "name": "S1001", "long": 100.5, "lat": 13.7},
+				{"station_code": 1002.0, "name": "S1002", "long": 100.6, "lat": 13.8},
+			},
+		},
+		{
+			name: "keys around features in any order",
+			input: `{"name":"export","crs":{"type":"name","properties":{"name":"EPSG:4326"}},"features":[` + point("1001", "100.5", "13.7") +
+				`],"type":"FeatureCollection"}`,
+			rows: []map[string]interface{}{{"station_code": 1001.0, "name": "S1001", "long": 100.5, "lat": 13.7}},
+		},
+		{
+			name:  "geometry wins over lat and long in properties",
+			input: `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[100.5,13.7,12]},"properties":{"station_code":1,"lat":1,"long":2}}]}`,
+			rows:  []map[string]interface{}{{"station_code": 1.0, "long": 100.5, "lat": 13.7}},
+		},
+		{
+			name: "bad features are rejected one by one",
+			input: `{"type":"FeatureCollection","features":[` +
+				`{"type":"Feature","geometry":{"type":"LineString","coordinates":[[100,13],[101,14]]},"properties":{}},` +
+				`{"type":"Feature","geometry":null,"properties":{"station_code":2}},` +
+				`{"type":"Feature","geometry":{"type":"Point","coordinates":[100.5]},"properties":{}},` +
+				`{"type":"Thing","geometry":{"type":"Point","coordinates":[100.5,13.7]}},` +
+				point("1003", "100.7", "13.9") + `]}`,
+			rows:    []map[string]interface{}{{"station_code": 1003.0, "name": "S1003", "long": 100.7, "lat": 13.9}},
+			reasons: []string{"geometry type must be Point", "geometry is missing", "must be [long, lat]", "must be Feature"},
+		},
+		{
+			name:  "empty features",
+			input: `{"type":"FeatureCollection","features":[]}`,
+		},
+	}
+	for _, tt := range tests {
+		rr, err := (&GeoJSONParser{}).NewRowReader(strings.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("%s: NewRowReader: %v", tt.name, err)
+			continue
+		}
+		rows, rowErrs := readRowsAndErrors(t, rr)
+		if len(rows) != len(tt.rows) || (len(rows) > 0 && !reflect.DeepEqual(rows, tt.rows)) {
+			t.Errorf("%s: rows = %v, want %v", tt.name, rows, tt.rows)
+		}
+		var reasons []string
+		for _, e := range rowErrs {
+			reasons = append(reasons, e.Reason)
+		}
+		if !reflect.DeepEqual(reasons, tt.reasons) {
+			t.Errorf("%s: row errors = %v, want %v", tt.name, reasons, tt.reasons)
+		}
+	}
+}
+
+func TestGeoJSONRowReaderRejectsFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "  \n", "empty GeoJSON"},
+		{"array", `[{"type":"Feature"}]`, "must be a FeatureCollection object"},
+		{"single feature", `{"type":"Feature","geometry":{"type":"Point","coordinates":[100,13]}}`, `"Feature" is not supported`},
+		{"no features", `{"type":"FeatureCollection"}`, "has no features"},
+		{"features is not an array", `{"type":"FeatureCollection","features":{}}`, "features must be an array"},
+	}
+	for _, tt := range tests {
+		_, err := (&GeoJSONParser{}).NewRowReader(strings.NewReader(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want it to contain %q", tt.name, err, tt.want)
+		}
+	}
+
+	//type ที่อยู่หลัง features ตรวจได้ตอนอ่าน features จบ
+	rr, err := (&GeoJSONParser{}).NewRowReader(strings.NewReader(`{"features":[],"type":"GeometryCollection"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rr.Next(); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("Next with a trailing wrong type: err = %v, want not supported", err)
+	}
+}
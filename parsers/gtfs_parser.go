@@ -0,0 +1,263 @@
+package parsers
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+)
+
+// วิธีแปลง id ของ GTFS (เป็น string) เป็น station_code (เป็น int)
+const (
+	// GTFSIDNumeric id ต้องเป็นตัวเลขล้วน เช่น "1001"
+	GTFSIDNumeric = "numeric"
+	// GTFSIDDigits ใช้เฉพาะตัวเลขใน id เช่น "BTS-N08" -> 8
+	GTFSIDDigits = "digits"
+	// GTFSIDHash hash id เป็นตัวเลข (FNV-1a 31 bit) ใช้กับ id ที่ไม่มีตัวเลข มีโอกาสชนกันได้เล็กน้อย
+	GTFSIDHash = "hash"
+)
+
+// InsertOnlyKey key ที่ใส่ไว้ในแถวเพื่อบอก field ที่เป็นแค่ค่าตั้งต้นของสถานีใหม่ (ไม่ใช่ field ของสถานี)
+// สถานีที่มีอยู่แล้วจะคงค่าเดิมของ field เหล่านี้ไว้ ค่าเป็น []string
+const InsertOnlyKey = "_insert_only"
+
+// ParseGTFSIDStrategy ตรวจค่า config GTFS_ID_STRATEGY
+func ParseGTFSIDStrategy(s string) (string, error) {
+	switch s {
+	case GTFSIDNumeric, GTFSIDDigits, GTFSIDHash:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid GTFS id strategy %q (use numeric, digits or hash)", s)
+}
+
+// GTFSParser อ่าน stops.txt ของ GTFS ได้ทั้งไฟล์ stops.txt ตรงๆ และไฟล์ zip ของ GTFS feed
+// map stop_code (หรือ stop_id ถ้าไม่มี) -> station_code, stop_id -> id, stop_name -> name, stop_lat/stop_lon -> lat/long
+// stop_id ที่เป็นค่าเดียวกับ station_code (เช่นไฟล์ที่ export จากที่นี่) และ active ใช้กับสถานีใหม่เท่านั้น (ดู InsertOnlyKey)
+// IDStrategy คือวิธีแปลง id เป็นตัวเลข (ดู GTFSIDNumeric, GTFSIDDigits, GTFSIDHash)
+type GTFSParser struct {
+	IDStrategy string
+}
+
+func (p *GTFSParser) Parse(data []byte, target interface{}) error {
+	return collectRows(p, data, target)
+}
+
+// NewRowReader อ่าน stops.txt ทีละแถว ถ้าเป็น zip จะแตกใน memory แล้วหา stops.txt ข้างใน
+func (p *GTFSParser) NewRowReader(r io.Reader) (RowReader, error) {
+	br := bufio.NewReader(r)
+
+	var closer io.Closer
+	if magic, _ := br.Peek(4); bytes.Equal(magic, []byte("PK\x03\x04")) {
+		stops, err := openGTFSStops(br)
+		if err != nil {
+			return nil, err
+		}
+		closer = stops
+		br = bufio.NewReader(stops)
+	}
+
+	//ไฟล์ GTFS มักมี UTF-8 BOM นำหน้า header
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte("\xEF\xBB\xBF")) {
+		br.Discard(3)
+	}
+
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	headers, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty stops.txt")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range headers {
+		headers[i] = strings.TrimSpace(headers[i])
+	}
+
+	return &gtfsRowReader{
+		csvRowReader: csvRowReader{r: cr, headers: headers},
+		strategy:     p.IDStrategy,
+		closer:       closer,
+	}, nil
+}
+
+// openGTFSStops แตก zip ใน memory แล้วเปิดไฟล์ stops.txt
+func openGTFSStops(r io.Reader) (io.ReadCloser, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid GTFS zip: %w", err)
+	}
+	//บาง feed zip มาทั้ง folder ให้ดูแค่ชื่อไฟล์
+	for _, f := range zr.File {
+		if path.Base(f.Name) == "stops.txt" {
+			return f.Open()
+		}
+	}
+	return nil, errors.New("GTFS zip has no stops.txt")
+}
+
+type gtfsRowReader struct {
+	csvRowReader
+	strategy string
+	closer   io.Closer
+}
+
+func (gr *gtfsRowReader) Next() (map[string]interface{}, error) {
+	row, err := gr.csvRowReader.Next()
+	if err != nil {
+		return nil, err
+	}
+	get := func(k string) string {
+		s, _ := row[k].(string)
+		return s
+	}
+
+	stopID := get("stop_id")
+	if stopID == "" {
+		return nil, &RowError{Column: "stop_id", Value: "", Reason: "is required"}
+	}
+
+	//location_type 2-4 คือทางเข้า/จุดเชื่อม/จุดขึ้นรถ ไม่ใช่สถานี
+	switch lt := get("location_type"); lt {
+	case "", "0", "1":
+	default:
+		return nil, &RowError{Column: "location_type", Value: lt, Reason: "is not a stop or station"}
+	}
+
+	//station_code มาจาก stop_code ถ้าไม่มีใช้ stop_id แทน
+	codeColumn, codeValue := "stop_code", get("stop_code")
+	if codeValue == "" {
+		codeColumn, codeValue = "stop_id", stopID
+	}
+	code, err := GTFSStationCode(codeValue, gr.strategy)
+	if err != nil {
+		return nil, &RowError{Column: codeColumn, Value: codeValue, Reason: err.Error()}
+	}
+
+	m := map[string]interface{}{
+		"station_code": code,
+		"name":         get("stop_name"),
+		"lat":          get("stop_lat"),
+		"long":         get("stop_lon"),
+		// GTFS ไม่มีสถานะ active สถานีใหม่ใน feed ถือว่าใช้งานอยู่ สถานีเดิมคงสถานะไว้
+		"active": 1,
+	}
+	insertOnly := []string{"active"}
+	//id ใช้ stop_id ถ้าแปลงเป็นตัวเลขไม่ได้ก็ไม่ใส่
+	//ถ้า stop_id ถูกใช้เป็น station_code อยู่แล้ว ไม่ใช่ id จริงของสถานี ห้ามทับ id ของสถานีเดิม
+	if id, err := GTFSStationCode(stopID, gr.strategy); err == nil {
+		m["id"] = id
+		if codeColumn == "stop_id" || codeValue == stopID {
+			insertOnly = append(insertOnly, "id")
+		}
+	}
+	m[InsertOnlyKey] = insertOnly
+	return m, nil
+}
+
+func (gr *gtfsRowReader) Close() error {
+	if gr.closer != nil {
+		return gr.closer.Close()
+	}
+	return nil
+}
+
+// GTFSStationCode แปลง id ของ GTFS เป็นตัวเลขตาม strategy
+func GTFSStationCode(id, strategy string) (int, error) {
+	id = strings.TrimSpace(id)
+	switch strategy {
+	case GTFSIDDigits:
+		var digits strings.Builder
+		for _, r := range id {
+			if r >= '0' && r <= '9' {
+				digits.WriteRune(r)
+			}
+		}
+		if digits.Len() == 0 {
+			return 0, errors.New("has no digits")
+		}
+		n, err := strconv.Atoi(digits.String())
+		if err != nil || n <= 0 {
+			return 0, errors.New("digits are not a valid station code")
+		}
+		return n, nil
+	case GTFSIDHash:
+		if id == "" {
+			return 0, errors.New("is empty")
+		}
+		h := fnv.New32a()
+		h.Write([]byte(id))
+		n := int(h.Sum32() & 0x7fffffff)
+		if n == 0 {
+			n = 1
+		}
+		return n, nil
+	default:
+		n, err := strconv.Atoi(id)
+		if err != nil || n <= 0 {
+			return 0, errors.New("must be a positive integer (or use GTFS_ID_STRATEGY=digits or hash)")
+		}
+		return n, nil
+	}
+}
+
+// GTFSStopsWriter เขียนสถานีเป็นไฟล์ stops.txt ของ GTFS
+// stops.txt ไม่มีสถานะ active คนเรียกควรเขียนเฉพาะสถานีที่ active
+type GTFSStopsWriter struct {
+	w *csv.Writer
+}
+
+// gtfsStopsHeader column ของ stops.txt ที่ export (stop_id, stop_name, stop_lat, stop_lon เป็น field บังคับของ GTFS)
+var gtfsStopsHeader = []string{"stop_id", "stop_code", "stop_name", "stop_lat", "stop_lon", "location_type"}
+
+func NewGTFSStopsWriter(w io.Writer) (*GTFSStopsWriter, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(gtfsStopsHeader); err != nil {
+		return nil, err
+	}
+	return &GTFSStopsWriter{w: cw}, nil
+}
+
+// Write เขียนสถานี 1 แถว
+// stop_id ใช้ station_code ถ้าไม่มีใช้ ObjectID แทน เพราะ stop_id ต้องไม่ซ้ำและห้ามว่าง
+func (gw *GTFSStopsWriter) Write(st models.Station) error {
+	stopID, stopCode := st.ID.Hex(), ""
+	if st.StationCode != 0 {
+		stopID = strconv.Itoa(st.StationCode)
+		stopCode = stopID
+	}
+	name := st.Name
+	if name == "" {
+		name = st.EnName
+	}
+	if name == "" {
+		name = stopID
+	}
+	return gw.w.Write([]string{
+		stopID,
+		stopCode,
+		name,
+		strconv.FormatFloat(st.Lat, 'f', -1, 64),
+		strconv.FormatFloat(st.Long, 'f', -1, 64),
+		"0",
+	})
+}
+
+// Flush เขียนข้อมูลที่ค้างอยู่ใน buffer ออกไป
+func (gw *GTFSStopsWriter) Flush() error {
+	gw.w.Flush()
+	return gw.w.Error()
+}
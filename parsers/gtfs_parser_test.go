@@ -0,0 +1,166 @@
+package parsers
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGTFSStationCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		strategy string
+		want     int
+		wantErr  bool
+	}{
+		{name: "numeric", id: "1001", strategy: GTFSIDNumeric, want: 1001},
+		{name: "numeric trims spaces", id: " 42 ", strategy: GTFSIDNumeric, want: 42},
+		{name: "numeric rejects letters", id: "BTS-N08", strategy: GTFSIDNumeric, wantErr: true},
+		{name: "numeric rejects zero", id: "0", strategy: GTFSIDNumeric, wantErr: true},
+		{name: "empty strategy is numeric", id: "7", strategy: "", want: 7},
+		{name: "digits", id: "BTS-N08", strategy: GTFSIDDigits, want: 8},
+		{name: "digits joins all digits", id: "A1-B2", strategy: GTFSIDDigits, want: 12},
+		{name: "digits rejects id without digits", id: "SIAM", strategy: GTFSIDDigits, wantErr: true},
+		{name: "digits rejects zero", id: "N00", strategy: GTFSIDDigits, wantErr: true},
+		{name: "hash rejects empty id", id: "  ", strategy: GTFSIDHash, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GTFSStationCode(tt.id, tt.strategy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GTFSStationCode(%q, %q) error = %v, wantErr %v", tt.id, tt.strategy, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("GTFSStationCode(%q, %q) = %d, want %d", tt.id, tt.strategy, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGTFSStationCodeHashIsStable(t *testing.T) {
+	a, err := GTFSStationCode("SIAM", GTFSIDHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GTFSStationCode(" SIAM ", GTFSIDHash)
+	c, _ := GTFSStationCode("ASOK", GTFSIDHash)
+	if a <= 0 || a != b {
+		t.Errorf("hash of SIAM = %d and %d, want the same positive code", a, b)
+	}
+	if a == c {
+		t.Errorf("SIAM and ASOK hash to the same code %d", a)
+	}
+}
+
+func TestGTFSRowReader(t *testing.T) {
+	const stops = "stop_id,stop_code,stop_name,stop_lat,stop_lon,location_type\n" +
+		"1001,,Siam,13.7456,100.5341,1\n" +
+		"2002,15,Asok,13.7370,100.5603,0\n"
+
+	tests := []struct {
+		name     string
+		data     []byte
+		strategy string
+		want     []map[string]interface{}
+		rejected []string
+	}{
+		{
+			name: "stop_code wins over stop_id",
+			data: []byte(stops),
+			want: []map[string]interface{}{
+				{"station_code": 1001, "id": 1001, "name": "Siam", "lat": "13.7456", "long": "100.5341", "active": 1, InsertOnlyKey: []string{"active", "id"}},
+				{"station_code": 15, "id": 2002, "name": "Asok", "lat": "13.7370", "long": "100.5603", "active": 1, InsertOnlyKey: []string{"active"}},
+			},
+		},
+		{
+			name: "utf-8 bom before header",
+			data: []byte("\xEF\xBB\xBFstop_id,stop_name,stop_lat,stop_lon\n7,Phaya Thai,13.7565,100.5344\n"),
+			want: []map[string]interface{}{
+				{"station_code": 7, "id": 7, "name": "Phaya Thai", "lat": "13.7565", "long": "100.5344", "active": 1, InsertOnlyKey: []string{"active", "id"}},
+			},
+		},
+		{
+			name: "stops.txt inside a folder of the zip",
+			data: buildZip(t, []string{"feed/agency.txt", "feed/stops.txt"}, map[string]string{
+				"feed/agency.txt": "agency_id,agency_name\n1,BTS\n",
+				"feed/stops.txt":  stops,
+			}),
+			want: []map[string]interface{}{
+				{"station_code": 1001, "id": 1001, "name": "Siam", "lat": "13.7456", "long": "100.5341", "active": 1, InsertOnlyKey: []string{"active", "id"}},
+				{"station_code": 15, "id": 2002, "name": "Asok", "lat": "13.7370", "long": "100.5603", "active": 1, InsertOnlyKey: []string{"active"}},
+			},
+		},
+		{
+			name:     "digits strategy keeps id when stop_code differs",
+			data:     []byte("stop_id,stop_code,stop_name\nBTS-N08,N8,Mo Chit\n"),
+			strategy: GTFSIDDigits,
+			want: []map[string]interface{}{
+				{"station_code": 8, "id": 8, "name": "Mo Chit", "lat": "", "long": "", "active": 1, InsertOnlyKey: []string{"active"}},
+			},
+		},
+		{
+			name: "stop_id that is not a number leaves id out",
+			data: []byte("stop_id,stop_code,stop_name\nSIAM,9,Siam\n"),
+			want: []map[string]interface{}{
+				{"station_code": 9, "name": "Siam", "lat": "", "long": "", "active": 1, InsertOnlyKey: []string{"active"}},
+			},
+		},
+		{
+			name: "rejected rows",
+			data: []byte("stop_id,stop_code,stop_name,location_type\n" +
+				",,No id,0\n" +
+				"3,,Entrance,2\n" +
+				"X1,,Letters,\n" +
+				"4,,Kept,\n"),
+			want: []map[string]interface{}{
+				{"station_code": 4, "id": 4, "name": "Kept", "lat": "", "long": "", "active": 1, InsertOnlyKey: []string{"active", "id"}},
+			},
+			rejected: []string{"stop_id", "location_type", "stop_id"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr, err := (&GTFSParser{IDStrategy: tt.strategy}).NewRowReader(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows, rowErrs := readRowsAndErrors(t, rr)
+			if !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("rows = %v, want %v", rows, tt.want)
+			}
+			var columns []string
+			for _, e := range rowErrs {
+				columns = append(columns, e.Column)
+			}
+			if !reflect.DeepEqual(columns, tt.rejected) {
+				t.Errorf("rejected columns = %v, want %v", columns, tt.rejected)
+			}
+		})
+	}
+}
+
+func TestGTFSRowReaderRejectsFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{name: "empty stops.txt", data: nil, want: "empty stops.txt"},
+		{
+			name: "zip without stops.txt",
+			data: buildZip(t, []string{"agency.txt"}, map[string]string{"agency.txt": "agency_id\n1\n"}),
+			want: "GTFS zip has no stops.txt",
+		},
+		{name: "broken zip", data: []byte("PK\x03\x04 not really a zip"), want: "invalid GTFS zip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&GTFSParser{}).NewRowReader(bytes.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewRowReader error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package parsers
+
+import (
+	"io"
+	"strings"
+
+	"github.com/Teneieiza/go-spinsolf-test/dto"
+	"github.com/Teneieiza/go-spinsolf-test/utils"
+)
+
+// maxHeaderScan จำนวนแถวแรกที่ใช้หาแถว header (บางไฟล์มีแถวชื่อรายงานอยู่ด้านบน)
+const maxHeaderScan = 10
+
+// HeaderReporter RowReader ที่บอกได้ว่าใช้แถวไหนเป็น header และจับคู่ column กับ field อย่างไร
+type HeaderReporter interface {
+	HeaderInfo() (row int, headers []dto.HeaderMapping)
+}
+
+// headerInfo ผลการหา header เก็บไว้ตอบ HeaderInfo
+type headerInfo struct {
+	row     int
+	headers []dto.HeaderMapping
+}
+
+func (h headerInfo) HeaderInfo() (int, []dto.HeaderMapping) {
+	return h.row, h.headers
+}
+
+// sourceRow ค่าของแถวพร้อมเลขแถวในไฟล์หรือ sheet (เริ่มที่ 1 นับแถวว่างและแถวด้านบน header ด้วย)
+type sourceRow struct {
+	cells []string
+	num   int
+}
+
+// detectHeader อ่านแถวแรกๆ ด้วย next เพื่อหาแถว header
+// ถ้า resolver เป็น nil จะใช้แถวแรกเป็น header ตรงๆ แบบเดิม
+// ถ้ามี resolver จะเลือกแถวที่จับคู่ column ได้มากที่สุดใน maxHeaderScan แถวแรก (เท่ากันเลือกแถวบนสุด)
+// คืน key ของแต่ละ column และแถวข้อมูลที่อ่านเกินมาแล้ว ซึ่งต้องใช้ก่อนอ่านแถวถัดไป
+func detectHeader(next func() (sourceRow, error), resolver *utils.HeaderResolver) (keys []string, pending []sourceRow, info headerInfo, err error) {
+	if resolver == nil {
+		row, err := next()
+		return row.cells, nil, info, err
+	}
+
+	var rows []sourceRow
+	for len(rows) < maxHeaderScan {
+		row, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, info, err
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, nil, info, io.EOF
+	}
+
+	best, bestMatched := 0, 0
+	for i, row := range rows {
+		if _, _, matched := resolver.ResolveHeaders(row.cells); matched > bestMatched {
+			best, bestMatched = i, matched
+		}
+	}
+
+	keys, report, _ := resolver.ResolveHeaders(rows[best].cells)
+	return keys, rows[best+1:], headerInfo{row: rows[best].num, headers: report}, nil
+}
+
+// rowToMap จับคู่ค่าในแถวกับ key ของ column ข้าม column ที่ key ว่าง และใส่เลขแถวไว้ที่ RowKey
+func rowToMap(keys []string, row sourceRow) map[string]interface{} {
+	m := make(map[string]interface{})
+	for i, k := range keys {
+		if i >= len(row.cells) || k == "" {
+			continue
+		}
+		m[k] = strings.TrimSpace(row.cells[i])
+	}
+	m[RowKey] = row.num
+	return m
+}
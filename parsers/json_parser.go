@@ -1,7 +1,10 @@
 package parsers
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
+	"io"
 )
 
 type JSONParser struct{}
@@ -9,3 +12,73 @@ type JSONParser struct{}
 func (p *JSONParser) Parse(data []byte, target interface{}) error {
 	return json.Unmarshal(data, target)
 }
+
+// NewRowReader อ่าน JSON ทีละ object จาก r
+// รองรับทั้ง JSON array ([{...}, {...}]) และ NDJSON (1 object ต่อบรรทัด)
+func (p *JSONParser) NewRowReader(r io.Reader) (RowReader, error) {
+	br := bufio.NewReader(r)
+
+	//ดูตัวอักษรแรกที่ไม่ใช่ช่องว่าง เพื่อแยกว่าเป็น array หรือ NDJSON
+	first, err := peekNonSpace(br)
+	if err == io.EOF {
+		return nil, errors.New("empty JSON")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(br)
+	array := first == '['
+	if array {
+		//อ่าน [ ทิ้งไป แล้วค่อย decode ทีละ element
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &jsonRowReader{dec: dec, array: array}, nil
+}
+
+type jsonRowReader struct {
+	dec   *json.Decoder
+	array bool
+}
+
+func (jr *jsonRowReader) Next() (map[string]interface{}, error) {
+	if jr.array && !jr.dec.More() {
+		return nil, io.EOF
+	}
+
+	var m map[string]interface{}
+	if err := jr.dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (jr *jsonRowReader) Close() error {
+	return nil
+}
+
+// peekNonSpace ข้ามช่องว่าง (และ UTF-8 BOM) แล้วดูตัวอักษรถัดไปโดยไม่อ่านออก
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+		case 0xEF:
+			//UTF-8 BOM (EF BB BF)
+			if bom, err := br.Peek(3); err == nil && bom[1] == 0xBB && bom[2] == 0xBF {
+				br.Discard(3)
+				continue
+			}
+			return b[0], nil
+		default:
+			return b[0], nil
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package parsers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+)
+
+// KMLParser อ่าน Placemark ที่เป็น Point จากไฟล์ KML (Google Earth)
+// Placemark ที่อยู่ใน Folder/Document ซ้อนกันกี่ชั้นก็ได้
+// พิกัดมาจาก <Point><coordinates> ส่วน field อื่นมาจาก <ExtendedData> ที่ชื่อตรงกับ models.FieldTypes
+type KMLParser struct{}
+
+func (p *KMLParser) Parse(data []byte, target interface{}) error {
+	return collectRows(p, data, target)
+}
+
+// NewRowReader อ่าน Placemark ทีละตัวจาก r
+func (p *KMLParser) NewRowReader(r io.Reader) (RowReader, error) {
+	return &kmlRowReader{dec: xml.NewDecoder(r)}, nil
+}
+
+// KMZParser อ่านไฟล์ KMZ (zip ที่มีไฟล์ KML อยู่ข้างใน)
+// แตก zip แล้วอ่าน doc.kml (หรือไฟล์ .kml ไฟล์แรก) ด้วย KMLParser
+type KMZParser struct{}
+
+func (p *KMZParser) Parse(data []byte, target interface{}) error {
+	return collectRows(p, data, target)
+}
+
+// NewRowReader อ่านทั้ง zip เข้า memory (zip ต้องอ่านแบบ random access) แล้ว stream ไฟล์ KML ข้างใน
+// ถ้ามีไฟล์บน disk ให้ใช้ OpenFile แทน
+func (p *KMZParser) NewRowReader(r io.Reader) (RowReader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid KMZ: %w", err)
+	}
+	return openKMZ(zr, nil)
+}
+
+// OpenFile อ่าน zip จากไฟล์บน disk ตรงๆ ไม่โหลดทั้งไฟล์เข้า memory
+func (p *KMZParser) OpenFile(name string) (RowReader, error) {
+	zc, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KMZ: %w", err)
+	}
+	rr, err := openKMZ(&zc.Reader, zc)
+	if err != nil {
+		zc.Close()
+		return nil, err
+	}
+	return rr, nil
+}
+
+// openKMZ เปิดไฟล์ KML หลักใน zr archive คือสิ่งที่ต้องปิดพร้อม RowReader (nil ถ้าไม่มี)
+func openKMZ(zr *zip.Reader, archive io.Closer) (RowReader, error) {
+	//ตาม spec ของ KMZ ไฟล์หลักคือ doc.kml ถ้าไม่มีให้ใช้ไฟล์ .kml ไฟล์แรก
+	var kml *zip.File
+	for _, f := range zr.File {
+		if strings.EqualFold(path.Ext(f.Name), ".kml") {
+			if strings.EqualFold(f.Name, "doc.kml") {
+				kml = f
+				break
+			}
+			if kml == nil {
+				kml = f
+			}
+		}
+	}
+	if kml == nil {
+		return nil, errors.New("KMZ has no .kml file")
+	}
+
+	rc, err := kml.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &kmlRowReader{dec: xml.NewDecoder(rc), closer: rc, archive: archive}, nil
+}
+
+type kmlRowReader struct {
+	dec     *xml.Decoder
+	closer  io.Closer
+	archive io.Closer // zip ที่เปิดจากไฟล์ (KMZParser.OpenFile)
+}
+
+// kmlPlacemark โครงสร้างของ Placemark 1 ตัว
+type kmlPlacemark struct {
+	Name  string `xml:"name"`
+	Point *struct {
+		Coordinates string `xml:"coordinates"`
+	} `xml:"Point"`
+	LineString    *struct{} `xml:"LineString"`
+	Polygon       *struct{} `xml:"Polygon"`
+	MultiGeometry *struct{} `xml:"MultiGeometry"`
+	ExtendedData  struct {
+		Data []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:"value"`
+		} `xml:"Data"`
+		SchemaData []struct {
+			SimpleData []struct {
+				Name  string `xml:"name,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"SimpleData"`
+		} `xml:"SchemaData"`
+	} `xml:"ExtendedData"`
+}
+
+func (kr *kmlRowReader) Next() (map[string]interface{}, error) {
+	//เดินหา <Placemark> ถัดไป ไม่สนว่าอยู่ใน Folder ชั้นไหน
+	for {
+		tok, err := kr.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "Placemark" {
+			continue
+		}
+
+		var pm kmlPlacemark
+		if err := kr.dec.DecodeElement(&pm, &start); err != nil {
+			return nil, err
+		}
+		return pm.toRow()
+	}
+}
+
+func (kr *kmlRowReader) Close() error {
+	var err error
+	if kr.closer != nil {
+		err = kr.closer.Close()
+	}
+	if kr.archive != nil {
+		if cerr := kr.archive.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// toRow แปลง Placemark เป็นแถวข้อมูล Placemark ที่ไม่ใช่ Point จะคืน RowError
+func (pm *kmlPlacemark) toRow() (map[string]interface{}, error) {
+	if pm.Point == nil {
+		geometry := ""
+		switch {
+		case pm.LineString != nil:
+			geometry = "LineString"
+		case pm.Polygon != nil:
+			geometry = "Polygon"
+		case pm.MultiGeometry != nil:
+			geometry = "MultiGeometry"
+		}
+		if geometry == "" {
+			return nil, &RowError{Column: "Point", Value: pm.Name, Reason: "placemark has no Point"}
+		}
+		return nil, &RowError{Column: "Point", Value: geometry, Reason: "geometry type must be Point"}
+	}
+
+	//coordinates เป็น "long,lat[,alt]" ถ้ามีหลายชุดใช้ชุดแรก
+	coords := strings.Fields(pm.Point.Coordinates)
+	var parts []string
+	if len(coords) > 0 {
+		parts = strings.Split(coords[0], ",")
+	}
+	if len(parts) < 2 {
+		return nil, &RowError{Column: "coordinates", Value: pm.Point.Coordinates, Reason: "must be long,lat"}
+	}
+	long, errLong := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lat, errLat := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if errLong != nil || errLat != nil {
+		return nil, &RowError{Column: "coordinates", Value: pm.Point.Coordinates, Reason: "must be long,lat"}
+	}
+
+	m := make(map[string]interface{})
+	set := func(name, value string) {
+		if _, ok := models.FieldTypes[name]; ok {
+			m[name] = strings.TrimSpace(value)
+		}
+	}
+	for _, d := range pm.ExtendedData.Data {
+		set(d.Name, d.Value)
+	}
+	for _, sd := range pm.ExtendedData.SchemaData {
+		for _, d := range sd.SimpleData {
+			set(d.Name, d.Value)
+		}
+	}
+	//ถ้า ExtendedData ไม่มี name ให้ใช้ <name> ของ Placemark แทน
+	if _, ok := m["name"]; !ok && strings.TrimSpace(pm.Name) != "" {
+		m["name"] = strings.TrimSpace(pm.Name)
+	}
+	m["long"] = long
+	m["lat"] = lat
+	return m, nil
+}
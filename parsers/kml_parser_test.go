@@ -0,0 +1,180 @@
+package parsers
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildZip สร้าง zip จากชื่อไฟล์และเนื้อหา ตามลำดับใน names
+func buildZip(t *testing.T, names []string, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// kmlDoc สร้างเอกสาร KML จาก Placemark ที่ส่งมา
+func kmlDoc(placemarks ...string) string {
+	doc := `<?xml version="1.0" encoding="UTF-8"?><kml xmlns="http://www.opengis.net/kml/2.2"><Document>`
+	for _, pm := range placemarks {
+		doc += pm
+	}
+	return doc + `</Document></kml>`
+}
+
+func kmlPoint(code, name, coords string) string {
+	return `<Placemark><name>` + name + `</name><ExtendedData><Data name="station_code"><value>` + code +
+		`</value></Data></ExtendedData><Point><coordinates>` + coords + `</coordinates></Point></Placemark>`
+}
+
+func TestKMZOpenFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   []string
+		files   map[string]string
+		codes   []string
+		wantErr bool
+	}{
+		{
+			name:  "doc.kml wins over other kml files",
+			order: []string{"extra.kml", "doc.kml"},
+			files: map[string]string{
+				"extra.kml": kmlDoc(kmlPoint("X9", "Other", "100.5,13.7")),
+				"doc.kml":   kmlDoc(kmlPoint("A1", "Alpha", "100.5,13.7"), kmlPoint("B2", "Beta", "100.6,13.8")),
+			},
+			codes: []string{"A1", "B2"},
+		},
+		{
+			name:  "first kml file when there is no doc.kml",
+			order: []string{"images/icon.png", "files/stations.KML"},
+			files: map[string]string{
+				"images/icon.png":    "png",
+				"files/stations.KML": kmlDoc(kmlPoint("C3", "Gamma", "100.5,13.7")),
+			},
+			codes: []string{"C3"},
+		},
+		{
+			name:    "no kml file",
+			order:   []string{"readme.txt"},
+			files:   map[string]string{"readme.txt": "hello"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "stations.kmz")
+		if err := os.WriteFile(path, buildZip(t, tt.order, tt.files), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		rr, err := (&KMZParser{}).OpenFile(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: OpenFile error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var codes []string
+		for _, m := range readAllRows(t, rr) {
+			code, _ := m["station_code"].(string)
+			codes = append(codes, code)
+		}
+		if !reflect.DeepEqual(codes, tt.codes) {
+			t.Errorf("%s: codes = %v, want %v", tt.name, codes, tt.codes)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "broken.kmz")
+	if err := os.WriteFile(path, []byte("not a zip"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&KMZParser{}).OpenFile(path); err == nil {
+		t.Error("OpenFile on a file that is not a zip: want error")
+	}
+}
+
+func TestKMLRowReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		rows    []map[string]interface{}
+		reasons []string
+	}{
+		{
+			name:  "extended data and nested folders",
+			input: kmlDoc(`<Folder><Folder>` + kmlPoint("1001", "Alpha", " 100.5,13.7,0 ") + `</Folder></Folder>`),
+			rows:  []map[string]interface{}{{"station_code": "1001", "name": "Alpha", "long": 100.5, "lat": 13.7}},
+		},
+		{
+			name: "schema data and name from ExtendedData",
+			input: kmlDoc(`<Placemark><name>Label</name><ExtendedData><SchemaData schemaUrl="#s">` +
+				`<SimpleData name="station_code">1002</SimpleData><SimpleData name="name"> Beta </SimpleData><SimpleData name="color">red</SimpleData>` +
+				`</SchemaData></ExtendedData><Point><coordinates>100.6,13.8 101,14</coordinates></Point></Placemark>`),
+			rows: []map[string]interface{}{{"station_code": "1002", "name": "Beta", "long": 100.6, "lat": 13.8}},
+		},
+		{
+			name: "non-point placemarks are rejected",
+			input: kmlDoc(`<Placemark><name>Line</name><LineString><coordinates>100,13 101,14</coordinates></LineString></Placemark>`,
+				`<Placemark><name>Area</name><Polygon/></Placemark>`,
+				`<Placemark><name>Nothing</name></Placemark>`,
+				`<Placemark><Point><coordinates>100.5</coordinates></Point></Placemark>`,
+				`<Placemark><Point><coordinates>abc,13</coordinates></Point></Placemark>`,
+				kmlPoint("1003", "Gamma", "100.7,13.9")),
+			rows:    []map[string]interface{}{{"station_code": "1003", "name": "Gamma", "long": 100.7, "lat": 13.9}},
+			reasons: []string{"geometry type must be Point", "geometry type must be Point", "placemark has no Point", "must be long,lat", "must be long,lat"},
+		},
+		{
+			name:  "no placemarks",
+			input: kmlDoc(),
+		},
+	}
+	for _, tt := range tests {
+		rr, err := (&KMLParser{}).NewRowReader(strings.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("%s: NewRowReader: %v", tt.name, err)
+			continue
+		}
+		rows, rowErrs := readRowsAndErrors(t, rr)
+		if len(rows) != len(tt.rows) || (len(rows) > 0 && !reflect.DeepEqual(rows, tt.rows)) {
+			t.Errorf("%s: rows = %v, want %v", tt.name, rows, tt.rows)
+		}
+		var reasons []string
+		for _, e := range rowErrs {
+			reasons = append(reasons, e.Reason)
+		}
+		if !reflect.DeepEqual(reasons, tt.reasons) {
+			t.Errorf("%s: row errors = %v, want %v", tt.name, reasons, tt.reasons)
+		}
+	}
+}
+
+func TestKMZNewRowReader(t *testing.T) {
+	data := buildZip(t, []string{"doc.kml"}, map[string]string{"doc.kml": kmlDoc(kmlPoint("A1", "Alpha", "100.5,13.7"))})
+	rr, err := (&KMZParser{}).NewRowReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := readAllRows(t, rr); len(rows) != 1 || rows[0]["station_code"] != "A1" {
+		t.Errorf("rows = %v, want station A1", rows)
+	}
+
+	if _, err := (&KMZParser{}).NewRowReader(strings.NewReader("<kml/>")); err == nil || !strings.Contains(err.Error(), "invalid KMZ") {
+		t.Errorf("NewRowReader on plain KML: err = %v, want invalid KMZ", err)
+	}
+}
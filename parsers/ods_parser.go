@@ -0,0 +1,220 @@
+package parsers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Teneieiza/go-spinsolf-test/utils"
+)
+
+// namespace ของ OpenDocument ที่ใช้อ่าน content.xml
+const (
+	odsTableNS  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsOfficeNS = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	odsTextNS   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+)
+
+// maxODSRepeat จำนวนสูงสุดที่ยอมให้ cell/แถวที่มีค่าซ้ำ (number-*-repeated) ขยายออก
+// ไฟล์ ods มักมี cell ว่างซ้ำถึงท้าย sheet (เช่น 1024 column, 1048576 แถว) ซึ่งไม่ต้องขยาย
+const maxODSRepeat = 10000
+
+// ODSParser อ่านไฟล์ OpenDocument spreadsheet (.ods) ได้แถวแบบเดียวกับ XLSXParser
+// รวมถึงการหา header การเลือก sheet และ SheetKey
+type ODSParser struct {
+	Headers *utils.HeaderResolver
+	// Sheet ชื่อหรือลำดับ (เริ่มที่ 0) ของ sheet ที่จะอ่าน ว่างคือ sheet แรก
+	Sheet string
+	// AllSheets อ่านทุก sheet ต่อกันตามลำดับ แต่ละ sheet หา header ของตัวเอง
+	AllSheets bool
+}
+
+func (p *ODSParser) Parse(data []byte, target interface{}) error {
+	return collectRows(p, data, target)
+}
+
+// NewRowReader อ่านไฟล์ zip เข้า memory แล้วอ่าน content.xml ทุก sheet ก่อนอ่านทีละแถว
+func (p *ODSParser) NewRowReader(r io.Reader) (RowReader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.New("invalid ods file: " + err.Error())
+	}
+
+	for _, f := range zr.File {
+		if f.Name != "content.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		sheets, err := readODSSheets(rc)
+		if err != nil {
+			return nil, errors.New("invalid ods file: " + err.Error())
+		}
+		return newMemSheetRowReader(sheets, p.Sheet, p.AllSheets, p.Headers)
+	}
+	return nil, errors.New("invalid ods file: content.xml not found")
+}
+
+// readODSSheets อ่าน table:table ทุกตัวใน content.xml เป็น memSheet
+// ค่าของ cell ตัวเลข/วันที่/boolean ใช้ค่าจริงใน attribute (office:value ฯลฯ) ไม่ใช่ข้อความที่จัดรูปแบบแล้ว
+func readODSSheets(r io.Reader) ([]memSheet, error) {
+	dec := xml.NewDecoder(r)
+
+	var (
+		sheets    []memSheet
+		sheet     *memSheet
+		row       []string
+		rowRepeat int
+		emptyRows int // แถวว่างที่ยังไม่ได้ใส่ จะใส่เมื่อเจอแถวที่มีข้อมูลต่อจากนั้น
+		cell      strings.Builder
+		cellValue string // ค่าจาก attribute ถ้ามี
+		cellRep   int
+		emptyCols int // cell ว่างที่ยังไม่ได้ใส่ จะใส่เมื่อเจอ cell ที่มีข้อมูลต่อจากนั้น
+		inCell    bool
+		paragraph int // จำนวน text:p ใน cell ปัจจุบัน
+	)
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == odsTableNS && t.Name.Local == "table":
+				sheets = append(sheets, memSheet{name: odsAttr(t, odsTableNS, "name")})
+				sheet = &sheets[len(sheets)-1]
+				emptyRows = 0
+			case sheet == nil:
+				continue
+			case t.Name.Space == odsTableNS && t.Name.Local == "table-row":
+				row, emptyCols = nil, 0
+				rowRepeat = odsRepeat(t, "number-rows-repeated")
+			case t.Name.Space == odsTableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				inCell, paragraph = true, 0
+				cell.Reset()
+				cellValue = odsCellValue(t)
+				cellRep = odsRepeat(t, "number-columns-repeated")
+			case inCell && t.Name.Space == odsTextNS:
+				switch t.Name.Local {
+				case "p":
+					if paragraph > 0 {
+						cell.WriteByte('\n')
+					}
+					paragraph++
+				case "s":
+					cell.WriteString(strings.Repeat(" ", odsRepeatAttr(t, odsTextNS, "c")))
+				case "tab":
+					cell.WriteByte('\t')
+				case "line-break":
+					cell.WriteByte('\n')
+				}
+			}
+
+		case xml.CharData:
+			if inCell {
+				cell.Write(t)
+			}
+
+		case xml.EndElement:
+			if t.Name.Space != odsTableNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "table-cell", "covered-table-cell":
+				if !inCell {
+					continue
+				}
+				inCell = false
+				value := cellValue
+				if value == "" {
+					value = cell.String()
+				}
+				value = strings.TrimSpace(value)
+				if value == "" {
+					emptyCols += cellRep
+					continue
+				}
+				for ; emptyCols > 0; emptyCols-- {
+					row = append(row, "")
+				}
+				for i := 0; i < min(cellRep, maxODSRepeat); i++ {
+					row = append(row, value)
+				}
+			case "table-row":
+				if sheet == nil {
+					continue
+				}
+				if len(row) == 0 {
+					emptyRows += rowRepeat
+					continue
+				}
+				for i := 0; i < min(emptyRows, maxODSRepeat); i++ {
+					sheet.rows = append(sheet.rows, nil)
+				}
+				emptyRows = 0
+				for i := 0; i < min(rowRepeat, maxODSRepeat); i++ {
+					sheet.rows = append(sheet.rows, row)
+				}
+				row = nil
+			case "table":
+				sheet = nil
+			}
+		}
+	}
+	return sheets, nil
+}
+
+// odsCellValue ค่าของ cell จาก attribute ตามชนิด (office:value-type) คืน "" ถ้าเป็นข้อความ
+func odsCellValue(t xml.StartElement) string {
+	switch odsAttr(t, odsOfficeNS, "value-type") {
+	case "float", "percentage", "currency":
+		return odsAttr(t, odsOfficeNS, "value")
+	case "date":
+		return odsAttr(t, odsOfficeNS, "date-value")
+	case "time":
+		return odsAttr(t, odsOfficeNS, "time-value")
+	case "boolean":
+		return odsAttr(t, odsOfficeNS, "boolean-value")
+	}
+	return ""
+}
+
+// odsRepeat อ่านจำนวนซ้ำของแถว/cell ถ้าไม่มีหรือไม่ถูกต้องคือ 1
+func odsRepeat(t xml.StartElement, name string) int {
+	return odsRepeatAttr(t, odsTableNS, name)
+}
+
+func odsRepeatAttr(t xml.StartElement, space, name string) int {
+	n, err := strconv.Atoi(odsAttr(t, space, name))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+func odsAttr(t xml.StartElement, space, name string) string {
+	for _, a := range t.Attr {
+		if a.Name.Space == space && a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package parsers
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// odsContent สร้าง content.xml จาก table:table ที่ส่งมา
+func odsContent(tables ...string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"` +
+		` xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"` +
+		` xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">` +
+		`<office:body><office:spreadsheet>` + strings.Join(tables, "") +
+		`</office:spreadsheet></office:body></office:document-content>`
+}
+
+func odsTable(name string, rows ...string) string {
+	return `<table:table table:name="` + name + `">` + strings.Join(rows, "") + `</table:table>`
+}
+
+func TestReadODSSheets(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []string
+		want [][]string
+	}{
+		{
+			name: "text cells",
+			rows: []string{`<table:table-row><table:table-cell><text:p>A1</text:p></table:table-cell><table:table-cell><text:p>Alpha</text:p></table:table-cell></table:table-row>`},
+			want: [][]string{{"A1", "Alpha"}},
+		},
+		{
+			name: "typed values win over formatted text",
+			rows: []string{`<table:table-row>` +
+				`<table:table-cell office:value-type="float" office:value="13.7456"><text:p>13.75</text:p></table:table-cell>` +
+				`<table:table-cell office:value-type="percentage" office:value="0.5"><text:p>50%</text:p></table:table-cell>` +
+				`<table:table-cell office:value-type="date" office:date-value="2024-01-31"><text:p>31/01/2024</text:p></table:table-cell>` +
+				`<table:table-cell office:value-type="boolean" office:boolean-value="true"><text:p>TRUE</text:p></table:table-cell>` +
+				`<table:table-cell office:value-type="string"><text:p>text</text:p></table:table-cell>` +
+				`</table:table-row>`},
+			want: [][]string{{"13.7456", "0.5", "2024-01-31", "true", "text"}},
+		},
+		{
+			name: "spaces, tabs and paragraphs inside a cell",
+			rows: []string{`<table:table-row><table:table-cell><text:p>Bang<text:s text:c="2"/>Sue<text:tab/>Grand</text:p><text:p>Line 2</text:p></table:table-cell></table:table-row>`},
+			want: [][]string{{"Bang  Sue\tGrand\nLine 2"}},
+		},
+		{
+			name: "repeated cells and rows expand",
+			rows: []string{
+				`<table:table-row table:number-rows-repeated="2"><table:table-cell table:number-columns-repeated="3"><text:p>x</text:p></table:table-cell></table:table-row>`,
+			},
+			want: [][]string{{"x", "x", "x"}, {"x", "x", "x"}},
+		},
+		{
+			name: "empty cells and rows between data are kept, trailing ones are dropped",
+			rows: []string{
+				`<table:table-row><table:table-cell table:number-columns-repeated="2"/><table:table-cell><text:p>c</text:p></table:table-cell><table:table-cell table:number-columns-repeated="1021"/></table:table-row>`,
+				`<table:table-row table:number-rows-repeated="2"><table:table-cell table:number-columns-repeated="1024"/></table:table-row>`,
+				`<table:table-row><table:table-cell><text:p>d</text:p></table:table-cell></table:table-row>`,
+				`<table:table-row table:number-rows-repeated="1048572"><table:table-cell table:number-columns-repeated="1024"/></table:table-row>`,
+			},
+			want: [][]string{{"", "", "c"}, nil, nil, {"d"}},
+		},
+		{
+			name: "repeat is capped",
+			rows: []string{`<table:table-row table:number-rows-repeated="20000"><table:table-cell><text:p>y</text:p></table:table-cell></table:table-row>`},
+			want: func() [][]string {
+				rows := make([][]string, maxODSRepeat)
+				for i := range rows {
+					rows[i] = []string{"y"}
+				}
+				return rows
+			}(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sheets, err := readODSSheets(strings.NewReader(odsContent(odsTable("Stations", tt.rows...))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sheets) != 1 || sheets[0].name != "Stations" {
+				t.Fatalf("sheets = %+v, want one sheet named Stations", sheets)
+			}
+			if !reflect.DeepEqual(sheets[0].rows, tt.want) {
+				t.Errorf("rows = %q, want %q", sheets[0].rows, tt.want)
+			}
+		})
+	}
+}
+
+func TestODSRowReader(t *testing.T) {
+	row := func(cells ...string) string {
+		s := `<table:table-row>`
+		for _, c := range cells {
+			s += `<table:table-cell><text:p>` + c + `</text:p></table:table-cell>`
+		}
+		return s + `</table:table-row>`
+	}
+	content := odsContent(
+		odsTable("Stations", row("Station list"), row("station_code", "name"), row("A1", "Alpha")),
+		odsTable("Depots", row("station_code", "name"), row("B2", "Beta")),
+	)
+	data := buildZip(t, []string{"mimetype", "content.xml"}, map[string]string{
+		"mimetype":    "application/vnd.oasis.opendocument.spreadsheet",
+		"content.xml": content,
+	})
+
+	tests := []struct {
+		name  string
+		sheet string
+		all   bool
+		want  []string
+	}{
+		{name: "first sheet", want: []string{"Stations:A1"}},
+		{name: "sheet by name", sheet: "Depots", want: []string{"Depots:B2"}},
+		{name: "all sheets", all: true, want: []string{"Stations:A1", "Depots:B2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ODSParser{Headers: testResolver(), Sheet: tt.sheet, AllSheets: tt.all}
+			rr, err := p.NewRowReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range readAllRows(t, rr) {
+				sheet, _ := m[SheetKey].(string)
+				code, _ := m["station_code"].(string)
+				got = append(got, sheet+":"+code)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestODSRowReaderRejectsFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{name: "not a zip", data: []byte("station_code,name\n"), want: "invalid ods file"},
+		{
+			name: "zip without content.xml",
+			data: buildZip(t, []string{"mimetype"}, map[string]string{"mimetype": "application/vnd.oasis.opendocument.spreadsheet"}),
+			want: "content.xml not found",
+		},
+		{
+			name: "broken content.xml",
+			data: buildZip(t, []string{"content.xml"}, map[string]string{"content.xml": "<office:document-content><table:table"}),
+			want: "invalid ods file",
+		},
+		{
+			name: "unknown sheet",
+			data: buildZip(t, []string{"content.xml"}, map[string]string{"content.xml": odsContent(odsTable("Stations"))}),
+			want: "Missing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&ODSParser{Headers: testResolver(), Sheet: "Missing"}).NewRowReader(bytes.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewRowReader error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package parsers
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/Teneieiza/go-spinsolf-test/utils"
+)
+
+// readAllRows อ่านทุกแถวจาก RowReader จนหมด ถ้ามี error ที่ไม่ใช่ io.EOF ให้ test ล้ม
+func readAllRows(t *testing.T, rr RowReader) []map[string]interface{} {
+	t.Helper()
+	defer rr.Close()
+
+	var rows []map[string]interface{}
+	for {
+		m, err := rr.Next()
+		if err == io.EOF {
+			return rows
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		rows = append(rows, m)
+	}
+}
+
+// readRowsAndErrors อ่านทุกแถวจาก RowReader เก็บ RowError ของแถวที่ถูกปฏิเสธแยกไว้ error อื่นให้ test ล้ม
+func readRowsAndErrors(t *testing.T, rr RowReader) ([]map[string]interface{}, []*RowError) {
+	t.Helper()
+	defer rr.Close()
+
+	var rows []map[string]interface{}
+	var rowErrs []*RowError
+	for {
+		m, err := rr.Next()
+		if err == io.EOF {
+			return rows, rowErrs
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			rowErrs = append(rowErrs, rowErr)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		rows = append(rows, m)
+	}
+}
+
+// rowNums คืนเลขแถวใน RowKey ของแต่ละแถว
+func rowNums(rows []map[string]interface{}) []int {
+	nums := make([]int, len(rows))
+	for i, m := range rows {
+		nums[i], _ = m[RowKey].(int)
+	}
+	return nums
+}
+
+// testResolver resolver ที่ไม่มี column ของ profile ใช้ชื่อ column มาตรฐานอย่างเดียว
+func testResolver() *utils.HeaderResolver {
+	return utils.NewHeaderResolver(nil)
+}
@@ -0,0 +1,177 @@
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Teneieiza/go-spinsolf-test/dto"
+	"github.com/Teneieiza/go-spinsolf-test/utils"
+)
+
+// SheetKey key ที่ใส่ไว้ในแถวจากไฟล์ spreadsheet เพื่อบอกว่ามาจาก sheet ไหน (ไม่ใช่ field ของสถานี)
+const SheetKey = "_sheet"
+
+// SheetReporter RowReader ที่อ่านได้หลาย sheet บอกสรุปของแต่ละ sheet ที่อ่านไปแล้ว
+type SheetReporter interface {
+	Sheets() []dto.SheetSummary
+}
+
+// selectSheets เลือก sheet ที่จะอ่านจากรายชื่อ sheet ในไฟล์ตาม sheet และ all
+// sheet เป็นชื่อ (ไม่สนตัวพิมพ์) หรือลำดับเริ่มที่ 0 ว่างคือ sheet แรก
+func selectSheets(list []string, sheet string, all bool) ([]string, error) {
+	if len(list) == 0 {
+		return nil, errors.New("file has no sheets")
+	}
+	if all {
+		return list, nil
+	}
+	if sheet == "" {
+		return list[:1], nil
+	}
+
+	//หาจากชื่อก่อน (ไม่สนตัวพิมพ์) ถ้าไม่เจอค่อยดูว่าเป็นลำดับหรือไม่
+	for _, name := range list {
+		if strings.EqualFold(name, strings.TrimSpace(sheet)) {
+			return []string{name}, nil
+		}
+	}
+	if i, err := strconv.Atoi(strings.TrimSpace(sheet)); err == nil && i >= 0 && i < len(list) {
+		return []string{list[i]}, nil
+	}
+	return nil, fmt.Errorf("sheet %q not found (sheets: %s)", sheet, strings.Join(list, ", "))
+}
+
+// memSheet sheet ที่อ่านค่าทุก cell เข้า memory แล้ว ใช้กับรูปแบบที่ไม่มี row iterator (xls, ods)
+type memSheet struct {
+	name string
+	rows [][]string
+}
+
+// trimEmptyRows ตัดแถวว่างท้าย sheet ออก (sheet ที่ไม่มีข้อมูลจะเหลือ 0 แถว)
+func trimEmptyRows(rows [][]string) [][]string {
+	for len(rows) > 0 && isEmptyRow(rows[len(rows)-1]) {
+		rows = rows[:len(rows)-1]
+	}
+	return rows
+}
+
+func isEmptyRow(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// memSheetRowReader อ่านแถวจาก memSheet ทีละแถว ทำงานเหมือน xlsxRowReader
+// แต่ละ sheet หา header ของตัวเอง sheet ที่ว่างจะถูกข้ามแต่ยังอยู่ในสรุป
+type memSheetRowReader struct {
+	sheets     []memSheet
+	current    int // ลำดับใน sheets ของ sheet ที่กำลังอ่าน
+	pos        int // แถวถัดไปที่จะอ่านใน sheet ปัจจุบัน
+	resolver   *utils.HeaderResolver
+	headers    []string
+	pending    []sourceRow // แถวข้อมูลที่อ่านมาแล้วตอนหา header
+	summary    []dto.SheetSummary
+	headerInfo // header ของ sheet แรกที่มีข้อมูล
+}
+
+// newMemSheetRowReader เลือก sheet ตาม sheet/all แล้วเปิด sheet แรกที่มีข้อมูล
+func newMemSheetRowReader(all []memSheet, sheet string, allSheets bool, resolver *utils.HeaderResolver) (*memSheetRowReader, error) {
+	names := make([]string, len(all))
+	byName := make(map[string]memSheet, len(all))
+	for i, s := range all {
+		names[i] = s.name
+		byName[s.name] = s
+	}
+	selected, err := selectSheets(names, sheet, allSheets)
+	if err != nil {
+		return nil, err
+	}
+
+	mr := &memSheetRowReader{resolver: resolver}
+	for _, name := range selected {
+		mr.sheets = append(mr.sheets, byName[name])
+	}
+	if err := mr.openSheet(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return mr, nil
+}
+
+// openSheet หาแถว header ของ sheet ที่ current ข้าม sheet ที่ว่าง คืน io.EOF เมื่อไม่มี sheet เหลือแล้ว
+func (mr *memSheetRowReader) openSheet() error {
+	for ; mr.current < len(mr.sheets); mr.current++ {
+		name := mr.sheets[mr.current].name
+		mr.pos = 0
+
+		headers, pending, info, err := detectHeader(mr.nextRow, mr.resolver)
+		if err == io.EOF {
+			mr.summary = append(mr.summary, dto.SheetSummary{Name: name})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		mr.headers, mr.pending = headers, pending
+		if mr.headerInfo.row == 0 {
+			mr.headerInfo = info
+		}
+		mr.summary = append(mr.summary, dto.SheetSummary{Name: name, HeaderRow: info.row, Headers: info.headers})
+		return nil
+	}
+	return io.EOF
+}
+
+// nextRow คืนแถวถัดไปใน sheet ปัจจุบันโดยข้ามแถวว่าง คืน io.EOF เมื่อหมด sheet
+func (mr *memSheetRowReader) nextRow() (sourceRow, error) {
+	rows := mr.sheets[mr.current].rows
+	for mr.pos < len(rows) {
+		mr.pos++
+		if !isEmptyRow(rows[mr.pos-1]) {
+			return sourceRow{cells: rows[mr.pos-1], num: mr.pos}, nil
+		}
+	}
+	return sourceRow{}, io.EOF
+}
+
+func (mr *memSheetRowReader) Next() (map[string]interface{}, error) {
+	for mr.current < len(mr.sheets) {
+		var row sourceRow
+		if len(mr.pending) > 0 {
+			row = mr.pending[0]
+			mr.pending = mr.pending[1:]
+		} else {
+			var err error
+			row, err = mr.nextRow()
+			if err == io.EOF {
+				//หมด sheet นี้แล้ว ไปอ่าน sheet ถัดไป
+				mr.current++
+				if err := mr.openSheet(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+
+		m := rowToMap(mr.headers, row)
+		m[SheetKey] = mr.sheets[mr.current].name
+		mr.summary[len(mr.summary)-1].Rows++
+		return m, nil
+	}
+	return nil, io.EOF
+}
+
+func (mr *memSheetRowReader) Sheets() []dto.SheetSummary {
+	return mr.summary
+}
+
+func (mr *memSheetRowReader) Close() error {
+	mr.sheets = nil
+	return nil
+}
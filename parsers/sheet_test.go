@@ -0,0 +1,76 @@
+package parsers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMemSheetRowReaderSkipsBlankRows(t *testing.T) {
+	sheets := []memSheet{
+		{name: "First", rows: [][]string{
+			{"", ""},
+			{"station_code", "name"},
+			{"A1", "Alpha"},
+			{" ", ""},
+			{},
+			{"B2", "Beta"},
+		}},
+		{name: "Empty", rows: [][]string{{""}, {"", " "}}},
+		{name: "Last", rows: [][]string{
+			{"station_code", "name"},
+			{"", ""},
+			{"C3", ""},
+		}},
+	}
+
+	mr, err := newMemSheetRowReader(sheets, "", true, testResolver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := readAllRows(t, mr)
+
+	var codes []string
+	for _, m := range rows {
+		sheet, _ := m[SheetKey].(string)
+		code, _ := m["station_code"].(string)
+		codes = append(codes, sheet+":"+code)
+	}
+	if want := []string{"First:A1", "First:B2", "Last:C3"}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("codes = %v, want %v", codes, want)
+	}
+	if got, want := rowNums(rows), []int{3, 6, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+
+	summary := mr.Sheets()
+	if len(summary) != 3 || summary[1].HeaderRow != 0 || summary[1].Rows != 0 {
+		t.Errorf("sheets = %+v, want the blank sheet listed with no header and no rows", summary)
+	}
+}
+
+func TestSelectSheets(t *testing.T) {
+	list := []string{"Stations", "Depots", "Notes"}
+	tests := []struct {
+		sheet   string
+		all     bool
+		want    []string
+		wantErr bool
+	}{
+		{"", false, []string{"Stations"}, false},
+		{"", true, list, false},
+		{"depots", false, []string{"Depots"}, false},
+		{" 2 ", false, []string{"Notes"}, false},
+		{"3", false, nil, true},
+		{"missing", false, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := selectSheets(list, tt.sheet, tt.all)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("selectSheets(%q, %v) error = %v, wantErr %v", tt.sheet, tt.all, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("selectSheets(%q, %v) = %v, want %v", tt.sheet, tt.all, got, tt.want)
+		}
+	}
+}
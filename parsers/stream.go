@@ -0,0 +1,105 @@
+package parsers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// RowReader อ่านข้อมูลทีละแถว คืน io.EOF เมื่ออ่านครบแล้ว
+// ใช้กับไฟล์ใหญ่ที่ไม่ควรโหลดทั้งไฟล์เข้า memory
+type RowReader interface {
+	Next() (map[string]interface{}, error)
+	Close() error
+}
+
+// RowKey key ที่ใส่ไว้ในแถวเพื่อบอกเลขแถวในไฟล์หรือ sheet ที่มา (เริ่มที่ 1 นับแถว header และแถวว่างด้วย) ไม่ใช่ field ของสถานี
+// มีเฉพาะรูปแบบที่เป็นตาราง (csv, GTFS, xlsx, xls, ods) รูปแบบอื่นไม่มีเลขแถวในไฟล์
+const RowKey = "_row"
+
+// StreamParser parser ที่อ่านจาก io.Reader ได้เลยโดยไม่ต้องโหลดข้อมูลทั้งหมดก่อน
+type StreamParser interface {
+	NewRowReader(r io.Reader) (RowReader, error)
+}
+
+// FileParser parser ที่เปิดไฟล์บน disk เองได้ ใช้กับรูปแบบที่เป็น zip ซึ่งต้องอ่านแบบ random access
+// อ่านจากไฟล์ตรงๆ จึงไม่ต้องโหลดทั้งไฟล์เข้า memory แบบ NewRowReader
+type FileParser interface {
+	OpenFile(path string) (RowReader, error)
+}
+
+// OpenRows เปิด RowReader จาก parser ที่ส่งมา
+// ถ้า parser รองรับ StreamParser จะอ่านแบบ stream ถ้าไม่รองรับจะอ่านทั้งหมดแล้ว Parse ตามปกติ
+func OpenRows(p Parser, r io.Reader) (RowReader, error) {
+	if sp, ok := p.(StreamParser); ok {
+		return sp.NewRowReader(r)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]interface{}
+	if err := p.Parse(data, &rows); err != nil {
+		return nil, err
+	}
+	return NewSliceRowReader(rows), nil
+}
+
+// sliceRowReader RowReader ของข้อมูลที่อยู่ใน memory แล้ว
+type sliceRowReader struct {
+	rows []map[string]interface{}
+	pos  int
+}
+
+// NewSliceRowReader สร้าง RowReader จาก slice ที่ parse มาแล้ว
+func NewSliceRowReader(rows []map[string]interface{}) RowReader {
+	return &sliceRowReader{rows: rows}
+}
+
+func (r *sliceRowReader) Next() (map[string]interface{}, error) {
+	if r.pos >= len(r.rows) {
+		return nil, io.EOF
+	}
+	r.pos++
+	return r.rows[r.pos-1], nil
+}
+
+func (r *sliceRowReader) Close() error {
+	return nil
+}
+
+// RowError error ของแถวเดียวที่ parser อ่านได้แต่ใช้ไม่ได้ (เช่น geometry ผิดประเภท)
+// Next คืน RowError เมื่อแถวนั้นต้องถูกปฏิเสธ แต่ยังอ่านแถวถัดไปต่อได้
+type RowError struct {
+	Column string
+	Value  interface{}
+	Reason string
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Column, e.Reason)
+}
+
+// collectRows อ่านทุกแถวจาก StreamParser ใส่ target สำหรับ Parse แบบเดิม
+// แถวที่ใช้ไม่ได้จะคืน error พร้อมลำดับของแถว
+func collectRows(p StreamParser, data []byte, target interface{}) error {
+	slice := target.(*[]map[string]interface{})
+
+	rows, err := p.NewRowReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for n := 1; ; n++ {
+		m, err := rows.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("row %d: %w", n, err)
+		}
+		*slice = append(*slice, m)
+	}
+}
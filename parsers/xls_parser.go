@@ -0,0 +1,73 @@
+package parsers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Teneieiza/go-spinsolf-test/utils"
+	"github.com/shakinm/xlsReader/xls"
+)
+
+// XLSParser อ่านไฟล์ Excel รุ่นเก่า (.xls, BIFF8) ได้แถวแบบเดียวกับ XLSXParser
+// รวมถึงการหา header การเลือก sheet และ SheetKey
+type XLSParser struct {
+	Headers *utils.HeaderResolver
+	// Sheet ชื่อหรือลำดับ (เริ่มที่ 0) ของ sheet ที่จะอ่าน ว่างคือ sheet แรก
+	Sheet string
+	// AllSheets อ่านทุก sheet ต่อกันตามลำดับ แต่ละ sheet หา header ของตัวเอง
+	AllSheets bool
+}
+
+func (p *XLSParser) Parse(data []byte, target interface{}) error {
+	return collectRows(p, data, target)
+}
+
+// NewRowReader อ่านไฟล์ทั้งไฟล์เข้า memory (xls เป็นไฟล์ OLE ต้อง random access)
+// แล้วแปลงทุก cell เป็น string ก่อนอ่านทีละแถว
+func (p *XLSParser) NewRowReader(r io.Reader) (RowReader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	sheets, err := readXLSSheets(data)
+	if err != nil {
+		return nil, err
+	}
+	return newMemSheetRowReader(sheets, p.Sheet, p.AllSheets, p.Headers)
+}
+
+// readXLSSheets อ่านทุก sheet ของไฟล์ xls
+// library อาจ panic กับไฟล์ที่เสีย จึงแปลง panic เป็น error
+func readXLSSheets(data []byte) (sheets []memSheet, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			sheets, err = nil, fmt.Errorf("invalid xls file: %v", r)
+		}
+	}()
+
+	wb, err := xls.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid xls file: %w", err)
+	}
+
+	for i := 0; i < wb.GetNumberSheets(); i++ {
+		sheet, err := wb.GetSheet(i)
+		if err != nil {
+			return nil, err
+		}
+		s := memSheet{name: sheet.GetName()}
+		for _, row := range sheet.GetRows() {
+			cols := row.GetCols()
+			values := make([]string, len(cols))
+			for j, c := range cols {
+				values[j] = strings.TrimSpace(c.GetString())
+			}
+			s.rows = append(s.rows, values)
+		}
+		s.rows = trimEmptyRows(s.rows)
+		sheets = append(sheets, s)
+	}
+	return sheets, nil
+}
@@ -0,0 +1,28 @@
+package parsers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestXLSRowReaderRejectsFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty file", data: nil},
+		{name: "csv text", data: []byte("station_code,name\nA1,Alpha\n")},
+		{name: "zip file", data: buildZip(t, []string{"content.xml"}, map[string]string{"content.xml": "<x/>"})},
+		// OLE header ที่ถูกต้องแต่ข้อมูลหลังจากนั้นขาด library อาจ panic ต้องได้ error แทน
+		{name: "truncated ole file", data: append([]byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1"), make([]byte, 64)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&XLSParser{Headers: testResolver()}).NewRowReader(bytes.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), "invalid xls file") {
+				t.Errorf("NewRowReader error = %v, want invalid xls file", err)
+			}
+		})
+	}
+}
@@ -1,51 +1,170 @@
 package parsers
 
 import (
-	"bytes"
-	"errors"
-	"reflect"
-	"strings"
+	"io"
 
+	"github.com/Teneieiza/go-spinsolf-test/dto"
+	"github.com/Teneieiza/go-spinsolf-test/utils"
 	"github.com/xuri/excelize/v2"
 )
 
-type XLSXParser struct{}
+// XLSXParser อ่านไฟล์ xlsx
+// ถ้าตั้ง Headers จะหาแถว header เองและจับคู่ชื่อ column กับ field ด้วย resolver
+// ทุกแถวจะมี SheetKey บอกชื่อ sheet ที่มา
+type XLSXParser struct {
+	Headers *utils.HeaderResolver
+	// Sheet ชื่อหรือลำดับ (เริ่มที่ 0) ของ sheet ที่จะอ่าน ว่างคือ sheet แรก
+	Sheet string
+	// AllSheets อ่านทุก sheet ต่อกันตามลำดับ แต่ละ sheet หา header ของตัวเอง
+	AllSheets bool
+}
 
 func (p *XLSXParser) Parse(data []byte, target interface{}) error {
-    f, err := excelize.OpenReader(bytes.NewReader(data))
-    if err != nil {
-        return err
-    }
-    defer f.Close()
-
-    sheet := f.GetSheetName(0)
-    rows, err := f.GetRows(sheet)
-    if err != nil {
-        return err
-    }
-    if len(rows) < 1 {
-        return nil
-    }
-
-    headers := rows[0]
-
-    // ตรวจสอบว่า target เป็น *[]map[string]interface{}
-    sliceVal := reflect.ValueOf(target)
-    if sliceVal.Kind() != reflect.Ptr || sliceVal.Elem().Kind() != reflect.Slice {
-        return errors.New("target must be a pointer to a slice")
-    }
-    sliceVal = sliceVal.Elem()
-
-    for _, row := range rows[1:] {
-        m := make(map[string]interface{})
-        for i, h := range headers {
-            if i >= len(row) {
-                continue
-            }
-            m[h] = strings.TrimSpace(row[i])
-        }
-        sliceVal.Set(reflect.Append(sliceVal, reflect.ValueOf(m)))
-    }
-    return nil
+	return collectRows(p, data, target)
+}
+
+// NewRowReader อ่าน xlsx ทีละแถวด้วย row iterator ของ excelize
+// ไฟล์ zip ยังต้องอ่านเข้า memory (excelize ต้องการ random access) แต่ sheet จะถูกอ่านทีละแถว
+// ไม่สร้าง [][]string ของทั้ง sheet แบบ GetRows ถ้ามีไฟล์บน disk ให้ใช้ OpenFile แทน
+func (p *XLSXParser) NewRowReader(r io.Reader) (RowReader, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return p.newRowReader(f)
+}
+
+// OpenFile อ่าน xlsx จากไฟล์บน disk excelize อ่าน zip จากไฟล์ตรงๆ ไม่โหลดทั้งไฟล์เข้า memory
+func (p *XLSXParser) OpenFile(path string) (RowReader, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.newRowReader(f)
+}
+
+// newRowReader เลือก sheet แล้วเปิด sheet แรกที่มีข้อมูล ปิด f ให้ถ้าเปิดไม่สำเร็จ
+func (p *XLSXParser) newRowReader(f *excelize.File) (RowReader, error) {
+	sheets, err := selectSheets(f.GetSheetList(), p.Sheet, p.AllSheets)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	xr := &xlsxRowReader{file: f, sheets: sheets, resolver: p.Headers}
+	if err := xr.openSheet(); err != nil && err != io.EOF {
+		xr.Close()
+		return nil, err
+	}
+	return xr, nil
+}
+
+type xlsxRowReader struct {
+	file       *excelize.File
+	sheets     []string
+	current    int // ลำดับใน sheets ของ sheet ที่กำลังอ่าน
+	rows       *excelize.Rows
+	resolver   *utils.HeaderResolver
+	headers    []string
+	pending    []sourceRow // แถวข้อมูลที่อ่านมาแล้วตอนหา header
+	rowNum     int         // เลขแถวที่อ่านล่าสุดใน sheet ปัจจุบัน
+	summary    []dto.SheetSummary
+	headerInfo // header ของ sheet แรกที่มีข้อมูล
+}
+
+// openSheet เปิด sheet ที่ current แล้วหาแถว header ข้าม sheet ที่ว่าง
+// คืน io.EOF เมื่อไม่มี sheet เหลือแล้ว
+func (xr *xlsxRowReader) openSheet() error {
+	for ; xr.current < len(xr.sheets); xr.current++ {
+		name := xr.sheets[xr.current]
+		rows, err := xr.file.Rows(name)
+		if err != nil {
+			return err
+		}
+		xr.rows = rows
+		xr.rowNum = 0
+
+		headers, pending, info, err := detectHeader(xr.nextRow, xr.resolver)
+		if err == io.EOF {
+			rows.Close()
+			xr.rows = nil
+			xr.summary = append(xr.summary, dto.SheetSummary{Name: name})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		xr.headers, xr.pending = headers, pending
+		if xr.headerInfo.row == 0 {
+			xr.headerInfo = info
+		}
+		xr.summary = append(xr.summary, dto.SheetSummary{Name: name, HeaderRow: info.row, Headers: info.headers})
+		return nil
+	}
+	return io.EOF
 }
 
+// nextRow อ่าน column ของแถวถัดไปใน sheet ปัจจุบัน คืน io.EOF เมื่อหมด sheet
+// excelize คืนแถวว่างให้ด้วยแม้ไม่มีอยู่ในไฟล์ เลขแถวจึงนับต่อกันได้
+// แถวที่ไม่มีค่าเลย (แถวว่างหรือแถวที่มีแค่ format) จะถูกข้ามไป
+func (xr *xlsxRowReader) nextRow() (sourceRow, error) {
+	for xr.rows.Next() {
+		cells, err := xr.rows.Columns()
+		if err != nil {
+			return sourceRow{}, err
+		}
+		xr.rowNum++
+		if isEmptyRow(cells) {
+			continue
+		}
+		return sourceRow{cells: cells, num: xr.rowNum}, nil
+	}
+	if err := xr.rows.Error(); err != nil {
+		return sourceRow{}, err
+	}
+	return sourceRow{}, io.EOF
+}
+
+func (xr *xlsxRowReader) Next() (map[string]interface{}, error) {
+	for xr.rows != nil {
+		var row sourceRow
+		if len(xr.pending) > 0 {
+			row = xr.pending[0]
+			xr.pending = xr.pending[1:]
+		} else {
+			var err error
+			row, err = xr.nextRow()
+			if err == io.EOF {
+				//หมด sheet นี้แล้ว ไปอ่าน sheet ถัดไป
+				xr.rows.Close()
+				xr.rows = nil
+				xr.current++
+				if err := xr.openSheet(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		m := rowToMap(xr.headers, row)
+		m[SheetKey] = xr.sheets[xr.current]
+		xr.summary[len(xr.summary)-1].Rows++
+		return m, nil
+	}
+	return nil, io.EOF
+}
+
+func (xr *xlsxRowReader) Sheets() []dto.SheetSummary {
+	return xr.summary
+}
+
+func (xr *xlsxRowReader) Close() error {
+	if xr.rows != nil {
+		xr.rows.Close()
+	}
+	return xr.file.Close()
+}
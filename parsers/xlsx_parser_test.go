@@ -0,0 +1,140 @@
+package parsers
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// buildXLSX สร้างไฟล์ xlsx จากค่าของแต่ละ sheet (cell ว่างไม่เขียนลงไฟล์)
+// styled คือช่วง cell ที่ใส่แค่ format ไม่มีค่า ต่อ sheet
+func buildXLSX(t *testing.T, sheets map[string][][]string, order []string, styled map[string][2]string) []byte {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+
+	style, err := f.NewStyle(&excelize.Style{Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FFFF00"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range order {
+		if i == 0 {
+			if err := f.SetSheetName("Sheet1", name); err != nil {
+				t.Fatal(err)
+			}
+		} else if _, err := f.NewSheet(name); err != nil {
+			t.Fatal(err)
+		}
+		for r, row := range sheets[name] {
+			for c, v := range row {
+				if v == "" {
+					continue
+				}
+				cell, _ := excelize.CoordinatesToCellName(c+1, r+1)
+				if err := f.SetCellValue(name, cell, v); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if rng, ok := styled[name]; ok {
+			if err := f.SetCellStyle(name, rng[0], rng[1], style); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestXLSXRowReaderSkipsBlankRows(t *testing.T) {
+	sheets := map[string][][]string{
+		"Main": {
+			{"Station list"},
+			{},
+			{"station_code", "name"},
+			{"A1", "Alpha"},
+			{},
+			{"", ""},
+			{"B2", "Beta"},
+		},
+		"Extra": {
+			{"station_code", "name"},
+			{"C3", "Gamma"},
+		},
+	}
+	//แถว 6-8 ของ Main และแถว 3-5 ของ Extra มีแค่สีพื้นหลัง ไม่มีค่า
+	styled := map[string][2]string{
+		"Main":  {"A5", "B9"},
+		"Extra": {"A3", "D5"},
+	}
+	data := buildXLSX(t, sheets, []string{"Main", "Extra"}, styled)
+
+	p := &XLSXParser{Headers: testResolver(), AllSheets: true}
+	rr, err := p.NewRowReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := readAllRows(t, rr)
+
+	type got struct {
+		sheet, code string
+		row         int
+	}
+	var all []got
+	for _, m := range rows {
+		sheet, _ := m[SheetKey].(string)
+		code, _ := m["station_code"].(string)
+		row, _ := m[RowKey].(int)
+		all = append(all, got{sheet, code, row})
+	}
+	want := []got{{"Main", "A1", 4}, {"Main", "B2", 7}, {"Extra", "C3", 2}}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("rows = %v, want %v", all, want)
+	}
+
+	summary := rr.(SheetReporter).Sheets()
+	if len(summary) != 2 || summary[0].Rows != 2 || summary[0].HeaderRow != 3 || summary[1].Rows != 1 {
+		t.Errorf("sheets = %+v, want Main 2 rows header 3, Extra 1 row", summary)
+	}
+}
+
+func TestXLSXOpenFileMatchesNewRowReader(t *testing.T) {
+	sheets := map[string][][]string{
+		"Stations": {
+			{"station_code", "name", "lat"},
+			{"A1", "Alpha", "13.7"},
+			{"B2", "Beta", "13.8"},
+		},
+	}
+	data := buildXLSX(t, sheets, []string{"Stations"}, nil)
+	path := filepath.Join(t.TempDir(), "stations.xlsx")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &XLSXParser{Headers: testResolver()}
+	fromFile, err := p.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromReader, err := p.NewRowReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, want := readAllRows(t, fromFile), readAllRows(t, fromReader)
+	if len(got) != 2 || !reflect.DeepEqual(got, want) {
+		t.Errorf("OpenFile rows = %v, want %v", got, want)
+	}
+
+	if _, err := p.OpenFile(filepath.Join(t.TempDir(), "missing.xlsx")); err == nil {
+		t.Error("OpenFile on a missing file: want error")
+	}
+}
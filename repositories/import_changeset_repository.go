@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrChangesetNotFound ใช้เมื่อหา changeset ตาม id หรือ job id ไม่เจอ
+var ErrChangesetNotFound = errors.New("import changeset not found")
+
+// ImportChangesetRepository ที่เก็บประวัติการ import (changeset) และค่าก่อน/หลังของแต่ละสถานี
+// มีทั้งแบบ MongoDB และแบบ in-memory เหมือน StationRepository
+type ImportChangesetRepository interface {
+	// List ดึง changeset เรียงจากใหม่ไปเก่า ไม่เกิน limit รายการ
+	List(ctx context.Context, limit int) ([]models.ImportChangeset, error)
+	// Get ดึง changeset ตาม id ถ้าไม่เจอคืน ErrChangesetNotFound
+	Get(ctx context.Context, id primitive.ObjectID) (*models.ImportChangeset, error)
+	// GetByJob ดึง changeset ของ import job ถ้าไม่เจอคืน ErrChangesetNotFound
+	GetByJob(ctx context.Context, jobID string) (*models.ImportChangeset, error)
+	// Insert เพิ่ม changeset ใหม่ (สร้าง id ให้ถ้ายังไม่มี)
+	Insert(ctx context.Context, cs *models.ImportChangeset) error
+	// Replace แทนที่ข้อมูลของ changeset ถ้าไม่เจอคืน ErrChangesetNotFound
+	Replace(ctx context.Context, cs *models.ImportChangeset) error
+	// AddChanges บันทึกค่าก่อน/หลังของสถานีหลายรายการ
+	AddChanges(ctx context.Context, changes []models.StationChange) error
+	// ListChanges ดึงรายการสถานีที่เปลี่ยนใน changeset เรียงตาม seq
+	ListChanges(ctx context.Context, changesetID primitive.ObjectID, skip, limit int) ([]models.StationChange, error)
+	// EnsureIndexes สร้าง index ที่จำเป็น
+	EnsureIndexes(ctx context.Context) error
+}
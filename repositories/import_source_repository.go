@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrImportSourceNotFound ใช้เมื่อหา import source ตาม id ไม่เจอ
+var ErrImportSourceNotFound = errors.New("import source not found")
+
+// ImportSourceRepository ที่เก็บ import source และประวัติการรัน
+// มีทั้งแบบ MongoDB และแบบ in-memory เหมือน StationRepository
+type ImportSourceRepository interface {
+	// List ดึง source ทั้งหมด เรียงตามชื่อ
+	List(ctx context.Context) ([]models.ImportSource, error)
+	// Get ดึง source ตาม id ถ้าไม่เจอคืน ErrImportSourceNotFound
+	Get(ctx context.Context, id primitive.ObjectID) (*models.ImportSource, error)
+	// Insert เพิ่ม source ใหม่ (สร้าง id ให้ถ้ายังไม่มี)
+	Insert(ctx context.Context, src *models.ImportSource) error
+	// Replace แทนที่ข้อมูลทั้งหมดของ source ถ้าไม่เจอคืน ErrImportSourceNotFound
+	Replace(ctx context.Context, src *models.ImportSource) error
+	// Delete ลบ source พร้อมประวัติการรัน ถ้าไม่เจอคืน ErrImportSourceNotFound
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// AddRun บันทึกประวัติการรัน 1 ครั้ง
+	AddRun(ctx context.Context, run *models.ImportSourceRun) error
+	// ListRuns ดึงประวัติการรันของ source เรียงจากใหม่ไปเก่า ไม่เกิน limit รายการ
+	ListRuns(ctx context.Context, sourceID primitive.ObjectID, limit int) ([]models.ImportSourceRun, error)
+}
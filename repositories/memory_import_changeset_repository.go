@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryImportChangesetRepository เก็บ changeset ไว้ใน memory ใช้คู่กับ MemoryStationRepository
+type MemoryImportChangesetRepository struct {
+	mu         sync.RWMutex
+	changesets map[primitive.ObjectID]models.ImportChangeset
+	changes    map[primitive.ObjectID][]models.StationChange // เรียงตามลำดับที่เพิ่ม
+}
+
+func NewMemoryImportChangesetRepository() *MemoryImportChangesetRepository {
+	return &MemoryImportChangesetRepository{
+		changesets: make(map[primitive.ObjectID]models.ImportChangeset),
+		changes:    make(map[primitive.ObjectID][]models.StationChange),
+	}
+}
+
+func (r *MemoryImportChangesetRepository) List(ctx context.Context, limit int) ([]models.ImportChangeset, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]models.ImportChangeset, 0, len(r.changesets))
+	for _, cs := range r.changesets {
+		list = append(list, cs)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (r *MemoryImportChangesetRepository) Get(ctx context.Context, id primitive.ObjectID) (*models.ImportChangeset, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cs, ok := r.changesets[id]
+	if !ok {
+		return nil, ErrChangesetNotFound
+	}
+	return &cs, nil
+}
+
+func (r *MemoryImportChangesetRepository) GetByJob(ctx context.Context, jobID string) (*models.ImportChangeset, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, cs := range r.changesets {
+		if cs.JobID != "" && cs.JobID == jobID {
+			return &cs, nil
+		}
+	}
+	return nil, ErrChangesetNotFound
+}
+
+func (r *MemoryImportChangesetRepository) Insert(ctx context.Context, cs *models.ImportChangeset) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cs.ID.IsZero() {
+		cs.ID = primitive.NewObjectID()
+	}
+	r.changesets[cs.ID] = *cs
+	return nil
+}
+
+func (r *MemoryImportChangesetRepository) Replace(ctx context.Context, cs *models.ImportChangeset) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.changesets[cs.ID]; !ok {
+		return ErrChangesetNotFound
+	}
+	r.changesets[cs.ID] = *cs
+	return nil
+}
+
+func (r *MemoryImportChangesetRepository) AddChanges(ctx context.Context, changes []models.StationChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range changes {
+		if c.ID.IsZero() {
+			c.ID = primitive.NewObjectID()
+		}
+		r.changes[c.ChangesetID] = append(r.changes[c.ChangesetID], c)
+	}
+	return nil
+}
+
+func (r *MemoryImportChangesetRepository) ListChanges(ctx context.Context, changesetID primitive.ObjectID, skip, limit int) ([]models.StationChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := r.changes[changesetID]
+	list := []models.StationChange{}
+	for i := skip; i < len(all) && len(list) < limit; i++ {
+		list = append(list, all[i])
+	}
+	return list, nil
+}
+
+func (r *MemoryImportChangesetRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
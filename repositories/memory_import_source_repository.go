@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxMemoryRunsPerSource จำนวนประวัติการรันที่เก็บไว้ต่อ source (แบบ in-memory)
+const maxMemoryRunsPerSource = 100
+
+// MemoryImportSourceRepository เก็บ import source ไว้ใน memory ใช้คู่กับ MemoryStationRepository
+type MemoryImportSourceRepository struct {
+	mu      sync.RWMutex
+	sources map[primitive.ObjectID]models.ImportSource
+	runs    map[primitive.ObjectID][]models.ImportSourceRun // เรียงจากเก่าไปใหม่
+}
+
+func NewMemoryImportSourceRepository() *MemoryImportSourceRepository {
+	return &MemoryImportSourceRepository{
+		sources: make(map[primitive.ObjectID]models.ImportSource),
+		runs:    make(map[primitive.ObjectID][]models.ImportSourceRun),
+	}
+}
+
+func (r *MemoryImportSourceRepository) List(ctx context.Context) ([]models.ImportSource, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]models.ImportSource, 0, len(r.sources))
+	for _, src := range r.sources {
+		list = append(list, copySource(src))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (r *MemoryImportSourceRepository) Get(ctx context.Context, id primitive.ObjectID) (*models.ImportSource, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	src, ok := r.sources[id]
+	if !ok {
+		return nil, ErrImportSourceNotFound
+	}
+	src = copySource(src)
+	return &src, nil
+}
+
+func (r *MemoryImportSourceRepository) Insert(ctx context.Context, src *models.ImportSource) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if src.ID.IsZero() {
+		src.ID = primitive.NewObjectID()
+	}
+	r.sources[src.ID] = copySource(*src)
+	return nil
+}
+
+func (r *MemoryImportSourceRepository) Replace(ctx context.Context, src *models.ImportSource) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sources[src.ID]; !ok {
+		return ErrImportSourceNotFound
+	}
+	r.sources[src.ID] = copySource(*src)
+	return nil
+}
+
+func (r *MemoryImportSourceRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sources[id]; !ok {
+		return ErrImportSourceNotFound
+	}
+	delete(r.sources, id)
+	delete(r.runs, id)
+	return nil
+}
+
+func (r *MemoryImportSourceRepository) AddRun(ctx context.Context, run *models.ImportSourceRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if run.ID.IsZero() {
+		run.ID = primitive.NewObjectID()
+	}
+	runs := append(r.runs[run.SourceID], *run)
+	if len(runs) > maxMemoryRunsPerSource {
+		runs = runs[len(runs)-maxMemoryRunsPerSource:]
+	}
+	r.runs[run.SourceID] = runs
+	return nil
+}
+
+func (r *MemoryImportSourceRepository) ListRuns(ctx context.Context, sourceID primitive.ObjectID, limit int) ([]models.ImportSourceRun, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	runs := r.runs[sourceID]
+	list := make([]models.ImportSourceRun, 0, min(len(runs), limit))
+	for i := len(runs) - 1; i >= 0 && len(list) < limit; i-- {
+		list = append(list, runs[i])
+	}
+	return list, nil
+}
+
+// copySource คัดลอก source รวมถึง field ที่เป็น pointer กันไม่ให้คนเรียกแก้ข้อมูลใน memory โดยตรง
+func copySource(src models.ImportSource) models.ImportSource {
+	if src.Auth != nil {
+		auth := *src.Auth
+		src.Auth = &auth
+	}
+	if src.LastRunAt != nil {
+		t := *src.LastRunAt
+		src.LastRunAt = &t
+	}
+	if src.NextRunAt != nil {
+		t := *src.NextRunAt
+		src.NextRunAt = &t
+	}
+	return src
+}
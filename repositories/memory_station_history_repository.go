@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryStationHistoryRepository เก็บประวัติของสถานีไว้ใน memory ใช้คู่กับ MemoryStationRepository
+type MemoryStationHistoryRepository struct {
+	mu       sync.RWMutex
+	versions []models.StationVersion // เรียงตามลำดับที่เพิ่ม
+}
+
+func NewMemoryStationHistoryRepository() *MemoryStationHistoryRepository {
+	return &MemoryStationHistoryRepository{}
+}
+
+func (r *MemoryStationHistoryRepository) Append(ctx context.Context, versions []models.StationVersion) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, v := range versions {
+		if v.ID.IsZero() {
+			v.ID = primitive.NewObjectID()
+		}
+		r.versions = append(r.versions, v)
+	}
+	return nil
+}
+
+func (r *MemoryStationHistoryRepository) ListByStation(ctx context.Context, code int, skip, limit int) ([]models.StationVersion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := []models.StationVersion{}
+	for i := len(r.versions) - 1; i >= 0 && len(list) < limit; i-- {
+		if r.versions[i].StationCode != code {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		list = append(list, r.versions[i])
+	}
+	return list, nil
+}
+
+func (r *MemoryStationHistoryRepository) CountByStation(ctx context.Context, code int) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var n int64
+	for _, v := range r.versions {
+		if v.StationCode == code {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *MemoryStationHistoryRepository) ForEachSince(ctx context.Context, since time.Time, fn func(v models.StationVersion) error) error {
+	//copy รายการออกมาก่อน fn จะได้ไม่ทำงานระหว่างถือ lock
+	r.mu.RLock()
+	var list []models.StationVersion
+	for i := len(r.versions) - 1; i >= 0; i-- {
+		if r.versions[i].ChangedAt.After(since) {
+			list = append(list, r.versions[i])
+		}
+	}
+	r.mu.RUnlock()
+
+	for _, v := range list {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemoryStationHistoryRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
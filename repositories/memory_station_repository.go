@@ -0,0 +1,679 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"github.com/Teneieiza/go-spinsolf-test/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MemoryStationRepository เก็บข้อมูลสถานีไว้ใน memory
+// ทำงานเหมือน MongoStationRepository (รวมถึง geo query) แต่ไม่ต้องมี MongoDB
+// ใช้สำหรับเทสและ demo ข้อมูลจะหายเมื่อปิดโปรแกรม
+type MemoryStationRepository struct {
+	mu         sync.RWMutex
+	docs       map[primitive.ObjectID]bson.M
+	order      []primitive.ObjectID // ลำดับการ insert เพื่อให้ผลลัพธ์คงที่
+	uniqueKeys []string             // field ที่ต้องไม่ซ้ำ เหมือน unique index ของ mongo
+}
+
+func NewMemoryStationRepository() *MemoryStationRepository {
+	return &MemoryStationRepository{
+		docs: make(map[primitive.ObjectID]bson.M),
+	}
+}
+
+func (r *MemoryStationRepository) FindNearby(ctx context.Context, lat, long float64, skip, limit int) ([]models.Station, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	type candidate struct {
+		station  models.Station
+		distance float64
+	}
+
+	//เลียนแบบ $near คือเอาเฉพาะ active ที่มี location แล้วเรียงตามระยะทาง
+	var candidates []candidate
+	for _, id := range r.order {
+		doc := r.docs[id]
+		if !valueEquals(doc["active"], 1) || IsDeletedDocument(doc) {
+			continue
+		}
+		st, err := decodeStation(doc)
+		if err != nil {
+			return nil, err
+		}
+		pLong, pLat, ok := pointOf(st.Location)
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			station:  st,
+			distance: utils.Haversine(lat, long, pLat, pLong),
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	stations := make([]models.Station, 0, limit)
+	for i := skip; i < len(candidates) && len(stations) < limit; i++ {
+		stations = append(stations, candidates[i].station)
+	}
+	return stations, nil
+}
+
+func (r *MemoryStationRepository) CountActive(ctx context.Context) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var total int64
+	for _, doc := range r.docs {
+		if valueEquals(doc["active"], 1) && !IsDeletedDocument(doc) {
+			total++
+		}
+	}
+	return total, nil
+}
+
+func (r *MemoryStationRepository) FindByCode(ctx context.Context, code int) (*models.Station, error) {
+	return r.findByCode(code, false)
+}
+
+func (r *MemoryStationRepository) FindByText(ctx context.Context, terms []string) ([]models.Station, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stations := []models.Station{}
+	for _, id := range r.order {
+		doc := r.docs[id]
+		if IsDeletedDocument(doc) || !matchesText(doc, terms) {
+			continue
+		}
+		st, err := decodeStation(doc)
+		if err != nil {
+			return nil, err
+		}
+		stations = append(stations, st)
+	}
+	return stations, nil
+}
+
+func (r *MemoryStationRepository) FindByFuzzyPrefix(ctx context.Context, namePrefix, romanPrefix string) ([]models.Station, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hasPrefix := func(doc bson.M, field, prefix string) bool {
+		s, ok := doc[field].(string)
+		return prefix != "" && ok && strings.HasPrefix(s, prefix)
+	}
+
+	stations := []models.Station{}
+	for _, id := range r.order {
+		doc := r.docs[id]
+		if IsDeletedDocument(doc) {
+			continue
+		}
+		if !hasPrefix(doc, "fuzzy_name", namePrefix) && !hasPrefix(doc, "fuzzy_en_name", romanPrefix) && !hasPrefix(doc, "fuzzy_roman", romanPrefix) {
+			continue
+		}
+		st, err := decodeStation(doc)
+		if err != nil {
+			return nil, err
+		}
+		stations = append(stations, st)
+	}
+	return stations, nil
+}
+
+func (r *MemoryStationRepository) FindDeletedByCode(ctx context.Context, code int) (*models.Station, error) {
+	return r.findByCode(code, true)
+}
+
+// findByCode หาสถานีตาม station_code ที่สถานะการลบตรงกับ deleted
+func (r *MemoryStationRepository) findByCode(code int, deleted bool) (*models.Station, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.findID(map[string]interface{}{"station_code": code})
+	if !ok || IsDeletedDocument(r.docs[id]) != deleted {
+		return nil, ErrStationNotFound
+	}
+	st, err := decodeStation(r.docs[id])
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (r *MemoryStationRepository) FindByCodes(ctx context.Context, codes []int) ([]models.Station, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(codes))
+	for _, c := range codes {
+		wanted[valueKey(c)] = true
+	}
+
+	var stations []models.Station
+	for _, id := range r.order {
+		doc := r.docs[id]
+		if !wanted[valueKey(doc["station_code"])] {
+			continue
+		}
+		st, err := decodeStation(doc)
+		if err != nil {
+			return nil, err
+		}
+		stations = append(stations, st)
+	}
+	return stations, nil
+}
+
+func (r *MemoryStationRepository) InsertStation(ctx context.Context, st *models.Station) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.findID(map[string]interface{}{"station_code": st.StationCode}); ok {
+		return ErrStationExists
+	}
+
+	if st.ID.IsZero() {
+		st.ID = primitive.NewObjectID()
+	}
+	doc, err := encodeStation(st)
+	if err != nil {
+		return err
+	}
+	if r.violatesUnique(doc, primitive.NilObjectID) {
+		return ErrStationExists
+	}
+	r.docs[st.ID] = doc
+	r.order = append(r.order, st.ID)
+	return nil
+}
+
+func (r *MemoryStationRepository) ReplaceByCode(ctx context.Context, code int, st *models.Station) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.findID(map[string]interface{}{"station_code": code})
+	if !ok || IsDeletedDocument(r.docs[id]) {
+		return ErrStationNotFound
+	}
+	//ถ้าเปลี่ยน station_code ต้องไม่ไปชนกับสถานีอื่น
+	if other, ok := r.findID(map[string]interface{}{"station_code": st.StationCode}); ok && other != id {
+		return ErrStationExists
+	}
+
+	//replace จะคง _id เดิมไว้เหมือน ReplaceOne ของ mongo
+	st.ID = id
+	doc, err := encodeStation(st)
+	if err != nil {
+		return err
+	}
+	if r.violatesUnique(doc, id) {
+		return ErrStationExists
+	}
+	r.docs[id] = doc
+	return nil
+}
+
+func (r *MemoryStationRepository) SoftDelete(ctx context.Context, code int, at time.Time, by string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.findID(map[string]interface{}{"station_code": code})
+	if !ok || IsDeletedDocument(r.docs[id]) {
+		return ErrStationNotFound
+	}
+	//เก็บเป็น DateTime แบบเดียวกับที่ mongo คืนมา
+	r.docs[id]["deleted_at"] = primitive.NewDateTimeFromTime(at)
+	r.docs[id]["deleted_by"] = by
+	return nil
+}
+
+func (r *MemoryStationRepository) Restore(ctx context.Context, code int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.findID(map[string]interface{}{"station_code": code})
+	if !ok || !IsDeletedDocument(r.docs[id]) {
+		return ErrStationNotFound
+	}
+	delete(r.docs[id], "deleted_at")
+	delete(r.docs[id], "deleted_by")
+	return nil
+}
+
+func (r *MemoryStationRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) ([]models.Station, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged []models.Station
+	for _, id := range append([]primitive.ObjectID(nil), r.order...) {
+		if len(purged) >= limit {
+			break
+		}
+		doc := r.docs[id]
+		at, ok := doc["deleted_at"].(primitive.DateTime)
+		if !ok || !at.Time().Before(before) {
+			continue
+		}
+		st, err := decodeStation(doc)
+		if err != nil {
+			return purged, err
+		}
+		r.deleteLocked(id)
+		purged = append(purged, st)
+	}
+	return purged, nil
+}
+
+func (r *MemoryStationRepository) BulkDelete(ctx context.Context, filters []map[string]interface{}) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for _, f := range filters {
+		if id, ok := r.findID(f); ok {
+			r.deleteLocked(id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// deleteLocked ลบ document ออกจาก docs และ order (ต้องถือ lock ก่อนเรียก)
+func (r *MemoryStationRepository) deleteLocked(id primitive.ObjectID) {
+	delete(r.docs, id)
+	for i, oid := range r.order {
+		if oid == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (r *MemoryStationRepository) ForEach(ctx context.Context, includeDeleted bool, fn func(st models.Station) error) error {
+	//decode ทั้งหมดไว้ก่อนแล้วค่อยเรียก fn นอก lock กัน fn ไปเรียก repository ซ้ำแล้ว deadlock
+	r.mu.RLock()
+	stations := make([]models.Station, 0, len(r.order))
+	for _, id := range r.order {
+		if !includeDeleted && IsDeletedDocument(r.docs[id]) {
+			continue
+		}
+		st, err := decodeStation(r.docs[id])
+		if err != nil {
+			r.mu.RUnlock()
+			return err
+		}
+		stations = append(stations, st)
+	}
+	r.mu.RUnlock()
+
+	sort.SliceStable(stations, func(i, j int) bool {
+		return stations[i].StationCode < stations[j].StationCode
+	})
+	for _, st := range stations {
+		if err := fn(st); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemoryStationRepository) FindDocument(ctx context.Context, filter map[string]interface{}) (map[string]interface{}, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.findID(filter)
+	if !ok {
+		return nil, nil
+	}
+	return copyDoc(r.docs[id]), nil
+}
+
+func (r *MemoryStationRepository) FindDocumentsIn(ctx context.Context, field string, values []interface{}) ([]map[string]interface{}, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(values))
+	for _, v := range values {
+		wanted[valueKey(v)] = true
+	}
+
+	var docs []map[string]interface{}
+	for _, id := range r.order {
+		doc := r.docs[id]
+		if wanted[valueKey(doc[field])] {
+			docs = append(docs, copyDoc(doc))
+		}
+	}
+	return docs, nil
+}
+
+func (r *MemoryStationRepository) BulkUpsert(ctx context.Context, upserts []StationUpsert) (*UpsertResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := &UpsertResult{}
+	//ทำ index ชั่วคราวตาม field ของ filter กันการวนหา document ทุกรอบ
+	indexes := make(map[string]map[string]primitive.ObjectID)
+	//index ของ uniqueKeys ใช้ตรวจค่าซ้ำแบบเดียวกับ Insert/Update รายการที่ซ้ำจะถูกข้าม รายการอื่นยังเขียนต่อ (unordered)
+	var unique map[string]primitive.ObjectID
+	if len(r.uniqueKeys) > 0 {
+		unique = r.buildIndex(r.uniqueKeys)
+	}
+	var writeErrs []mongo.BulkWriteError
+	for i, u := range upserts {
+		fields := filterFields(u.Filter)
+		sig := strings.Join(fields, ",")
+		index, ok := indexes[sig]
+		if !ok {
+			index = r.buildIndex(fields)
+			indexes[sig] = index
+		}
+		lookup := docKey(u.Filter, fields)
+
+		if id, ok := index[lookup]; ok {
+			doc := r.docs[id]
+			if unique != nil {
+				after := make(map[string]interface{}, len(r.uniqueKeys))
+				for _, k := range r.uniqueKeys {
+					if v, ok := u.Fields[k]; ok {
+						after[k] = v
+					} else {
+						after[k] = doc[k]
+					}
+				}
+				before, next := docKey(doc, r.uniqueKeys), docKey(after, r.uniqueKeys)
+				if other, taken := unique[next]; taken && other != id {
+					writeErrs = append(writeErrs, r.duplicateKeyError(i))
+					continue
+				}
+				if unique[before] == id {
+					delete(unique, before)
+				}
+				unique[next] = id
+			}
+			changed := false
+			for k, v := range u.Fields {
+				if old, exists := doc[k]; !exists || !valueEquals(old, v) {
+					changed = true
+				}
+				doc[k] = v
+			}
+			if changed {
+				res.Updated++
+			}
+			continue
+		}
+
+		//ไม่เจอ document เดิม ให้ insert ใหม่โดยเอา filter กับ fields มารวมกันแบบเดียวกับ upsert ของ mongo
+		id := primitive.NewObjectID()
+		doc := bson.M{"_id": id}
+		for k, v := range u.Filter {
+			doc[k] = v
+		}
+		for k, v := range u.Fields {
+			doc[k] = v
+		}
+		if unique != nil {
+			key := docKey(doc, r.uniqueKeys)
+			if _, taken := unique[key]; taken {
+				writeErrs = append(writeErrs, r.duplicateKeyError(i))
+				continue
+			}
+			unique[key] = id
+		}
+		r.docs[id] = doc
+		r.order = append(r.order, id)
+		res.Inserted++
+
+		//document ใหม่อาจไปตรงกับ index อื่นที่สร้างไว้แล้ว ให้เพิ่มเข้าไปด้วย
+		for sig, index := range indexes {
+			index[docKey(doc, strings.Split(sig, ","))] = id
+		}
+	}
+	//คืน error แบบเดียวกับ BulkWrite ของ mongo ที่ mongo.IsDuplicateKeyError ตรวจได้
+	if len(writeErrs) > 0 {
+		return nil, mongo.BulkWriteException{WriteErrors: writeErrs}
+	}
+	return res, nil
+}
+
+// duplicateKeyError error ของรายการที่ index ใน bulk write ที่ค่า uniqueKeys ซ้ำ (code 11000 เหมือน mongo)
+func (r *MemoryStationRepository) duplicateKeyError(index int) mongo.BulkWriteError {
+	return mongo.BulkWriteError{WriteError: mongo.WriteError{
+		Index:   index,
+		Code:    11000,
+		Message: fmt.Sprintf("E11000 duplicate key error: %s", strings.Join(r.uniqueKeys, ", ")),
+	}}
+}
+
+func (r *MemoryStationRepository) BulkUpdate(ctx context.Context, updates []StationUpsert) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modified := 0
+	for _, u := range updates {
+		id, ok := r.findID(u.Filter)
+		if !ok {
+			continue
+		}
+		doc := r.docs[id]
+		changed := false
+		for k, v := range u.Fields {
+			if old, exists := doc[k]; !exists || !valueEquals(old, v) {
+				changed = true
+			}
+			doc[k] = v
+		}
+		if changed {
+			modified++
+		}
+	}
+	return modified, nil
+}
+
+// buildIndex สร้าง map จากค่าของ fields ไปหา _id (ต้องถือ lock ก่อนเรียก)
+func (r *MemoryStationRepository) buildIndex(fields []string) map[string]primitive.ObjectID {
+	index := make(map[string]primitive.ObjectID, len(r.docs))
+	for _, id := range r.order {
+		key := docKey(r.docs[id], fields)
+		if _, exists := index[key]; !exists {
+			index[key] = id
+		}
+	}
+	return index
+}
+
+func (r *MemoryStationRepository) EnsureIndexes(ctx context.Context, keyFields []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.uniqueKeys = keyFields
+	return nil
+}
+
+// violatesUnique เช็คว่า doc มีค่า uniqueKeys ซ้ำกับ document อื่นที่ไม่ใช่ self หรือไม่ (ต้องถือ lock ก่อนเรียก)
+func (r *MemoryStationRepository) violatesUnique(doc bson.M, self primitive.ObjectID) bool {
+	if len(r.uniqueKeys) == 0 {
+		return false
+	}
+	filter := make(map[string]interface{}, len(r.uniqueKeys))
+	for _, k := range r.uniqueKeys {
+		filter[k] = doc[k]
+	}
+	for _, id := range r.order {
+		if id != self && matchesFilter(r.docs[id], filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// findID หา _id ของ document แรกที่ตรงกับ filter (ต้องถือ lock ก่อนเรียก)
+func (r *MemoryStationRepository) findID(filter map[string]interface{}) (primitive.ObjectID, bool) {
+	for _, id := range r.order {
+		if matchesFilter(r.docs[id], filter) {
+			return id, true
+		}
+	}
+	return primitive.NilObjectID, false
+}
+
+// matchesFilter เช็คว่า document ตรงกับ filter แบบเท่ากับทุก field
+func matchesFilter(doc bson.M, filter map[string]interface{}) bool {
+	for k, v := range filter {
+		if !valueEquals(doc[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesText เช็คว่าทุก term อยู่ใน models.SearchFields อย่างน้อย 1 field แบบเดียวกับ regex "i" ของ mongo
+func matchesText(doc bson.M, terms []string) bool {
+	for _, term := range terms {
+		term = strings.ToLower(term)
+		found := false
+		for _, f := range models.SearchFields {
+			if s, ok := doc[f].(string); ok && strings.Contains(strings.ToLower(s), term) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// valueEquals เทียบค่าโดยมองตัวเลขทุกชนิดเป็นค่าเดียวกัน (int, int32, float64 ...)
+func valueEquals(a, b interface{}) bool {
+	fa, aNum := toFloat(a)
+	fb, bNum := toFloat(b)
+	if aNum && bNum {
+		return math.Abs(fa-fb) < 1e-9
+	}
+	if aNum != bNum {
+		return false
+	}
+	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
+
+// valueKey แปลงค่าเป็น string สำหรับใช้เป็น key ของ map โดยตัวเลขทุกชนิดที่ค่าเท่ากันได้ key เดียวกัน
+func valueKey(v interface{}) string {
+	if f, ok := toFloat(v); ok {
+		return "n:" + strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return "s:" + fmt.Sprintf("%v", v)
+}
+
+// filterFields คืนชื่อ field ของ filter เรียงตามตัวอักษร
+func filterFields(filter map[string]interface{}) []string {
+	fields := make([]string, 0, len(filter))
+	for k := range filter {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// docKey รวมค่าของ fields ใน doc เป็น key เดียว
+func docKey(doc map[string]interface{}, fields []string) string {
+	parts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		parts = append(parts, valueKey(doc[f]))
+	}
+	return strings.Join(parts, "|")
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// pointOf ดึง long lat ออกจาก GeoJSON Point
+func pointOf(location map[string]interface{}) (long, lat float64, ok bool) {
+	if location == nil {
+		return 0, 0, false
+	}
+	var coords []interface{}
+	switch c := location["coordinates"].(type) {
+	case []float64:
+		if len(c) < 2 {
+			return 0, 0, false
+		}
+		return c[0], c[1], true
+	case primitive.A:
+		coords = c
+	case []interface{}:
+		coords = c
+	default:
+		return 0, 0, false
+	}
+	if len(coords) < 2 {
+		return 0, 0, false
+	}
+	long, okLong := toFloat(coords[0])
+	lat, okLat := toFloat(coords[1])
+	return long, lat, okLong && okLat
+}
+
+// decodeStation แปลง document เป็น Station ผ่าน bson เหมือนตอน decode จาก mongo
+func decodeStation(doc bson.M) (models.Station, error) {
+	var st models.Station
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return st, err
+	}
+	err = bson.Unmarshal(raw, &st)
+	return st, err
+}
+
+// encodeStation แปลง Station เป็น document แบบเดียวกับที่ mongo เก็บ
+func encodeStation(st *models.Station) (bson.M, error) {
+	raw, err := bson.Marshal(st)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	err = bson.Unmarshal(raw, &doc)
+	return doc, err
+}
+
+// copyDoc คัดลอก document ชั้นบนสุด กันคนเรียกไปแก้ข้อมูลใน memory ตรงๆ
+func copyDoc(doc bson.M) map[string]interface{} {
+	out := make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestMemoryBulkUpsertRejectsDuplicateUniqueKeys(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryStationRepository()
+	//key ที่ใช้ import คือ station_code แต่ id ก็ต้องไม่ซ้ำ
+	if err := r.EnsureIndexes(ctx, []string{"id"}); err != nil {
+		t.Fatal(err)
+	}
+	seed := []StationUpsert{
+		{Filter: map[string]interface{}{"station_code": 1001}, Fields: map[string]interface{}{"id": 11, "name": "กรุงเทพ"}},
+		{Filter: map[string]interface{}{"station_code": 1002}, Fields: map[string]interface{}{"id": 12, "name": "สามเสน"}},
+	}
+	if _, err := r.BulkUpsert(ctx, seed); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		upserts   []StationUpsert
+		wantIndex []int
+	}{
+		{
+			name: "insert with an id that is taken",
+			upserts: []StationUpsert{
+				{Filter: map[string]interface{}{"station_code": 1003}, Fields: map[string]interface{}{"id": 11}},
+				{Filter: map[string]interface{}{"station_code": 1004}, Fields: map[string]interface{}{"id": 14}},
+			},
+			wantIndex: []int{0},
+		},
+		{
+			name: "update to an id that is taken",
+			upserts: []StationUpsert{
+				{Filter: map[string]interface{}{"station_code": 1001}, Fields: map[string]interface{}{"name": "กรุงเทพใหม่"}},
+				{Filter: map[string]interface{}{"station_code": 1002}, Fields: map[string]interface{}{"id": 11}},
+			},
+			wantIndex: []int{1},
+		},
+		{
+			name: "two new rows with the same id in one batch",
+			upserts: []StationUpsert{
+				{Filter: map[string]interface{}{"station_code": 1005}, Fields: map[string]interface{}{"id": 15}},
+				{Filter: map[string]interface{}{"station_code": 1006}, Fields: map[string]interface{}{"id": 15}},
+			},
+			wantIndex: []int{1},
+		},
+	}
+	for _, tt := range tests {
+		_, err := r.BulkUpsert(ctx, tt.upserts)
+		if !mongo.IsDuplicateKeyError(err) {
+			t.Errorf("%s: err = %v, want duplicate key error", tt.name, err)
+			continue
+		}
+		var bwe mongo.BulkWriteException
+		if !errors.As(err, &bwe) || len(bwe.WriteErrors) != len(tt.wantIndex) {
+			t.Errorf("%s: err = %#v, want %d write errors", tt.name, err, len(tt.wantIndex))
+			continue
+		}
+		for i, we := range bwe.WriteErrors {
+			if we.Index != tt.wantIndex[i] {
+				t.Errorf("%s: write error %d index = %d, want %d", tt.name, i, we.Index, tt.wantIndex[i])
+			}
+		}
+	}
+
+	//รายการที่ไม่ซ้ำในแต่ละครั้งยังถูกเขียน (unordered) ส่วนที่ซ้ำไม่ถูกเขียน
+	for code, wantID := range map[int]int{1001: 11, 1002: 12, 1004: 14, 1005: 15} {
+		st, err := r.FindByCode(ctx, code)
+		if err != nil {
+			t.Errorf("station %d: %v", code, err)
+			continue
+		}
+		if st.StationID != wantID {
+			t.Errorf("station %d id = %d, want %d", code, st.StationID, wantID)
+		}
+	}
+	for _, code := range []int{1003, 1006} {
+		if _, err := r.FindByCode(ctx, code); !errors.Is(err, ErrStationNotFound) {
+			t.Errorf("station %d was inserted with a duplicate id (err %v)", code, err)
+		}
+	}
+}
+
+func TestMemoryBulkUpsertWithoutUniqueKeys(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryStationRepository()
+	upserts := []StationUpsert{
+		{Filter: map[string]interface{}{"station_code": 1001}, Fields: map[string]interface{}{"id": 11}},
+		{Filter: map[string]interface{}{"station_code": 1002}, Fields: map[string]interface{}{"id": 11}},
+	}
+	res, err := r.BulkUpsert(ctx, upserts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Inserted != 2 {
+		t.Errorf("inserted = %d, want 2 when no unique index is set", res.Inserted)
+	}
+}
@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoImportChangesetRepository เก็บ changeset และรายการสถานีที่เปลี่ยนไว้ใน MongoDB คนละ collection
+type MongoImportChangesetRepository struct {
+	changesets *mongo.Collection
+	changes    *mongo.Collection
+}
+
+func NewMongoImportChangesetRepository(changesets, changes *mongo.Collection) *MongoImportChangesetRepository {
+	return &MongoImportChangesetRepository{changesets: changesets, changes: changes}
+}
+
+func (r *MongoImportChangesetRepository) List(ctx context.Context, limit int) ([]models.ImportChangeset, error) {
+	cur, err := r.changesets.Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	list := []models.ImportChangeset{}
+	if err := cur.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *MongoImportChangesetRepository) Get(ctx context.Context, id primitive.ObjectID) (*models.ImportChangeset, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *MongoImportChangesetRepository) GetByJob(ctx context.Context, jobID string) (*models.ImportChangeset, error) {
+	return r.findOne(ctx, bson.M{"job_id": jobID})
+}
+
+func (r *MongoImportChangesetRepository) findOne(ctx context.Context, filter bson.M) (*models.ImportChangeset, error) {
+	var cs models.ImportChangeset
+	err := r.changesets.FindOne(ctx, filter).Decode(&cs)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrChangesetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cs, nil
+}
+
+func (r *MongoImportChangesetRepository) Insert(ctx context.Context, cs *models.ImportChangeset) error {
+	if cs.ID.IsZero() {
+		cs.ID = primitive.NewObjectID()
+	}
+	_, err := r.changesets.InsertOne(ctx, cs)
+	return err
+}
+
+func (r *MongoImportChangesetRepository) Replace(ctx context.Context, cs *models.ImportChangeset) error {
+	res, err := r.changesets.ReplaceOne(ctx, bson.M{"_id": cs.ID}, cs)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrChangesetNotFound
+	}
+	return nil
+}
+
+func (r *MongoImportChangesetRepository) AddChanges(ctx context.Context, changes []models.StationChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, 0, len(changes))
+	for _, c := range changes {
+		docs = append(docs, c)
+	}
+	_, err := r.changes.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	return err
+}
+
+func (r *MongoImportChangesetRepository) ListChanges(ctx context.Context, changesetID primitive.ObjectID, skip, limit int) ([]models.StationChange, error) {
+	cur, err := r.changes.Find(ctx, bson.M{"changeset_id": changesetID},
+		options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}).SetSkip(int64(skip)).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	changes := []models.StationChange{}
+	if err := cur.All(ctx, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (r *MongoImportChangesetRepository) EnsureIndexes(ctx context.Context) error {
+	if _, err := r.changesets.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "job_id", Value: 1}},
+	}); err != nil {
+		return err
+	}
+	_, err := r.changes.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "changeset_id", Value: 1}, {Key: "seq", Value: 1}},
+	})
+	return err
+}
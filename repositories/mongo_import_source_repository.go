@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoImportSourceRepository เก็บ import source และประวัติการรันไว้ใน MongoDB คนละ collection
+type MongoImportSourceRepository struct {
+	sources *mongo.Collection
+	runs    *mongo.Collection
+}
+
+func NewMongoImportSourceRepository(sources, runs *mongo.Collection) *MongoImportSourceRepository {
+	return &MongoImportSourceRepository{sources: sources, runs: runs}
+}
+
+func (r *MongoImportSourceRepository) List(ctx context.Context) ([]models.ImportSource, error) {
+	cur, err := r.sources.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	list := []models.ImportSource{}
+	if err := cur.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *MongoImportSourceRepository) Get(ctx context.Context, id primitive.ObjectID) (*models.ImportSource, error) {
+	var src models.ImportSource
+	err := r.sources.FindOne(ctx, bson.M{"_id": id}).Decode(&src)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrImportSourceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &src, nil
+}
+
+func (r *MongoImportSourceRepository) Insert(ctx context.Context, src *models.ImportSource) error {
+	if src.ID.IsZero() {
+		src.ID = primitive.NewObjectID()
+	}
+	_, err := r.sources.InsertOne(ctx, src)
+	return err
+}
+
+func (r *MongoImportSourceRepository) Replace(ctx context.Context, src *models.ImportSource) error {
+	res, err := r.sources.ReplaceOne(ctx, bson.M{"_id": src.ID}, src)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrImportSourceNotFound
+	}
+	return nil
+}
+
+func (r *MongoImportSourceRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := r.sources.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrImportSourceNotFound
+	}
+	_, err = r.runs.DeleteMany(ctx, bson.M{"source_id": id})
+	return err
+}
+
+func (r *MongoImportSourceRepository) AddRun(ctx context.Context, run *models.ImportSourceRun) error {
+	if run.ID.IsZero() {
+		run.ID = primitive.NewObjectID()
+	}
+	_, err := r.runs.InsertOne(ctx, run)
+	return err
+}
+
+func (r *MongoImportSourceRepository) ListRuns(ctx context.Context, sourceID primitive.ObjectID, limit int) ([]models.ImportSourceRun, error) {
+	cur, err := r.runs.Find(ctx, bson.M{"source_id": sourceID},
+		options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	runs := []models.ImportSourceRun{}
+	if err := cur.All(ctx, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
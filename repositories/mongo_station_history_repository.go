@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStationHistoryRepository เก็บประวัติของสถานีไว้ใน MongoDB
+type MongoStationHistoryRepository struct {
+	col *mongo.Collection
+}
+
+func NewMongoStationHistoryRepository(col *mongo.Collection) *MongoStationHistoryRepository {
+	return &MongoStationHistoryRepository{col: col}
+}
+
+// newestFirst เรียงประวัติจากใหม่ไปเก่า ใช้ _id ช่วยเรียงรายการที่เวลาเดียวกัน (เขียนใน batch เดียวกัน)
+var newestFirst = bson.D{{Key: "changed_at", Value: -1}, {Key: "_id", Value: -1}}
+
+func (r *MongoStationHistoryRepository) Append(ctx context.Context, versions []models.StationVersion) error {
+	if len(versions) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, 0, len(versions))
+	for i := range versions {
+		if versions[i].ID.IsZero() {
+			versions[i].ID = primitive.NewObjectID()
+		}
+		docs = append(docs, versions[i])
+	}
+	//ordered เพื่อให้ลำดับใน collection ตรงกับลำดับที่เปลี่ยนจริง
+	_, err := r.col.InsertMany(ctx, docs)
+	return err
+}
+
+func (r *MongoStationHistoryRepository) ListByStation(ctx context.Context, code int, skip, limit int) ([]models.StationVersion, error) {
+	cur, err := r.col.Find(ctx, bson.M{"station_code": code},
+		options.Find().SetSort(newestFirst).SetSkip(int64(skip)).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	list := []models.StationVersion{}
+	if err := cur.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *MongoStationHistoryRepository) CountByStation(ctx context.Context, code int) (int64, error) {
+	return r.col.CountDocuments(ctx, bson.M{"station_code": code})
+}
+
+func (r *MongoStationHistoryRepository) ForEachSince(ctx context.Context, since time.Time, fn func(v models.StationVersion) error) error {
+	cur, err := r.col.Find(ctx, bson.M{"changed_at": bson.M{"$gt": since}}, options.Find().SetSort(newestFirst))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var v models.StationVersion
+		if err := cur.Decode(&v); err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+func (r *MongoStationHistoryRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.col.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "station_code", Value: 1}, {Key: "changed_at", Value: -1}}},
+		{Keys: bson.D{{Key: "changed_at", Value: -1}}},
+	})
+	return err
+}
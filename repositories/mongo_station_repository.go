@@ -0,0 +1,398 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStationRepository เก็บข้อมูลสถานีไว้ใน MongoDB collection
+type MongoStationRepository struct {
+	col *mongo.Collection
+}
+
+func NewMongoStationRepository(col *mongo.Collection) *MongoStationRepository {
+	return &MongoStationRepository{col: col}
+}
+
+// notDeleted เงื่อนไขของสถานีที่ไม่ถูกลบ (ไม่มี deleted_at หรือเป็น null)
+func notDeleted(filter bson.M) bson.M {
+	filter["deleted_at"] = nil
+	return filter
+}
+
+// nearFilter filter เฉพาะสถานีที่ active และใช้ $near เพื่อเรียงจากใกล้ไปไกลตาม lat long ที่ใส่มา
+func nearFilter(lat, long float64) bson.M {
+	return notDeleted(bson.M{
+		"active": 1,
+		"location": bson.M{
+			"$near": bson.M{
+				"$geometry": bson.M{
+					"type":        "Point",
+					"coordinates": []float64{long, lat},
+				},
+			},
+		},
+	})
+}
+
+func (r *MongoStationRepository) FindNearby(ctx context.Context, lat, long float64, skip, limit int) ([]models.Station, error) {
+	cur, err := r.col.Find(ctx, nearFilter(lat, long),
+		options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var stations []models.Station
+	if err := cur.All(ctx, &stations); err != nil {
+		return nil, err
+	}
+	return stations, nil
+}
+
+func (r *MongoStationRepository) CountActive(ctx context.Context) (int64, error) {
+	return r.col.CountDocuments(ctx, notDeleted(bson.M{"active": 1}))
+}
+
+func (r *MongoStationRepository) FindByCode(ctx context.Context, code int) (*models.Station, error) {
+	return r.findOne(ctx, notDeleted(bson.M{"station_code": code}))
+}
+
+func (r *MongoStationRepository) FindByText(ctx context.Context, terms []string) ([]models.Station, error) {
+	//แต่ละ term ต้องอยู่ใน field ใด field หนึ่ง ใช้ regex แบบไม่สนตัวพิมพ์เพราะภาษาไทยไม่เว้นวรรค text index ตัดคำไม่ได้
+	and := bson.A{}
+	for _, term := range terms {
+		or := bson.A{}
+		for _, f := range models.SearchFields {
+			or = append(or, bson.M{f: primitive.Regex{Pattern: regexp.QuoteMeta(term), Options: "i"}})
+		}
+		and = append(and, bson.M{"$or": or})
+	}
+	filter := bson.M{}
+	if len(and) > 0 {
+		filter["$and"] = and
+	}
+
+	cur, err := r.col.Find(ctx, notDeleted(filter))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	stations := []models.Station{}
+	if err := cur.All(ctx, &stations); err != nil {
+		return nil, err
+	}
+	return stations, nil
+}
+
+func (r *MongoStationRepository) FindByFuzzyPrefix(ctx context.Context, namePrefix, romanPrefix string) ([]models.Station, error) {
+	//regex ที่ขึ้นต้นด้วย ^ และไม่มี option ใช้ index ของ field ได้
+	or := bson.A{}
+	prefix := func(field, value string) {
+		if value != "" {
+			or = append(or, bson.M{field: primitive.Regex{Pattern: "^" + regexp.QuoteMeta(value)}})
+		}
+	}
+	prefix("fuzzy_name", namePrefix)
+	prefix("fuzzy_en_name", romanPrefix)
+	prefix("fuzzy_roman", romanPrefix)
+	stations := []models.Station{}
+	if len(or) == 0 {
+		return stations, nil
+	}
+
+	cur, err := r.col.Find(ctx, notDeleted(bson.M{"$or": or}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	if err := cur.All(ctx, &stations); err != nil {
+		return nil, err
+	}
+	return stations, nil
+}
+
+func (r *MongoStationRepository) FindDeletedByCode(ctx context.Context, code int) (*models.Station, error) {
+	return r.findOne(ctx, bson.M{"station_code": code, "deleted_at": bson.M{"$ne": nil}})
+}
+
+func (r *MongoStationRepository) FindByCodes(ctx context.Context, codes []int) ([]models.Station, error) {
+	if len(codes) == 0 {
+		return nil, nil
+	}
+	cur, err := r.col.Find(ctx, bson.M{"station_code": bson.M{"$in": codes}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var stations []models.Station
+	if err := cur.All(ctx, &stations); err != nil {
+		return nil, err
+	}
+	return stations, nil
+}
+
+func (r *MongoStationRepository) findOne(ctx context.Context, filter bson.M) (*models.Station, error) {
+	var st models.Station
+	err := r.col.FindOne(ctx, filter).Decode(&st)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrStationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (r *MongoStationRepository) InsertStation(ctx context.Context, st *models.Station) error {
+	//เช็คก่อนว่ามี station_code นี้อยู่แล้วหรือยัง
+	n, err := r.col.CountDocuments(ctx, bson.M{"station_code": st.StationCode})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrStationExists
+	}
+
+	if st.ID.IsZero() {
+		st.ID = primitive.NewObjectID()
+	}
+	_, err = r.col.InsertOne(ctx, st)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrStationExists
+	}
+	return err
+}
+
+func (r *MongoStationRepository) ReplaceByCode(ctx context.Context, code int, st *models.Station) error {
+	res, err := r.col.ReplaceOne(ctx, notDeleted(bson.M{"station_code": code}), st)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrStationExists
+	}
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrStationNotFound
+	}
+	return nil
+}
+
+func (r *MongoStationRepository) SoftDelete(ctx context.Context, code int, at time.Time, by string) error {
+	res, err := r.col.UpdateOne(ctx, notDeleted(bson.M{"station_code": code}),
+		bson.M{"$set": bson.M{"deleted_at": at, "deleted_by": by}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrStationNotFound
+	}
+	return nil
+}
+
+func (r *MongoStationRepository) Restore(ctx context.Context, code int) error {
+	res, err := r.col.UpdateOne(ctx, bson.M{"station_code": code, "deleted_at": bson.M{"$ne": nil}},
+		bson.M{"$unset": bson.M{"deleted_at": "", "deleted_by": ""}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrStationNotFound
+	}
+	return nil
+}
+
+func (r *MongoStationRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) ([]models.Station, error) {
+	filter := bson.M{"deleted_at": bson.M{"$lt": before}}
+	cur, err := r.col.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "deleted_at", Value: 1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	var candidates []models.Station
+	err = cur.All(ctx, &candidates)
+	cur.Close(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	//ลบทีละตัวพร้อมเงื่อนไขเดิม สถานีที่ถูก restore ระหว่างนั้นจะไม่ถูกลบ
+	purged := make([]models.Station, 0, len(candidates))
+	for _, st := range candidates {
+		res, err := r.col.DeleteOne(ctx, bson.M{"_id": st.ID, "deleted_at": bson.M{"$lt": before}})
+		if err != nil {
+			return purged, err
+		}
+		if res.DeletedCount > 0 {
+			purged = append(purged, st)
+		}
+	}
+	return purged, nil
+}
+
+func (r *MongoStationRepository) ForEach(ctx context.Context, includeDeleted bool, fn func(st models.Station) error) error {
+	filter := bson.M{}
+	if !includeDeleted {
+		filter = notDeleted(filter)
+	}
+	cur, err := r.col.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "station_code", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	//อ่านทีละ document ไม่ต้องโหลดทั้ง collection เข้า memory
+	for cur.Next(ctx) {
+		var st models.Station
+		if err := cur.Decode(&st); err != nil {
+			return err
+		}
+		if err := fn(st); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+func (r *MongoStationRepository) FindDocument(ctx context.Context, filter map[string]interface{}) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	err := r.col.FindOne(ctx, bson.M(filter)).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (r *MongoStationRepository) FindDocumentsIn(ctx context.Context, field string, values []interface{}) ([]map[string]interface{}, error) {
+	cur, err := r.col.Find(ctx, bson.M{field: bson.M{"$in": values}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var docs []map[string]interface{}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+func (r *MongoStationRepository) BulkUpsert(ctx context.Context, upserts []StationUpsert) (*UpsertResult, error) {
+	if len(upserts) == 0 {
+		return &UpsertResult{}, nil
+	}
+
+	//สร้าง NewUpdateOneModel ไว้ใน model
+	//update or insert ถ้ามีข้อมูลตรงตาม filter ก็ update แต่ถ้าไม่มีก็ insert
+	models := make([]mongo.WriteModel, 0, len(upserts))
+	for _, u := range upserts {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M(u.Filter)).
+			SetUpdate(bson.M{"$set": bson.M(u.Fields)}).
+			SetUpsert(true))
+	}
+
+	//unordered ให้ mongo เขียนแบบขนานได้ ลำดับไม่สำคัญเพราะแต่ละรายการเป็นคนละสถานี
+	res, err := r.col.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return nil, err
+	}
+	return &UpsertResult{
+		Inserted: int(res.UpsertedCount),
+		Updated:  int(res.ModifiedCount),
+	}, nil
+}
+
+func (r *MongoStationRepository) BulkUpdate(ctx context.Context, updates []StationUpsert) (int, error) {
+	if len(updates) == 0 {
+		return 0, nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(updates))
+	for _, u := range updates {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M(u.Filter)).
+			SetUpdate(bson.M{"$set": bson.M(u.Fields)}))
+	}
+
+	res, err := r.col.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, err
+	}
+	return int(res.ModifiedCount), nil
+}
+
+func (r *MongoStationRepository) BulkDelete(ctx context.Context, filters []map[string]interface{}) (int, error) {
+	if len(filters) == 0 {
+		return 0, nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(filters))
+	for _, f := range filters {
+		models = append(models, mongo.NewDeleteOneModel().SetFilter(bson.M(f)))
+	}
+
+	res, err := r.col.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, err
+	}
+	return int(res.DeletedCount), nil
+}
+
+func (r *MongoStationRepository) EnsureIndexes(ctx context.Context, keyFields []string) error {
+	// สร้าง index location 2dsphere สำหรับ $near
+	_, err := r.col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.M{"location": "2dsphere"},
+	})
+	if err != nil {
+		return err
+	}
+
+	// index ของ deleted_at สำหรับหาสถานีที่ถูกลบนานเกินกำหนดตอน purge
+	_, err = r.col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"deleted_at": 1},
+		Options: options.Index().SetSparse(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// index ของค่าที่ใช้หาตัวเลือกตอนค้นหาแบบไม่เข้มงวด (FindByFuzzyPrefix)
+	for _, f := range []string{"fuzzy_name", "fuzzy_en_name", "fuzzy_roman"} {
+		_, err = r.col.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.M{f: 1}})
+		if err != nil {
+			return err
+		}
+	}
+
+	// สร้าง unique index ของ key กันไม่ให้มีสถานีซ้ำ
+	// ถ้าข้อมูลเดิมมี key ซ้ำอยู่แล้ว (เช่น station_code เป็น 0 หลายตัว) จะสร้างไม่ผ่านและคืน error
+	keys := bson.D{}
+	for _, f := range keyFields {
+		keys = append(keys, bson.E{Key: f, Value: 1})
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err = r.col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
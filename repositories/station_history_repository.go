@@ -0,0 +1,23 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+)
+
+// StationHistoryRepository ที่เก็บประวัติการเปลี่ยนแปลงของสถานี (เพิ่มอย่างเดียว)
+// มีทั้งแบบ MongoDB และแบบ in-memory เหมือน StationRepository
+type StationHistoryRepository interface {
+	// Append เพิ่มประวัติหลายรายการ (สร้าง id ให้ถ้ายังไม่มี)
+	Append(ctx context.Context, versions []models.StationVersion) error
+	// ListByStation ดึงประวัติของสถานีตาม station_code เรียงจากใหม่ไปเก่า
+	ListByStation(ctx context.Context, code int, skip, limit int) ([]models.StationVersion, error)
+	// CountByStation นับจำนวนประวัติของสถานี
+	CountByStation(ctx context.Context, code int) (int64, error)
+	// ForEachSince ส่งประวัติที่เกิดหลังเวลา since ให้ fn ทีละรายการ เรียงจากใหม่ไปเก่า ถ้า fn คืน error จะหยุดทันที
+	ForEachSince(ctx context.Context, since time.Time, fn func(v models.StationVersion) error) error
+	// EnsureIndexes สร้าง index ที่จำเป็น
+	EnsureIndexes(ctx context.Context) error
+}
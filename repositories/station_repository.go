@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+)
+
+// ErrStationNotFound ใช้เมื่อหา station ตามเงื่อนไขไม่เจอ
+var ErrStationNotFound = errors.New("station not found")
+
+// ErrStationExists ใช้เมื่อจะเพิ่ม station ที่มี station_code (หรือ key ที่เป็น unique) ซ้ำกับที่มีอยู่แล้ว
+var ErrStationExists = errors.New("station already exists")
+
+// StationUpsert คือคำสั่ง upsert 1 รายการ
+// Filter ใช้หา document เดิม (รองรับเฉพาะเงื่อนไขแบบเท่ากับ)
+// Fields คือ field ที่จะ $set ลงไป
+type StationUpsert struct {
+	Filter map[string]interface{}
+	Fields map[string]interface{}
+}
+
+// UpsertResult ผลลัพธ์ของการทำ BulkUpsert
+type UpsertResult struct {
+	Inserted int
+	Updated  int
+}
+
+// StationRepository รวมทุกอย่างที่ service ต้องใช้กับที่เก็บข้อมูลสถานี
+// มีทั้งแบบ MongoDB และแบบ in-memory (ใช้ตอนเทสหรือ demo)
+// สถานีที่ถูก soft delete (มี deleted_at) ถือว่าไม่มีอยู่ ยกเว้น method ที่บอกไว้ว่ารวมด้วย
+type StationRepository interface {
+	// FindNearby ดึงสถานีที่ active เรียงจากใกล้ไปไกล
+	FindNearby(ctx context.Context, lat, long float64, skip, limit int) ([]models.Station, error)
+	// CountActive นับจำนวนสถานีที่ active
+	CountActive(ctx context.Context) (int64, error)
+	// FindByCode ดึงสถานีตาม station_code ถ้าไม่เจอคืน ErrStationNotFound
+	FindByCode(ctx context.Context, code int) (*models.Station, error)
+	// FindByText ดึงสถานีที่ทุก term อยู่ใน models.SearchFields อย่างน้อย 1 field (ไม่สนตัวพิมพ์ อยู่ตรงไหนของข้อความก็ได้)
+	// ใช้หาตัวเลือกก่อนให้ service จัดอันดับ
+	FindByText(ctx context.Context, terms []string) ([]models.Station, error)
+	// FindByFuzzyPrefix ดึงสถานีที่ fuzzy_name ขึ้นต้นด้วย namePrefix หรือ fuzzy_en_name/fuzzy_roman ขึ้นต้นด้วย romanPrefix
+	// ค่าว่างคือไม่ใช้เงื่อนไขนั้น ใช้หาตัวเลือกของการค้นหาแบบไม่เข้มงวดโดยไม่ต้องอ่านทุกสถานี
+	FindByFuzzyPrefix(ctx context.Context, namePrefix, romanPrefix string) ([]models.Station, error)
+	// FindDeletedByCode ดึงสถานีที่ถูกลบตาม station_code ถ้าไม่เจอคืน ErrStationNotFound
+	FindDeletedByCode(ctx context.Context, code int) (*models.Station, error)
+	// FindByCodes ดึงสถานีตาม station_code หลายตัว รวมสถานีที่ถูกลบ code ที่ไม่เจอจะไม่มีในผลลัพธ์
+	FindByCodes(ctx context.Context, codes []int) ([]models.Station, error)
+	// InsertStation เพิ่มสถานีใหม่ ถ้า station_code ซ้ำ (รวมสถานีที่ถูกลบ) คืน ErrStationExists
+	InsertStation(ctx context.Context, st *models.Station) error
+	// ReplaceByCode แทนที่ข้อมูลทั้งหมดของสถานีตาม station_code ถ้าไม่เจอคืน ErrStationNotFound
+	ReplaceByCode(ctx context.Context, code int, st *models.Station) error
+	// SoftDelete ใส่ deleted_at และ deleted_by ให้สถานีตาม station_code ถ้าไม่เจอคืน ErrStationNotFound
+	SoftDelete(ctx context.Context, code int, at time.Time, by string) error
+	// Restore ลบ deleted_at และ deleted_by ของสถานีที่ถูกลบตาม station_code ถ้าไม่เจอคืน ErrStationNotFound
+	Restore(ctx context.Context, code int) error
+	// PurgeDeleted ลบจริงสถานีที่ถูกลบก่อนเวลา before ไม่เกิน limit รายการ คืนสถานีที่ลบไป
+	PurgeDeleted(ctx context.Context, before time.Time, limit int) ([]models.Station, error)
+	// ForEach ส่งสถานีทั้งหมดให้ fn ทีละตัว เรียงตาม station_code ถ้า fn คืน error จะหยุดทันที
+	// includeDeleted เป็น true จะรวมสถานีที่ถูกลบด้วย
+	ForEach(ctx context.Context, includeDeleted bool, fn func(st models.Station) error) error
+
+	// FindDocument, FindDocumentsIn และ Bulk* ใช้ตอน import และ rollback ทำงานกับทุก document รวมที่ถูกลบ
+	// คนเรียกต้องเช็คสถานะการลบของ document เอง (IsDeletedDocument) import จะปฏิเสธแถวของสถานีที่ถูกลบ
+
+	// FindDocument ดึง document ดิบตาม filter ถ้าไม่เจอคืน nil, nil
+	FindDocument(ctx context.Context, filter map[string]interface{}) (map[string]interface{}, error)
+	// FindDocumentsIn ดึง document ดิบทั้งหมดที่ field มีค่าอยู่ใน values (เหมือน $in)
+	FindDocumentsIn(ctx context.Context, field string, values []interface{}) ([]map[string]interface{}, error)
+	// BulkUpsert ทำ update/insert หลายรายการในครั้งเดียว (unordered)
+	BulkUpsert(ctx context.Context, upserts []StationUpsert) (*UpsertResult, error)
+	// BulkUpdate $set Fields ให้ document ที่ตรงกับ Filter ของแต่ละรายการ ไม่ insert ถ้าไม่เจอ
+	// คืนจำนวน document ที่ค่าเปลี่ยนจริง
+	BulkUpdate(ctx context.Context, updates []StationUpsert) (int, error)
+	// BulkDelete ลบ document แรกที่ตรงกับแต่ละ filter คืนจำนวนที่ลบได้
+	BulkDelete(ctx context.Context, filters []map[string]interface{}) (int, error)
+	// EnsureIndexes สร้าง index ที่จำเป็น เช่น 2dsphere ของ location
+	// และ unique index ของ keyFields (field ที่ใช้ระบุตัวสถานีตอน import)
+	EnsureIndexes(ctx context.Context, keyFields []string) error
+}
+
+// IsDeletedDocument เช็คว่า document ดิบ (จาก FindDocument*) ถูก soft delete แล้วหรือไม่ (มี deleted_at ที่ไม่ใช่ null)
+// ใช้ได้ทั้ง MongoDB (field ถูก $unset ตอนคืนสถานี) และ memory
+func IsDeletedDocument(doc map[string]interface{}) bool {
+	v, ok := doc["deleted_at"]
+	return ok && v != nil
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Teneieiza/go-spinsolf-test/dto"
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"github.com/Teneieiza/go-spinsolf-test/repositories"
+	"github.com/Teneieiza/go-spinsolf-test/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrChangesetRolledBack ใช้เมื่อจะ rollback import ที่ rollback ไปแล้ว
+	ErrChangesetRolledBack = errors.New("import already rolled back")
+	// ErrChangesetRunning ใช้เมื่อจะ rollback import ที่ยังไม่จบ
+	ErrChangesetRunning = errors.New("import is still running")
+	// ErrRollbackConflict ใช้เมื่อสถานีถูกแก้ไขหลังจาก import และไม่ได้สั่ง force
+	ErrRollbackConflict = errors.New("stations changed after the import, use force=true to roll back anyway")
+)
+
+// rollbackTimeout เวลาสูงสุดของการ rollback 1 ครั้ง (changeset ใหญ่ต้องเขียนหลาย batch)
+const rollbackTimeout = 5 * time.Minute
+
+// ImportHistoryService ดูประวัติการ import (changeset) และ rollback import job กลับไปเป็นข้อมูลก่อน import
+type ImportHistoryService struct {
+	changesets repositories.ImportChangesetRepository
+	stations   repositories.StationRepository
+	history    *StationHistoryService
+	key        utils.StationKey
+	batchSize  int
+
+	mu sync.Mutex // rollback ทีละครั้ง กัน rollback changeset เดียวกันซ้อนกัน
+}
+
+func NewImportHistoryService(changesets repositories.ImportChangesetRepository, stations repositories.StationRepository, history *StationHistoryService, key utils.StationKey, batchSize int) *ImportHistoryService {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	return &ImportHistoryService{changesets: changesets, stations: stations, history: history, key: key, batchSize: batchSize}
+}
+
+// List ดึง changeset ล่าสุดไม่เกิน limit รายการ
+func (s *ImportHistoryService) List(limit int) ([]models.ImportChangeset, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.changesets.List(ctx, limit)
+}
+
+// Get ดึง changeset ตาม id
+func (s *ImportHistoryService) Get(id string) (*models.ImportChangeset, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, repositories.ErrChangesetNotFound
+	}
+	return s.changesets.Get(ctx, oid)
+}
+
+// Changes ดึงรายการสถานีที่เปลี่ยนใน changeset แบบแบ่งหน้า (page เริ่มที่ 1)
+func (s *ImportHistoryService) Changes(id string, page, limit int) ([]models.StationChange, error) {
+	cs, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return s.changesets.ListChanges(ctx, cs.ID, (page-1)*limit, limit)
+}
+
+// rollbackStep สิ่งที่ต้องทำกับสถานี 1 แห่งตอน rollback
+type rollbackStep struct {
+	change  models.StationChange
+	current map[string]interface{} // document ปัจจุบัน nil คือไม่มีแล้ว
+}
+
+// Rollback คืนสถานีที่ import job เปลี่ยนกลับไปเป็นค่าก่อน import
+// สถานีที่ import เพิ่มจะถูกลบ สถานีที่ถูกแก้ไขหรือปิดจะได้ค่าเดิมของ field ที่เปลี่ยนกลับมา
+// ถ้ามีสถานีที่ถูกแก้ไขหลังจาก import จะไม่ทำอะไรเลยและคืน ErrRollbackConflict พร้อมรายการ
+// ยกเว้น force เป็น true ซึ่งจะเขียนทับค่าที่แก้ไขภายหลังด้วย (สถานีที่ถูกลบไปแล้วจะข้าม)
+// การ rollback ถูกบันทึกเป็น changeset ใหม่ด้วย
+func (s *ImportHistoryService) Rollback(jobID, actor string, force bool) (*dto.RollbackResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cs, err := s.changesets.GetByJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	switch cs.Status {
+	case models.ChangesetRolledBack:
+		return nil, ErrChangesetRolledBack
+	case models.ChangesetRunning:
+		return nil, ErrChangesetRunning
+	}
+
+	resp := &dto.RollbackResponse{JobID: jobID, ChangesetID: cs.ID.Hex(), Conflicts: []dto.RollbackConflict{}}
+	steps, err := s.planRollback(ctx, cs, resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ConflictCount > 0 && !force {
+		resp.Status = http.StatusConflict
+		resp.Message = fmt.Sprintf("Rollback rejected: %d stations changed after the import", resp.ConflictCount)
+		return resp, ErrRollbackConflict
+	}
+
+	rb := &models.ImportChangeset{
+		Kind:       "rollback",
+		Source:     cs.Source,
+		Actor:      actor,
+		Status:     models.ChangesetRunning,
+		RollbackOf: &cs.ID,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.changesets.Insert(ctx, rb); err != nil {
+		return nil, err
+	}
+	resp.RollbackChangesetID = rb.ID.Hex()
+
+	err = s.applyRollback(ctx, rb, steps, resp)
+	now := time.Now()
+	rb.FinishedAt = &now
+	rb.Updated, rb.Deleted = resp.Restored, resp.Deleted
+	rb.Status = models.ChangesetApplied
+	if err != nil {
+		rb.Status, rb.Error = models.ChangesetFailed, err.Error()
+	}
+	//บันทึกผลด้วย context ใหม่ เผื่อ ctx หมดเวลาไปแล้ว
+	saveCtx, saveCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer saveCancel()
+	if e := s.changesets.Replace(saveCtx, rb); e != nil && err == nil {
+		err = e
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cs.Status, cs.RolledBackBy = models.ChangesetRolledBack, &rb.ID
+	if err := s.changesets.Replace(saveCtx, cs); err != nil {
+		return nil, err
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = fmt.Sprintf("Rolled back: restored %d stations, deleted %d inserted stations, skipped %d", resp.Restored, resp.Deleted, resp.Skipped)
+	return resp, nil
+}
+
+// planRollback อ่านทุกรายการของ changeset เทียบกับข้อมูลปัจจุบัน เก็บ conflict ลง resp
+func (s *ImportHistoryService) planRollback(ctx context.Context, cs *models.ImportChangeset, resp *dto.RollbackResponse) ([]rollbackStep, error) {
+	var steps []rollbackStep
+	for skip := 0; ; skip += s.batchSize {
+		changes, err := s.changesets.ListChanges(ctx, cs.ID, skip, s.batchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		//ดึง document ปัจจุบันของทั้ง batch ด้วย $in ครั้งเดียวเหมือนตอน import
+		values := make([]interface{}, 0, len(changes))
+		for _, c := range changes {
+			values = append(values, c.Key[s.key.Fields[0]])
+		}
+		docs, err := s.stations.FindDocumentsIn(ctx, s.key.Fields[0], values)
+		if err != nil {
+			return nil, err
+		}
+		current := make(map[string]map[string]interface{}, len(docs))
+		for _, doc := range docs {
+			current[s.key.DocValue(doc)] = doc
+		}
+
+		for _, c := range changes {
+			doc := current[s.key.DocValue(c.Key)]
+			reason := ""
+			switch {
+			case doc == nil && c.Action == models.ChangeInsert:
+				//สถานีที่ import เพิ่มถูกลบไปแล้ว ไม่ต้องทำอะไร
+				resp.Skipped++
+				continue
+			case doc == nil:
+				reason = "station was deleted after the import"
+			case !matchesAfter(doc, c.After):
+				reason = "station was modified after the import"
+			}
+			if reason != "" {
+				resp.ConflictCount++
+				if len(resp.Conflicts) < dto.MaxReportedRowErrors {
+					resp.Conflicts = append(resp.Conflicts, dto.RollbackConflict{StationCode: c.StationCode, Action: c.Action, Reason: reason})
+				}
+			}
+			steps = append(steps, rollbackStep{change: c, current: doc})
+		}
+
+		if len(changes) < s.batchSize {
+			return steps, nil
+		}
+	}
+}
+
+// applyRollback เขียนค่าก่อน import กลับทีละ batch และบันทึกสิ่งที่ทำลง rb
+func (s *ImportHistoryService) applyRollback(ctx context.Context, rb *models.ImportChangeset, steps []rollbackStep, resp *dto.RollbackResponse) error {
+	for start := 0; start < len(steps); start += s.batchSize {
+		end := start + s.batchSize
+		if end > len(steps) {
+			end = len(steps)
+		}
+
+		var restores []repositories.StationUpsert
+		var deletes []map[string]interface{}
+		var restoreChanges, deleteChanges []models.StationChange
+		for _, step := range steps[start:end] {
+			c := step.change
+			if step.current == nil {
+				//สถานีถูกลบไปหลัง import (force) คืนค่าไม่ได้เพราะเก็บไว้เฉพาะ field ที่เปลี่ยน
+				resp.Skipped++
+				continue
+			}
+
+			rc := models.StationChange{ChangesetID: rb.ID, StationCode: c.StationCode, Key: c.Key}
+			if c.Action == models.ChangeInsert {
+				deletes = append(deletes, c.Key)
+				rc.Action, rc.Before = models.ChangeDelete, withoutID(step.current)
+				deleteChanges = append(deleteChanges, rc)
+			} else {
+				restores = append(restores, repositories.StationUpsert{Filter: c.Key, Fields: c.Before})
+				rc.Action, rc.Before, rc.After = models.ChangeUpdate, pickFields(step.current, c.Before), c.Before
+				restoreChanges = append(restoreChanges, rc)
+			}
+		}
+
+		changes := append(restoreChanges, deleteChanges...)
+		if len(changes) > 0 {
+			for i := range changes {
+				changes[i].Seq = rb.Changes + i
+			}
+			if err := s.changesets.AddChanges(ctx, changes); err != nil {
+				return err
+			}
+			rb.Changes += len(changes)
+		}
+		//ประวัติของสถานีบันทึกหลังเขียนแต่ละแบบสำเร็จ
+		if len(restores) > 0 {
+			if _, err := s.stations.BulkUpdate(ctx, restores); err != nil {
+				return err
+			}
+			resp.Restored += len(restores)
+			if err := s.history.record(ctx, versionsFromChanges(restoreChanges, models.HistorySourceRollback, rb.Actor, rb)); err != nil {
+				return err
+			}
+		}
+		if len(deletes) > 0 {
+			n, err := s.stations.BulkDelete(ctx, deletes)
+			if err != nil {
+				return err
+			}
+			resp.Deleted += n
+			if err := s.history.record(ctx, versionsFromChanges(deleteChanges, models.HistorySourceRollback, rb.Actor, rb)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matchesAfter ตรวจว่า document ยังมีค่าตามที่ import เขียนไว้
+// ไม่เทียบ location เพราะสร้างจาก lat/long และชนิดที่อ่านจาก database ต่างจากตอนเขียน
+func matchesAfter(doc, after map[string]interface{}) bool {
+	for k, v := range after {
+		if k == "location" {
+			continue
+		}
+		if !utils.ValuesEqual(doc[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+// pickFields ค่าปัจจุบันของ field ที่อยู่ใน fields
+func pickFields(doc, fields map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(fields))
+	for k := range fields {
+		out[k] = doc[k]
+	}
+	return out
+}
+
+// withoutID คัดลอก document โดยไม่เอา _id
+func withoutID(doc map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		if k != "_id" {
+			out[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Teneieiza/go-spinsolf-test/dto"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// สถานะของ import job
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+var (
+	// ErrJobNotFound ใช้เมื่อไม่เจอ job ตาม id
+	ErrJobNotFound = errors.New("import job not found")
+	// ErrJobQueueFull ใช้เมื่อคิวเต็ม ให้ client ลองใหม่ภายหลัง
+	ErrJobQueueFull = errors.New("import job queue is full")
+	// ErrJobFinished ใช้เมื่อจะยกเลิก job ที่จบไปแล้ว
+	ErrJobFinished = errors.New("import job already finished")
+	// ErrJobServiceClosed ใช้เมื่อส่ง job หลังจาก Shutdown ไปแล้ว
+	ErrJobServiceClosed = errors.New("import job service is shut down")
+)
+
+// finishedJobTTL ระยะเวลาที่เก็บ job ที่จบแล้วไว้ใน memory ให้ client มาถามผลได้
+const finishedJobTTL = 24 * time.Hour
+
+// ImportRunFunc คืองานที่ job ต้องทำ รับ context (ถูก cancel ได้) และตัวเก็บความคืบหน้า
+type ImportRunFunc func(ctx context.Context, progress *ImportProgress) (*dto.ImportStationResponse, error)
+
+// JobInfo ข้อมูลของ job ที่กำลังรัน ส่งให้งานผ่าน context
+type JobInfo struct {
+	ID     string
+	Kind   string
+	Source string
+}
+
+type jobInfoKey struct{}
+
+// JobInfoFromContext ดึงข้อมูลของ job จาก context ที่ worker ส่งให้ ok เป็น false ถ้าไม่ได้รันใน job
+func JobInfoFromContext(ctx context.Context) (JobInfo, bool) {
+	info, ok := ctx.Value(jobInfoKey{}).(JobInfo)
+	return info, ok
+}
+
+// importJob สถานะภายในของ job 1 ตัว ทุก field ที่เปลี่ยนได้ต้องอ่าน/เขียนผ่าน mu ของ ImportJobService
+type importJob struct {
+	id         string
+	kind       string
+	source     string
+	state      string
+	run        ImportRunFunc
+	progress   *ImportProgress
+	result     *dto.ImportStationResponse
+	err        string
+	createdAt  time.Time
+	startedAt  *time.Time
+	finishedAt *time.Time
+	cancel     context.CancelFunc
+	cleanup    func()
+}
+
+// ImportJobService รัน import แบบ async บน worker pool ที่จำกัดจำนวน
+// job เก็บไว้ใน memory ถ้า restart server job ที่ค้างอยู่จะหายไป
+type ImportJobService struct {
+	mu      sync.Mutex
+	jobs    map[string]*importJob
+	queue   chan *importJob
+	timeout time.Duration
+	ctx     context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewImportJobService สร้าง service พร้อมเปิด worker ตามจำนวน workers
+// queueSize คือจำนวน job ที่รอคิวได้ timeout คือเวลาสูงสุดของแต่ละ job
+func NewImportJobService(workers, queueSize int, timeout time.Duration) *ImportJobService {
+	if workers <= 0 {
+		workers = 1
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	s := &ImportJobService{
+		jobs:    make(map[string]*importJob),
+		queue:   make(chan *importJob, queueSize),
+		timeout: timeout,
+		ctx:     ctx,
+		stop:    stop,
+	}
+
+	for i := 0; i < workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	return s
+}
+
+// Submit ส่ง job เข้าคิวแล้วคืนสถานะทันทีโดยไม่รอให้ทำเสร็จ
+// cleanup (ถ้ามี) จะถูกเรียกครั้งเดียวเมื่อ job จบ ไม่ว่าจะรันเสร็จ ถูกยกเลิก หรือเข้าคิวไม่ได้ เช่นใช้ลบไฟล์ชั่วคราว
+func (s *ImportJobService) Submit(kind, source string, run ImportRunFunc, cleanup func()) (*dto.ImportJobResponse, error) {
+	job := &importJob{
+		id:        primitive.NewObjectID().Hex(),
+		kind:      kind,
+		source:    source,
+		state:     JobQueued,
+		run:       run,
+		progress:  &ImportProgress{},
+		createdAt: time.Now(),
+		cleanup:   cleanup,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneLocked()
+
+	//หลัง Shutdown ไม่มี worker มารับงานแล้ว ถ้าปล่อยเข้าคิวไฟล์ชั่วคราวจะค้าง
+	if s.ctx.Err() != nil {
+		job.runCleanup()
+		return nil, ErrJobServiceClosed
+	}
+
+	select {
+	case s.queue <- job:
+	default:
+		job.runCleanup()
+		return nil, ErrJobQueueFull
+	}
+	s.jobs[job.id] = job
+
+	return job.toResponse(), nil
+}
+
+// Get ดึงสถานะของ job
+func (s *ImportJobService) Get(id string) (*dto.ImportJobResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return job.toResponse(), nil
+}
+
+// List ดึงสถานะของทุก job เรียงจากใหม่ไปเก่า
+func (s *ImportJobService) List() []*dto.ImportJobResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*dto.ImportJobResponse, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		list = append(list, job.toResponse())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list
+}
+
+// Cancel ยกเลิก job ถ้ายังรอคิวอยู่จะไม่ถูกรันเลย ถ้ากำลังรันจะ cancel context
+func (s *ImportJobService) Cancel(id string) (*dto.ImportJobResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	switch job.state {
+	case JobQueued:
+		now := time.Now()
+		job.state = JobCancelled
+		job.finishedAt = &now
+		job.runCleanup()
+	case JobRunning:
+		//worker จะเปลี่ยน state เป็น cancelled เองหลัง run คืนค่ากลับมา
+		job.cancel()
+	default:
+		return nil, ErrJobFinished
+	}
+	return job.toResponse(), nil
+}
+
+// Shutdown หยุดรับงานและยกเลิก job ที่กำลังรันอยู่ แล้วรอ worker ปิดตัว
+// job ที่ยังรอคิวอยู่จะถูกยกเลิกและเรียก cleanup ให้ ไฟล์ชั่วคราวจะได้ไม่ค้าง
+func (s *ImportJobService) Shutdown() {
+	s.stop()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		select {
+		case job := <-s.queue:
+			if job.state == JobQueued {
+				now := time.Now()
+				job.state = JobCancelled
+				job.finishedAt = &now
+			}
+			job.runCleanup()
+		default:
+			return
+		}
+	}
+}
+
+func (s *ImportJobService) worker() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case job := <-s.queue:
+			s.runJob(job)
+		}
+	}
+}
+
+func (s *ImportJobService) runJob(job *importJob) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if s.timeout > 0 {
+		ctx, cancel = context.WithTimeout(s.ctx, s.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(s.ctx)
+	}
+	defer cancel()
+	ctx = context.WithValue(ctx, jobInfoKey{}, JobInfo{ID: job.id, Kind: job.kind, Source: job.source})
+
+	s.mu.Lock()
+	//job ถูกยกเลิกไปแล้วตอนรอคิว
+	if job.state != JobQueued {
+		s.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	//select ของ worker อาจหยิบ job จากคิวได้แม้ Shutdown ไปแล้ว ไม่ต้องเริ่มงานใหม่
+	if s.ctx.Err() != nil {
+		job.state = JobCancelled
+		job.finishedAt = &now
+		job.runCleanup()
+		s.mu.Unlock()
+		return
+	}
+	job.state = JobRunning
+	job.startedAt = &now
+	job.cancel = cancel
+	s.mu.Unlock()
+
+	result, err := job.run(ctx, job.progress)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job.runCleanup()
+
+	finished := time.Now()
+	job.finishedAt = &finished
+	//เก็บผลลัพธ์ไว้แม้จะ error เช่นตอน strict mode ปฏิเสธไฟล์ จะได้เห็นรายการ error ของแต่ละแถว
+	job.result = result
+	switch {
+	case err == nil:
+		job.state = JobSucceeded
+	case errors.Is(err, context.Canceled):
+		job.state = JobCancelled
+		job.err = err.Error()
+	default:
+		job.state = JobFailed
+		job.err = err.Error()
+		log.Printf("import job %s failed: %v", job.id, err)
+	}
+}
+
+// pruneLocked ลบ job ที่จบไปนานแล้วออกจาก memory (ต้องถือ mu ก่อนเรียก)
+func (s *ImportJobService) pruneLocked() {
+	cutoff := time.Now().Add(-finishedJobTTL)
+	for id, job := range s.jobs {
+		if job.finishedAt != nil && job.finishedAt.Before(cutoff) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// runCleanup เรียก cleanup ของ job ครั้งเดียว (ต้องถือ mu ก่อนเรียก)
+func (j *importJob) runCleanup() {
+	if j.cleanup != nil {
+		j.cleanup()
+		j.cleanup = nil
+	}
+}
+
+// toResponse แปลงสถานะภายในเป็น dto (ต้องถือ mu ก่อนเรียก)
+func (j *importJob) toResponse() *dto.ImportJobResponse {
+	return &dto.ImportJobResponse{
+		ID:         j.id,
+		Type:       j.kind,
+		Source:     j.source,
+		State:      j.state,
+		Progress:   j.progress.Snapshot(),
+		Result:     j.result,
+		Error:      j.err,
+		CreatedAt:  j.createdAt,
+		StartedAt:  j.startedAt,
+		FinishedAt: j.finishedAt,
+	}
+}
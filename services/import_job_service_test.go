@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Teneieiza/go-spinsolf-test/dto"
+)
+
+func TestImportJobShutdownCleansQueuedJobs(t *testing.T) {
+	s := NewImportJobService(1, 4, 0)
+
+	started := make(chan struct{})
+	block := func(ctx context.Context, _ *ImportProgress) (*dto.ImportStationResponse, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	noop := func(context.Context, *ImportProgress) (*dto.ImportStationResponse, error) {
+		t.Error("queued job ran after shutdown")
+		return nil, nil
+	}
+
+	cleaned := map[string]int{}
+	cleanup := func(name string) func() {
+		return func() { cleaned[name]++ }
+	}
+
+	running, err := s.Submit("file", "running.csv", block, cleanup("running"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	var queued []string
+	for _, name := range []string{"a.csv", "b.csv"} {
+		job, err := s.Submit("file", name, noop, cleanup(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		queued = append(queued, job.ID)
+	}
+
+	s.Shutdown()
+
+	for _, name := range []string{"running", "a.csv", "b.csv"} {
+		if cleaned[name] != 1 {
+			t.Errorf("cleanup of %s called %d times, want 1", name, cleaned[name])
+		}
+	}
+	for _, id := range append(queued, running.ID) {
+		job, err := s.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.State != JobCancelled {
+			t.Errorf("job %s state %q, want %q", job.Source, job.State, JobCancelled)
+		}
+	}
+
+	if _, err := s.Submit("file", "late.csv", noop, cleanup("late")); !errors.Is(err, ErrJobServiceClosed) {
+		t.Errorf("submit after shutdown: got %v, want ErrJobServiceClosed", err)
+	}
+	if cleaned["late"] != 1 {
+		t.Errorf("cleanup of late submit called %d times, want 1", cleaned["late"])
+	}
+}
@@ -0,0 +1,680 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Teneieiza/go-spinsolf-test/dto"
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"github.com/Teneieiza/go-spinsolf-test/parsers"
+	"github.com/Teneieiza/go-spinsolf-test/repositories"
+	"github.com/Teneieiza/go-spinsolf-test/utils"
+)
+
+// rowOpener เปิดอ่านแถวข้อมูลตั้งแต่ต้น เรียกได้หลายครั้ง (strict mode อ่าน 2 รอบ)
+type rowOpener func() (parsers.RowReader, error)
+
+// rowLoc ตำแหน่งของแถวในไฟล์ sheet มีเฉพาะ spreadsheet
+// row คือเลขแถวในไฟล์หรือ sheet จาก parser (parsers.RowKey) รูปแบบที่ไม่มีเลขแถวใช้ลำดับของแถวข้อมูลแทน
+type rowLoc struct {
+	sheet string
+	row   int
+}
+
+func (l rowLoc) String() string {
+	if l.sheet != "" {
+		return fmt.Sprintf("row %d of sheet %s", l.row, l.sheet)
+	}
+	return fmt.Sprintf("row %d", l.row)
+}
+
+// rowError สร้าง dto.RowError ของแถวนี้
+func (l rowLoc) rowError(column string, value interface{}, reason string) dto.RowError {
+	return dto.RowError{Sheet: l.sheet, Row: l.row, Column: column, Value: value, Reason: reason}
+}
+
+// stagedRow แถวที่ validate และตรวจ key ผ่านแล้ว รอ prefetch/diff/write
+type stagedRow struct {
+	loc     rowLoc
+	station models.Station
+	filter  map[string]interface{}
+	key     string
+
+	insertOnly []string // field ที่ใช้เฉพาะตอน insert สถานีเดิมคงค่าเดิมไว้ (parsers.InsertOnlyKey)
+}
+
+// phaseTimings เวลาที่ใช้ในแต่ละขั้นของการ import (รวมทุก batch)
+type phaseTimings struct {
+	parse, validate, prefetch, diff, write time.Duration
+}
+
+// importPipeline state ของการ import 1 รอบ
+// ทำงานทีละ batch: validate -> prefetch document เดิมด้วย $in -> diff ใน memory -> bulk write
+type importPipeline struct {
+	svc      *ImportStationService
+	opts     ImportOptions
+	progress *ImportProgress
+	timings  *phaseTimings
+	result   *dto.ImportStationResponse
+	diff     *dto.ImportDiff
+	seen     map[string]rowLoc // key ที่เจอแล้วในไฟล์นี้ กับแถวแรกที่เจอ ใช้ตรวจ key ซ้ำ
+	unkeyed  []dto.RowError    // แถวที่ถูกปฏิเสธก่อนรู้ key (parser อ่านไม่ได้ หรือไม่มีค่า key)
+
+	// headersResolved คือ parser จับคู่ header กับ field แล้ว (csv, spreadsheet) ชื่อ column ของ mapping profile ถูกใช้ไปแล้ว
+	headersResolved bool
+
+	changeset *models.ImportChangeset // nil ตอน dry run และตอน validate อย่างเดียว
+}
+
+// runImport รัน pipeline กับแถวข้อมูลจาก open
+// strict mode จะอ่านรอบแรกเพื่อ validate อย่างเดียว ถ้ามี error จะไม่เขียนอะไรลง database เลย
+// sync mode อ่านรอบแรกเพื่อเก็บ key ทั้งหมดในไฟล์ ถ้าสถานีที่จะถูกปิดเกิน % ที่ยอมให้จะไม่เขียนอะไรเลย
+// dry run จะคืนรายงาน diff แทนการเขียนลง database
+func (s *ImportStationService) runImport(ctx context.Context, open rowOpener, opts ImportOptions, progress *ImportProgress) (*dto.ImportStationResponse, error) {
+	started := time.Now()
+	timings := &phaseTimings{}
+
+	var missing []stagedRow
+	if opts.Strict || opts.Sync {
+		check := s.newPipeline(opts, nil, timings)
+		if err := check.run(ctx, open, false); err != nil {
+			return nil, err
+		}
+		if opts.Strict && check.result.ErrorCount > 0 {
+			result := check.result
+			result.Status = http.StatusUnprocessableEntity
+			result.Message = fmt.Sprintf("Import rejected: %d validation errors", result.ErrorCount)
+			result.Timings = timings.toDTO(started)
+			return result, ErrImportValidation
+		}
+
+		//แถวที่อ่าน key ไม่ได้ไม่อยู่ใน seen สถานีของแถวนั้นจะดูเหมือนหายไปจากไฟล์ ปิดผิดตัวได้ จึงไม่ sync เลย
+		if opts.Sync && len(check.unkeyed) > 0 {
+			result := check.result
+			result.Status = http.StatusUnprocessableEntity
+			result.Mode = opts.Mode()
+			result.UnkeyedRows = check.unkeyed
+			result.Message = fmt.Sprintf("Sync rejected: %d rows were rejected without a usable key, fix them or import with mode=upsert", len(check.unkeyed))
+			result.Timings = timings.toDTO(started)
+			return result, ErrSyncUnkeyedRows
+		}
+
+		if opts.Sync {
+			var active int
+			var err error
+			t := time.Now()
+			missing, active, err = s.missingStations(ctx, check.seen)
+			timings.prefetch += time.Since(t)
+			if err != nil {
+				return nil, err
+			}
+			//ค่าที่ส่งมาตอน import ลดเกณฑ์ของ config ได้อย่างเดียว เพิ่มเกินไม่ได้
+			maxRemove := s.syncMaxRemove
+			if opts.MaxRemovePercent > 0 && opts.MaxRemovePercent < maxRemove {
+				maxRemove = opts.MaxRemovePercent
+			}
+			if active > 0 && float64(len(missing))*100 > maxRemove*float64(active) {
+				result := check.result
+				result.Status = http.StatusUnprocessableEntity
+				result.Mode = opts.Mode()
+				result.Deactivated = len(missing)
+				result.DeactivatedStations = deactivatedList(missing)
+				result.Message = fmt.Sprintf("Sync rejected: %d of %d active stations (%.1f%%) are missing from the file, limit is %g%%",
+					len(missing), active, float64(len(missing))*100/float64(active), maxRemove)
+				result.Timings = timings.toDTO(started)
+				return result, ErrSyncThreshold
+			}
+		}
+	}
+
+	p := s.newPipeline(opts, progress, timings)
+	if !opts.DryRun {
+		cs, err := s.startChangeset(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		p.changeset = cs
+	}
+	err := p.run(ctx, open, true)
+	if err == nil && opts.Sync {
+		err = p.deactivate(ctx, missing)
+	}
+	//บันทึกผลของ changeset แม้ import จะล้มเหลว เพราะอาจเขียนไปแล้วบางส่วนและต้อง rollback ได้
+	if p.changeset != nil {
+		s.finishChangeset(p.changeset, p.result, err)
+		p.result.ChangesetID = p.changeset.ID.Hex()
+	}
+
+	result := p.result
+	result.Count = result.Inserted + result.Updated
+	result.Timings = timings.toDTO(started)
+	result.Mode = opts.Mode()
+	//คืนผลที่ทำไปแล้วพร้อม error job จะเก็บ changeset_id ไว้ให้ rollback ส่วนที่เขียนไปแล้วได้
+	if err != nil {
+		result.Status = http.StatusInternalServerError
+		result.Message = fmt.Sprintf("Import stopped after %d new, %d updated: %v", result.Inserted, result.Updated, err)
+		return result, err
+	}
+	if opts.DryRun {
+		result.Diff = p.diff
+		result.Message = fmt.Sprintf("Dry run: would import %d new, update %d existing, %d unchanged, %d records corrupted, %d rows rejected", result.Inserted, result.Updated, result.Unchanged, result.Corrupted, result.Rejected)
+		if opts.Sync {
+			result.Message += fmt.Sprintf(", deactivate %d missing stations", result.Deactivated)
+		}
+	} else {
+		result.Message = fmt.Sprintf("Imported %d new, updated %d existing, %d records corrupted, %d rows rejected, %d validation errors", result.Inserted, result.Updated, result.Corrupted, result.Rejected, result.ErrorCount)
+		if opts.Sync {
+			result.Message += fmt.Sprintf(", deactivated %d missing stations", result.Deactivated)
+		}
+	}
+	return result, nil
+}
+
+// missingStations หาสถานีที่ active อยู่แต่ key ไม่อยู่ใน seen (key ทั้งหมดในไฟล์) และนับสถานีที่ active ทั้งหมด
+// สถานีที่ไม่มีค่า key จะไม่นับ เพราะใช้หา document ที่จะปิดไม่ได้ และไม่รวมสถานีที่ถูกลบ
+func (s *ImportStationService) missingStations(ctx context.Context, seen map[string]rowLoc) ([]stagedRow, int, error) {
+	var missing []stagedRow
+	active := 0
+	err := s.repo.ForEach(ctx, false, func(st models.Station) error {
+		if st.Active != 1 {
+			return nil
+		}
+		active++
+		filter, absent := s.key.Filter(st)
+		if len(absent) > 0 {
+			return nil
+		}
+		key := s.key.Value(st)
+		if _, ok := seen[key]; !ok {
+			missing = append(missing, stagedRow{station: st, filter: filter, key: key})
+		}
+		return nil
+	})
+	return missing, active, err
+}
+
+// deactivate ปิดสถานีที่ไม่มีในไฟล์ทีละ batch (dry run นับอย่างเดียว)
+func (p *importPipeline) deactivate(ctx context.Context, missing []stagedRow) error {
+	p.result.DeactivatedStations = deactivatedList(missing)
+	if p.opts.DryRun {
+		p.result.Deactivated = len(missing)
+		return nil
+	}
+
+	t := time.Now()
+	defer func() { p.timings.write += time.Since(t) }()
+	for start := 0; start < len(missing); start += p.svc.batchSize {
+		end := start + p.svc.batchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		updates := make([]repositories.StationUpsert, 0, end-start)
+		changes := make([]models.StationChange, 0, end-start)
+		for _, r := range missing[start:end] {
+			updates = append(updates, repositories.StationUpsert{Filter: r.filter, Fields: map[string]interface{}{"active": 0}})
+			changes = append(changes, models.StationChange{
+				StationCode: r.station.StationCode, Key: r.filter, Action: models.ChangeDeactivate,
+				Before: map[string]interface{}{"active": r.station.Active}, After: map[string]interface{}{"active": 0},
+			})
+		}
+		if err := p.recordChanges(ctx, changes); err != nil {
+			return err
+		}
+		n, err := p.svc.repo.BulkUpdate(ctx, updates)
+		if err != nil {
+			return err
+		}
+		p.result.Deactivated += n
+		if err := p.recordHistory(ctx, changes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startChangeset สร้าง changeset ของการ import ครั้งนี้ ข้อมูลของ job (id, ชนิด, แหล่งข้อมูล) มาจาก ctx
+func (s *ImportStationService) startChangeset(ctx context.Context, opts ImportOptions) (*models.ImportChangeset, error) {
+	cs := &models.ImportChangeset{
+		Actor:     opts.Actor,
+		Mode:      opts.Mode(),
+		Status:    models.ChangesetRunning,
+		CreatedAt: time.Now(),
+	}
+	if info, ok := JobInfoFromContext(ctx); ok {
+		cs.JobID, cs.Kind, cs.Source = info.ID, info.Kind, info.Source
+	}
+	if err := s.changesets.Insert(ctx, cs); err != nil {
+		return nil, fmt.Errorf("create changeset: %w", err)
+	}
+	return cs, nil
+}
+
+// finishChangeset บันทึกสถานะและจำนวนสุดท้ายของ changeset
+// ใช้ context ใหม่ เพราะ context ของ job อาจถูกยกเลิกไปแล้ว
+func (s *ImportStationService) finishChangeset(cs *models.ImportChangeset, result *dto.ImportStationResponse, runErr error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	cs.FinishedAt = &now
+	cs.Inserted, cs.Updated, cs.Deactivated = result.Inserted, result.Updated, result.Deactivated
+	cs.Status = models.ChangesetApplied
+	if runErr != nil {
+		cs.Status, cs.Error = models.ChangesetFailed, runErr.Error()
+	}
+	if err := s.changesets.Replace(ctx, cs); err != nil {
+		log.Printf("import changeset %s: %v", cs.ID.Hex(), err)
+	}
+}
+
+// recordChanges บันทึกค่าก่อน/หลังของสถานีลง changeset (ไม่ทำอะไรถ้าไม่มี changeset)
+func (p *importPipeline) recordChanges(ctx context.Context, changes []models.StationChange) error {
+	if p.changeset == nil || len(changes) == 0 {
+		return nil
+	}
+	for i := range changes {
+		changes[i].ChangesetID = p.changeset.ID
+		changes[i].Seq = p.changeset.Changes + i
+	}
+	if err := p.svc.changesets.AddChanges(ctx, changes); err != nil {
+		return fmt.Errorf("record changeset: %w", err)
+	}
+	p.changeset.Changes += len(changes)
+	return nil
+}
+
+// recordHistory บันทึกประวัติของสถานีที่เขียนสำเร็จแล้ว
+func (p *importPipeline) recordHistory(ctx context.Context, changes []models.StationChange) error {
+	return p.svc.history.record(ctx, versionsFromChanges(changes, models.HistorySourceImport, p.opts.Actor, p.changeset))
+}
+
+// deactivatedList รายการสถานีที่ถูกปิดสำหรับรายงานผล
+func deactivatedList(missing []stagedRow) []dto.StationDiff {
+	list := make([]dto.StationDiff, 0, len(missing))
+	for _, r := range missing {
+		list = append(list, dto.StationDiff{StationCode: r.station.StationCode, Name: r.station.Name})
+	}
+	return list
+}
+
+func (s *ImportStationService) newPipeline(opts ImportOptions, progress *ImportProgress, timings *phaseTimings) *importPipeline {
+	return &importPipeline{
+		svc:      s,
+		opts:     opts,
+		progress: progress,
+		timings:  timings,
+		result:   &dto.ImportStationResponse{Status: http.StatusOK, DryRun: opts.DryRun, Errors: []dto.RowError{}},
+		diff: &dto.ImportDiff{
+			Inserted:  []dto.StationDiff{},
+			Updated:   []dto.StationDiff{},
+			Unchanged: []dto.StationDiff{},
+			Corrupted: []dto.StationDiff{},
+		},
+		seen: make(map[string]rowLoc),
+	}
+}
+
+// run อ่านแถวทั้งหมดแล้วส่งไปทำทีละ batch
+// apply เป็น false คือ validate อย่างเดียว ไม่เขียน (strict mode ยัง prefetch เพื่อตรวจสถานีที่ถูกลบ)
+func (p *importPipeline) run(ctx context.Context, open rowOpener, apply bool) error {
+	t := time.Now()
+	rows, err := open()
+	p.timings.parse += time.Since(t)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	//รายงานว่าใช้แถวไหนเป็น header และแต่ละ column จับคู่กับ field อะไร
+	if hr, ok := rows.(parsers.HeaderReporter); ok {
+		if row, headers := hr.HeaderInfo(); row > 0 {
+			p.result.HeaderRow, p.result.Headers = row, headers
+			p.headersResolved = true
+		}
+	}
+	if er, ok := rows.(parsers.EncodingReporter); ok {
+		p.result.Encoding, p.result.Delimiter = er.EncodingInfo()
+	}
+
+	batch := make([]stagedRow, 0, p.svc.batchSize)
+	var loc rowLoc
+	for {
+		//ถ้า job ถูกยกเลิกให้หยุดทันที
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		t = time.Now()
+		item, err := rows.Next()
+		p.timings.parse += time.Since(t)
+		if err == io.EOF {
+			break
+		}
+		//แถวจาก xlsx มีชื่อ sheet ติดมา เปลี่ยน sheet แล้วเริ่มนับแถวใหม่
+		if sheet, ok := item[parsers.SheetKey].(string); ok {
+			delete(item, parsers.SheetKey)
+			if sheet != loc.sheet {
+				loc = rowLoc{sheet: sheet}
+			}
+		}
+		loc.row++
+		if n, ok := item[parsers.RowKey].(int); ok {
+			loc.row = n
+		}
+		delete(item, parsers.RowKey)
+		insertOnly, _ := item[parsers.InsertOnlyKey].([]string)
+		delete(item, parsers.InsertOnlyKey)
+
+		//parser อ่านแถวนี้ได้แต่ใช้ไม่ได้ ปฏิเสธเฉพาะแถวนี้แล้วอ่านต่อ
+		var rowErr *parsers.RowError
+		if errors.As(err, &rowErr) {
+			p.progress.addParsed(1)
+			e := loc.rowError(rowErr.Column, rowErr.Value, rowErr.Reason+", row rejected")
+			p.addRowError(e)
+			p.addUnkeyed(e)
+			p.result.Rejected++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		//แถวที่ไม่มีค่าเลย (เช่น {} ใน json) ไม่ใช่ข้อมูล ข้ามไปเหมือนแถวว่างใน csv/spreadsheet
+		//ไม่นับเป็นแถวที่ไม่มี key ไม่อย่างนั้น sync จะหยุดทุกครั้งที่ไฟล์มีแถวว่าง
+		if isBlankItem(item) {
+			continue
+		}
+		p.progress.addParsed(1)
+
+		t = time.Now()
+		staged, ok := p.stage(loc, item)
+		p.timings.validate += time.Since(t)
+		if !ok {
+			continue
+		}
+		staged.insertOnly = insertOnly
+
+		batch = append(batch, staged)
+		if len(batch) >= p.svc.batchSize {
+			if err := p.finishBatch(ctx, batch, apply); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	//สรุปของแต่ละ sheet (xlsx) รู้ครบเมื่ออ่านจบแล้วเท่านั้น
+	if sr, ok := rows.(parsers.SheetReporter); ok {
+		if sheets := sr.Sheets(); len(sheets) > 0 {
+			p.result.Sheets = sheets
+		}
+	}
+
+	if len(batch) > 0 {
+		return p.finishBatch(ctx, batch, apply)
+	}
+	return nil
+}
+
+// finishBatch เขียน batch ลง database ถ้า apply ถ้าเป็นรอบ validate ของ strict mode จะตรวจสถานีที่ถูกลบอย่างเดียว
+func (p *importPipeline) finishBatch(ctx context.Context, batch []stagedRow, apply bool) error {
+	if apply {
+		return p.flush(ctx, batch)
+	}
+	if p.opts.Strict {
+		return p.checkDeleted(ctx, batch)
+	}
+	return nil
+}
+
+// stage validate แถว เก็บ error ลงรายงาน และตรวจ key
+// คืน false ถ้าแถวนี้ถูกปฏิเสธ
+func (p *importPipeline) stage(loc rowLoc, item map[string]interface{}) (stagedRow, bool) {
+	key := p.svc.key
+
+	//แปลงชื่อ column และค่าตาม mapping profile ก่อน validate
+	//parser ที่จับคู่ header เองใช้ชื่อ column ของ profile ไปแล้ว ห้ามเปลี่ยนชื่อซ้ำ (ชื่อที่สลับกันจะทับกันเอง)
+	//field ที่ transform ไม่ผ่านจะไม่รายงาน error ของ validate ซ้ำอีก
+	var mapped map[string]bool
+	if p.opts.Profile != nil {
+		var mapErrs []utils.FieldError
+		if p.headersResolved {
+			item, mapErrs = p.opts.Profile.Convert(item)
+		} else {
+			item, mapErrs = p.opts.Profile.Apply(item)
+		}
+		mapped = make(map[string]bool, len(mapErrs))
+		for _, fe := range mapErrs {
+			mapped[fe.Field] = true
+			p.addRowError(loc.rowError(fe.Field, fe.Value, fe.Reason))
+		}
+	}
+
+	//validate แล้วเก็บ error ของแต่ละ field ไว้ในรายงาน
+	st, fieldErrs := utils.ValidateStation(item)
+	for _, fe := range fieldErrs {
+		if mapped[fe.Field] {
+			continue
+		}
+		p.addRowError(loc.rowError(fe.Field, fe.Value, fe.Reason))
+	}
+
+	//สร้าง filter จาก key ถ้าไม่มีค่า key หรือ key ซ้ำกับแถวก่อนหน้า ให้ปฏิเสธแถวนี้
+	//กันไม่ให้แถวที่ key เป็น 0 ไปเขียนทับ document เดียวกันหมด
+	filter, missing := key.Filter(st)
+	if len(missing) > 0 {
+		for _, f := range missing {
+			e := loc.rowError(f, item[f], "key is missing, row rejected")
+			p.addRowError(e)
+			p.addUnkeyed(e)
+		}
+		p.result.Rejected++
+		return stagedRow{}, false
+	}
+	keyValue := key.Value(st)
+	if first, dup := p.seen[keyValue]; dup {
+		p.addRowError(loc.rowError(key.String(), keyValue, fmt.Sprintf("duplicate key, first seen in %s, row rejected", first)))
+		p.result.Rejected++
+		return stagedRow{}, false
+	}
+	p.seen[keyValue] = loc
+
+	// นับ corrupted
+	if strings.Contains(st.Comment, "[corrupted]") {
+		p.result.Corrupted++
+		p.addDiff(&p.diff.Corrupted, dto.StationDiff{StationCode: st.StationCode, Name: st.Name, Comment: st.Comment})
+	}
+
+	return stagedRow{loc: loc, station: st, filter: filter, key: keyValue}, true
+}
+
+// prefetch ดึง document เดิมของทั้ง batch ด้วย $in ครั้งเดียว คืน map จาก key ของแถวไปยัง document
+func (p *importPipeline) prefetch(ctx context.Context, batch []stagedRow) (map[string]map[string]interface{}, error) {
+	key := p.svc.key
+
+	//prefetch ด้วย field แรกของ key แล้วจับคู่ด้วย key เต็มใน memory (รองรับ composite key)
+	t := time.Now()
+	values := make([]interface{}, 0, len(batch))
+	for _, r := range batch {
+		values = append(values, r.filter[key.Fields[0]])
+	}
+	docs, err := p.svc.repo.FindDocumentsIn(ctx, key.Fields[0], values)
+	p.timings.prefetch += time.Since(t)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]map[string]interface{}, len(docs))
+	for _, doc := range docs {
+		existing[key.DocValue(doc)] = doc
+	}
+	return existing, nil
+}
+
+// rejectDeleted ปฏิเสธแถวที่ key ตรงกับสถานีที่ถูกลบ (soft delete) คืน true ถ้าแถวถูกปฏิเสธ
+// สถานีที่ถูกลบต้องคืนสถานีก่อน ไม่อย่างนั้นจะแก้ข้อมูลที่ยังซ่อนอยู่และถูก purge ทิ้งภายหลัง
+func (p *importPipeline) rejectDeleted(r stagedRow, existingDoc map[string]interface{}) bool {
+	if existingDoc == nil || !repositories.IsDeletedDocument(existingDoc) {
+		return false
+	}
+	p.addRowError(r.loc.rowError(p.svc.key.String(), r.key, fmt.Sprintf("station %d is deleted, restore it before importing, row rejected", r.station.StationCode)))
+	p.result.Rejected++
+	return true
+}
+
+// checkDeleted ใช้ตอน validate รอบแรกของ strict mode ปฏิเสธแถวของสถานีที่ถูกลบแบบเดียวกับ flush
+// strict จะได้ไม่ผ่านรอบแรกแล้วกลายเป็น import บางส่วนตอนเขียนจริง
+func (p *importPipeline) checkDeleted(ctx context.Context, batch []stagedRow) error {
+	existing, err := p.prefetch(ctx, batch)
+	if err != nil {
+		return err
+	}
+	for _, r := range batch {
+		p.rejectDeleted(r, existing[r.key])
+	}
+	return nil
+}
+
+// flush prefetch document เดิมของทั้ง batch เทียบใน memory แล้ว bulk write
+func (p *importPipeline) flush(ctx context.Context, batch []stagedRow) error {
+	existing, err := p.prefetch(ctx, batch)
+	if err != nil {
+		return err
+	}
+
+	t := time.Now()
+	upserts := make([]repositories.StationUpsert, 0, len(batch))
+	var stationChanges []models.StationChange
+	for _, r := range batch {
+		existingDoc := existing[r.key]
+		if p.rejectDeleted(r, existingDoc) {
+			continue
+		}
+
+		//เทียบกับ document เดิม ได้ field ที่เปลี่ยนพร้อมค่าเก่า/ใหม่
+		changes := utils.StationChanges(r.station, existingDoc)
+		if existingDoc != nil {
+			for _, f := range r.insertOnly {
+				delete(changes, f)
+			}
+		}
+		entry := dto.StationDiff{StationCode: r.station.StationCode, Name: r.station.Name}
+		switch {
+		case existingDoc == nil:
+			p.addDiff(&p.diff.Inserted, entry)
+		case len(changes) == 0:
+			p.result.Unchanged++
+			p.addDiff(&p.diff.Unchanged, entry)
+		default:
+			entry.Changes = changes
+			p.addDiff(&p.diff.Updated, entry)
+		}
+
+		//update or insert ถ้ามีข้อมูลตรงตาม filter ก็ update แต่ถ้าไม่มีก็ insert
+		if len(changes) > 0 {
+			updateData := make(map[string]interface{}, len(changes))
+			for k, c := range changes {
+				updateData[k] = c.New
+			}
+			upserts = append(upserts, repositories.StationUpsert{Filter: r.filter, Fields: updateData})
+
+			if !p.opts.DryRun {
+				change := models.StationChange{StationCode: r.station.StationCode, Key: r.filter, Action: models.ChangeInsert, After: updateData}
+				if existingDoc != nil {
+					change.Action = models.ChangeUpdate
+					change.Before = make(map[string]interface{}, len(changes))
+					for k, c := range changes {
+						change.Before[k] = c.Old
+					}
+				}
+				stationChanges = append(stationChanges, change)
+			}
+		}
+	}
+	p.timings.diff += time.Since(t)
+	p.result.TotalImport += len(upserts)
+
+	//dry run ไม่เขียนอะไรลง database นับจากผล diff แทน
+	if p.opts.DryRun {
+		p.result.Inserted = len(p.diff.Inserted)
+		p.result.Updated = len(p.diff.Updated)
+		return nil
+	}
+
+	//ส่ง upserts ของ batch นี้ไปทำ bulk write ครั้งเดียว
+	//บันทึก changeset ก่อนเขียน ถ้าเขียนไม่สำเร็จ rollback ก็แค่ใส่ค่าเดิมซ้ำ
+	//ส่วนประวัติของสถานีบันทึกหลังเขียนสำเร็จ จะได้มีเฉพาะสิ่งที่เปลี่ยนจริง
+	if len(upserts) > 0 {
+		if err := p.recordChanges(ctx, stationChanges); err != nil {
+			return err
+		}
+		t = time.Now()
+		res, err := p.svc.repo.BulkUpsert(ctx, upserts)
+		p.timings.write += time.Since(t)
+		if err != nil {
+			return err
+		}
+		p.progress.addWritten(len(upserts))
+		p.result.Inserted += res.Inserted
+		p.result.Updated += res.Updated
+		if err := p.recordHistory(ctx, stationChanges); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addDiff เก็บรายการลงรายงาน diff เฉพาะตอน dry run
+// import จริงไม่ต้องเก็บ จะได้ไม่กิน memory ตอนไฟล์ใหญ่
+func (p *importPipeline) addDiff(list *[]dto.StationDiff, entry dto.StationDiff) {
+	if p.opts.DryRun {
+		*list = append(*list, entry)
+	}
+}
+
+// isBlankItem คือแถวที่ทุกค่าเป็น nil หรือข้อความว่าง
+func isBlankItem(item map[string]interface{}) bool {
+	for _, v := range item {
+		switch val := v.(type) {
+		case nil:
+		case string:
+			if strings.TrimSpace(val) != "" {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// addRowError เพิ่ม error ลงในรายงาน โดยเก็บรายละเอียดไม่เกิน MaxReportedRowErrors รายการ
+func (p *importPipeline) addRowError(e dto.RowError) {
+	p.result.ErrorCount++
+	if len(p.result.Errors) < dto.MaxReportedRowErrors {
+		p.result.Errors = append(p.result.Errors, e)
+	}
+}
+
+// addUnkeyed เก็บแถวที่ถูกปฏิเสธก่อนรู้ key (เก็บสูงสุด MaxReportedRowErrors รายการ)
+func (p *importPipeline) addUnkeyed(e dto.RowError) {
+	if len(p.unkeyed) < dto.MaxReportedRowErrors {
+		p.unkeyed = append(p.unkeyed, e)
+	}
+}
+
+func (t *phaseTimings) toDTO(started time.Time) *dto.ImportTimings {
+	return &dto.ImportTimings{
+		ParseMS:    t.parse.Milliseconds(),
+		ValidateMS: t.validate.Milliseconds(),
+		PrefetchMS: t.prefetch.Milliseconds(),
+		DiffMS:     t.diff.Milliseconds(),
+		WriteMS:    t.write.Milliseconds(),
+		TotalMS:    time.Since(started).Milliseconds(),
+	}
+}
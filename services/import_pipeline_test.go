@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Teneieiza/go-spinsolf-test/dto"
+	"github.com/Teneieiza/go-spinsolf-test/repositories"
+	"github.com/Teneieiza/go-spinsolf-test/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestImportProfileSwappedColumns(t *testing.T) {
+	profile := &utils.MappingProfile{Name: "swap", Columns: map[string]string{"code": "station_code", "station_code": "id"}}
+	f := newImportFixture(t, map[string]*utils.MappingProfile{"swap": profile})
+
+	res, err := f.importCSV(t, "code,station_code,name,lat,long\n1001,55,กรุงเทพ,13.74,100.51\n", ImportOptions{Profile: profile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Inserted != 1 || res.Rejected != 0 {
+		t.Fatalf("inserted %d rejected %d, errors %v", res.Inserted, res.Rejected, res.Errors)
+	}
+	st, err := f.stations.FindByCode(context.Background(), 1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.StationID != 55 {
+		t.Errorf("id = %d, want 55", st.StationID)
+	}
+}
+
+const threeStations = "station_code,name,lat,long,active\n1001,กรุงเทพ,13.74,100.51,1\n1002,สามเสน,13.78,100.51,1\n1003,บางซื่อ,13.80,100.54,1\n"
+
+func TestImportSyncAbortsOnUnkeyedRows(t *testing.T) {
+	f := newImportFixture(t, nil)
+	if _, err := f.importCSV(t, threeStations, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	//พิมพ์ station_code ของ 1002 ผิด ถ้าไม่หยุด 1002 จะถูกปิดทั้งที่ยังอยู่ในไฟล์
+	file := "station_code,name,lat,long,active\n1001,กรุงเทพ,13.74,100.51,1\n10O2,สามเสน,13.78,100.51,1\n1003,บางซื่อ,13.80,100.54,1\n"
+	res, err := f.importCSV(t, file, ImportOptions{Sync: true})
+	if !errors.Is(err, ErrSyncUnkeyedRows) {
+		t.Fatalf("err = %v, want ErrSyncUnkeyedRows", err)
+	}
+	//header อยู่แถว 1 แถวที่ผิดจึงเป็นแถว 3 ของไฟล์
+	if len(res.UnkeyedRows) != 1 || res.UnkeyedRows[0].Row != 3 {
+		t.Errorf("unkeyed rows = %+v, want row 3", res.UnkeyedRows)
+	}
+	st, err := f.stations.FindByCode(context.Background(), 1002)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Active != 1 {
+		t.Errorf("station 1002 was deactivated")
+	}
+}
+
+func TestImportSyncIgnoresBlankRows(t *testing.T) {
+	f := newImportFixture(t, nil)
+	ctx := context.Background()
+	if _, err := f.importCSV(t, threeStations, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	//ไฟล์ที่ export จาก spreadsheet มักมีแถวว่างกลาง sheet และแถวท้าย sheet ที่มีแค่ format
+	x := excelize.NewFile()
+	x.SetSheetRow("Sheet1", "A1", &[]interface{}{"station_code", "name", "lat", "long", "active"})
+	x.SetSheetRow("Sheet1", "A2", &[]interface{}{1001, "กรุงเทพ", 13.74, 100.51, 1})
+	x.SetSheetRow("Sheet1", "A4", &[]interface{}{1002, "สามเสน", 13.78, 100.51, 1})
+	x.SetSheetRow("Sheet1", "A5", &[]interface{}{1003, "บางซื่อ", 13.80, 100.54, 1})
+	style, err := x.NewStyle(&excelize.Style{Border: []excelize.Border{{Type: "bottom", Color: "000000", Style: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.SetCellStyle("Sheet1", "A6", "E20", style); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "stations.xlsx")
+	if err := x.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	res, err := f.svc.ImportFileStations(ctx, "stations.xlsx", "", path, ImportOptions{Sync: true}, nil)
+	if err != nil {
+		t.Fatalf("err = %v, unkeyed rows %+v", err, res.UnkeyedRows)
+	}
+	if res.Rejected != 0 || res.Deactivated != 0 || len(res.Errors) != 0 {
+		t.Errorf("rejected %d deactivated %d errors %+v, want blank rows ignored", res.Rejected, res.Deactivated, res.Errors)
+	}
+
+	//รายการที่ไม่มีค่าใน json ก็ไม่นับเป็นแถวที่ไม่มี key
+	path = filepath.Join(t.TempDir(), "stations.json")
+	file := `[{"station_code":1001,"name":"กรุงเทพ","lat":13.74,"long":100.51},{},{"station_code":1002,"name":"สามเสน","lat":13.78,"long":100.51},{"name":" "},{"station_code":1003,"name":"บางซื่อ","lat":13.80,"long":100.54}]`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	res, err = f.svc.ImportFileStations(ctx, "stations.json", "application/json", path, ImportOptions{Sync: true}, nil)
+	if err != nil {
+		t.Fatalf("err = %v, unkeyed rows %+v", err, res.UnkeyedRows)
+	}
+	if res.Rejected != 0 || res.Deactivated != 0 {
+		t.Errorf("rejected %d deactivated %d, want blank items ignored", res.Rejected, res.Deactivated)
+	}
+}
+
+func TestImportSyncMaxRemoveCannotExceedConfig(t *testing.T) {
+	f := newImportFixture(t, nil)
+	if _, err := f.importCSV(t, threeStations, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	//ไฟล์ขาด 1 ใน 3 สถานี (33%) เกินค่า config 10% ส่ง 100 มาก็ต้องไม่ผ่าน
+	file := "station_code,name,lat,long,active\n1001,กรุงเทพ,13.74,100.51,1\n1003,บางซื่อ,13.80,100.54,1\n"
+	if _, err := f.importCSV(t, file, ImportOptions{Sync: true, MaxRemovePercent: 100}); !errors.Is(err, ErrSyncThreshold) {
+		t.Fatalf("err = %v, want ErrSyncThreshold", err)
+	}
+
+	//ค่าที่น้อยกว่า config ยังใช้ได้
+	f.svc.syncMaxRemove = 50
+	if _, err := f.importCSV(t, file, ImportOptions{Sync: true, MaxRemovePercent: 20}); !errors.Is(err, ErrSyncThreshold) {
+		t.Fatalf("err = %v, want ErrSyncThreshold with max_remove_percent below config", err)
+	}
+	res, err := f.importCSV(t, file, ImportOptions{Sync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Deactivated != 1 {
+		t.Errorf("deactivated = %d, want 1", res.Deactivated)
+	}
+}
+
+func TestImportRejectsSoftDeletedStation(t *testing.T) {
+	f := newImportFixture(t, nil)
+	ctx := context.Background()
+	if _, err := f.importCSV(t, threeStations, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.stations.SoftDelete(ctx, 1002, time.Now(), "tester"); err != nil {
+		t.Fatal(err)
+	}
+
+	file := "station_code,name,lat,long,active\n1001,กรุงเทพ,13.74,100.51,1\n1002,สามเสนใหม่,13.78,100.51,1\n"
+	res, err := f.importCSV(t, file, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Rejected != 1 || res.Updated != 0 || len(res.Errors) != 1 {
+		t.Fatalf("rejected %d updated %d errors %v, want the deleted station rejected", res.Rejected, res.Updated, res.Errors)
+	}
+	st, err := f.stations.FindDeletedByCode(ctx, 1002)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Name != "สามเสน" {
+		t.Errorf("deleted station was updated to %q", st.Name)
+	}
+}
+
+func TestImportStrictRejectsSoftDeletedStation(t *testing.T) {
+	f := newImportFixture(t, nil)
+	ctx := context.Background()
+	if _, err := f.importCSV(t, threeStations, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.stations.SoftDelete(ctx, 1002, time.Now(), "tester"); err != nil {
+		t.Fatal(err)
+	}
+
+	//แถวอื่นถูกต้องทั้งหมด strict ต้องไม่เขียนอะไรเลยเพราะ 1002 ถูกลบ
+	file := "station_code,name,lat,long,active\n1001,กรุงเทพใหม่,13.74,100.51,1\n1002,สามเสนใหม่,13.78,100.51,1\n1004,ใหม่,13.90,100.60,1\n"
+	res, err := f.importCSV(t, file, ImportOptions{Strict: true})
+	if !errors.Is(err, ErrImportValidation) {
+		t.Fatalf("err = %v, want ErrImportValidation", err)
+	}
+	if len(res.Errors) != 1 || res.Errors[0].Row != 3 {
+		t.Errorf("errors = %+v, want the deleted station at row 3", res.Errors)
+	}
+	st, err := f.stations.FindByCode(ctx, 1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Name != "กรุงเทพ" {
+		t.Errorf("station 1001 was updated to %q in a rejected strict import", st.Name)
+	}
+	if _, err := f.stations.FindByCode(ctx, 1004); !errors.Is(err, repositories.ErrStationNotFound) {
+		t.Errorf("station 1004 was inserted in a rejected strict import (err %v)", err)
+	}
+}
+
+func TestImportReportsSourceRowNumbers(t *testing.T) {
+	f := newImportFixture(t, nil)
+
+	//แถวชื่อรายงาน 2 แถว header อยู่แถว 3 และบรรทัดว่างก่อนแถวที่ผิด
+	file := "รายงานสถานี,,,,\nปรับปรุง 2026,,,,\n" +
+		"station_code,name,lat,long,active\n" +
+		"1001,กรุงเทพ,13.74,100.51,1\n\n" +
+		"1002,สามเสน,abc,100.51,1\n"
+	res, err := f.importCSV(t, file, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.HeaderRow != 3 {
+		t.Errorf("header row = %d, want 3", res.HeaderRow)
+	}
+	if len(res.Errors) != 1 || res.Errors[0].Row != 6 || res.Errors[0].Column != "lat" {
+		t.Errorf("errors = %+v, want lat at row 6", res.Errors)
+	}
+
+	//xlsx นับแยกแต่ละ sheet ตามเลขแถวที่เห็นใน sheet
+	x := excelize.NewFile()
+	x.SetSheetRow("Sheet1", "A1", &[]interface{}{"station_code", "name", "lat", "long"})
+	x.SetSheetRow("Sheet1", "A2", &[]interface{}{2001, "ดอนเมือง", "bad", 100.6})
+	x.NewSheet("North")
+	x.SetSheetRow("North", "A1", &[]interface{}{"สถานีภาคเหนือ"})
+	x.SetSheetRow("North", "A2", &[]interface{}{"station_code", "name", "lat", "long"})
+	x.SetSheetRow("North", "A3", &[]interface{}{3001, "เชียงใหม่", 18.78, 98.99})
+	x.SetSheetRow("North", "A4", &[]interface{}{3002, "ลำปาง", 18.28, "bad"})
+	path := filepath.Join(t.TempDir(), "stations.xlsx")
+	if err := x.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	res, err = f.svc.ImportFileStations(context.Background(), "stations.xlsx", "", path, ImportOptions{AllSheets: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []dto.RowError{
+		{Sheet: "Sheet1", Row: 2, Column: "lat"},
+		{Sheet: "North", Row: 4, Column: "long"},
+	}
+	if len(res.Errors) != len(want) {
+		t.Fatalf("errors = %+v, want %+v", res.Errors, want)
+	}
+	for i, w := range want {
+		if e := res.Errors[i]; e.Sheet != w.Sheet || e.Row != w.Row || e.Column != w.Column {
+			t.Errorf("error %d = %+v, want %s at row %d of sheet %s", i, e, w.Column, w.Row, w.Sheet)
+		}
+	}
+}
+
+// failingStations ทำให้ BulkUpsert ครั้งที่ failAt ล้มเหลว
+type failingStations struct {
+	*repositories.MemoryStationRepository
+	calls, failAt int
+}
+
+func (r *failingStations) BulkUpsert(ctx context.Context, upserts []repositories.StationUpsert) (*repositories.UpsertResult, error) {
+	r.calls++
+	if r.calls == r.failAt {
+		return nil, errors.New("write failed")
+	}
+	return r.MemoryStationRepository.BulkUpsert(ctx, upserts)
+}
+
+func TestImportFailureKeepsChangeset(t *testing.T) {
+	key, err := utils.ParseStationKey("station_code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stations := &failingStations{MemoryStationRepository: repositories.NewMemoryStationRepository(), failAt: 2}
+	history := NewStationHistoryService(repositories.NewMemoryStationHistoryRepository(), stations)
+	svc := NewImportStationService(stations, repositories.NewMemoryImportChangesetRepository(), history, key, 1, "", nil, nil, 10)
+
+	path := filepath.Join(t.TempDir(), "stations.csv")
+	if err := os.WriteFile(path, []byte(threeStations), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	res, err := svc.ImportFileStations(context.Background(), "stations.csv", "text/csv", path, ImportOptions{}, nil)
+	if err == nil {
+		t.Fatal("import succeeded, want write error")
+	}
+	//batch แรกเขียนไปแล้ว ต้องรู้ changeset ไว้ rollback
+	if res == nil || res.ChangesetID == "" || res.Inserted != 1 {
+		t.Fatalf("result = %+v, want changeset id and 1 inserted", res)
+	}
+}
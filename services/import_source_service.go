@@ -0,0 +1,480 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Teneieiza/go-spinsolf-test/dto"
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"github.com/Teneieiza/go-spinsolf-test/parsers"
+	"github.com/Teneieiza/go-spinsolf-test/repositories"
+	"github.com/Teneieiza/go-spinsolf-test/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ที่มาของการรัน import source
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// SourceRunNotModified สถานะของการรันที่ข้ามไปเพราะ server ตอบว่าข้อมูลไม่เปลี่ยน (304)
+const SourceRunNotModified = "not_modified"
+
+var (
+	// ErrInvalidImportSource ใช้เมื่อข้อมูลของ import source ไม่ถูกต้อง
+	ErrInvalidImportSource = errors.New("invalid import source")
+	// ErrImportSourceRunning ใช้เมื่อสั่งรัน source ที่ยังรันครั้งก่อนไม่จบ
+	ErrImportSourceRunning = errors.New("import source is already running")
+)
+
+// ImportSourceService จัดการ import source ที่เก็บไว้ใน database และรันตาม cron ของแต่ละ source
+// การรันแต่ละครั้งเป็น import job ปกติ (ดูผลได้ที่ /import-jobs) และบันทึกประวัติไว้ใน repository
+// ส่ง If-None-Match/If-Modified-Since จากครั้งล่าสุดที่สำเร็จ ถ้าข้อมูลไม่เปลี่ยนจะไม่ import ซ้ำ
+type ImportSourceService struct {
+	repo     repositories.ImportSourceRepository
+	importer *ImportStationService
+	jobs     *ImportJobService
+	tick     time.Duration
+
+	mu      sync.Mutex                    // กันไม่ให้ scheduler กับ API เขียน source ทับกัน
+	running map[primitive.ObjectID]string // source id -> job id ของการรันล่าสุด
+	ctx     context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewImportSourceService สร้าง service tick คือความถี่ที่ scheduler ตรวจว่ามี source ไหนถึงเวลารัน
+func NewImportSourceService(repo repositories.ImportSourceRepository, importer *ImportStationService, jobs *ImportJobService, tick time.Duration) *ImportSourceService {
+	if tick <= 0 {
+		tick = 30 * time.Second
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	return &ImportSourceService{
+		repo:     repo,
+		importer: importer,
+		jobs:     jobs,
+		tick:     tick,
+		running:  make(map[primitive.ObjectID]string),
+		ctx:      ctx,
+		stop:     stop,
+	}
+}
+
+// Start เริ่ม scheduler ใน goroutine แยก
+func (s *ImportSourceService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.tick)
+		defer ticker.Stop()
+
+		s.runDue()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				s.runDue()
+			}
+		}
+	}()
+}
+
+// Shutdown หยุด scheduler (job ที่ส่งไปแล้วจะถูกจัดการโดย ImportJobService)
+func (s *ImportSourceService) Shutdown() {
+	s.stop()
+	s.wg.Wait()
+}
+
+// runDue ส่ง source ที่ถึงเวลารันเข้า job แล้วเลื่อนเวลารันครั้งถัดไป
+func (s *ImportSourceService) runDue() {
+	ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+	defer cancel()
+
+	sources, err := s.repo.List(ctx)
+	if err != nil {
+		log.Printf("import scheduler: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, src := range sources {
+		if !src.Enabled {
+			continue
+		}
+		if src.NextRunAt != nil && src.NextRunAt.After(now) {
+			continue
+		}
+		if err := s.runIfDue(ctx, src.ID, now); err != nil {
+			log.Printf("import scheduler: source %s (%s): %v", src.Name, src.ID.Hex(), err)
+		}
+	}
+}
+
+// runIfDue อ่าน source ล่าสุดขณะถือ mu แล้วรันถ้ายังเปิดอยู่และถึงเวลา
+// รายการจาก List อาจเก่าแล้ว (source ถูกปิด แก้ไข หรือลบระหว่างนั้น) จึงใช้แค่เลือกว่าจะดู source ไหน
+func (s *ImportSourceService) runIfDue(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, err := s.repo.Get(ctx, id)
+	if errors.Is(err, repositories.ErrImportSourceNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !src.Enabled || (src.NextRunAt != nil && src.NextRunAt.After(now)) {
+		return nil
+	}
+
+	//source ที่ไม่เคยตั้งเวลา (NextRunAt ว่าง) แค่ตั้งเวลา ไม่รันทันที
+	if src.NextRunAt != nil {
+		if _, err := s.startLocked(src, TriggerSchedule, "scheduler"); err != nil {
+			log.Printf("import scheduler: source %s (%s): %v", src.Name, src.ID.Hex(), err)
+		}
+	}
+	src.NextRunAt = nextRun(src, now)
+	err = s.repo.Replace(ctx, src)
+	if errors.Is(err, repositories.ErrImportSourceNotFound) {
+		return nil
+	}
+	return err
+}
+
+// nextRun เวลารันครั้งถัดไปหลัง after คืน nil ถ้าปิดอยู่หรือ schedule ไม่ถูกต้อง
+func nextRun(src *models.ImportSource, after time.Time) *time.Time {
+	if !src.Enabled {
+		return nil
+	}
+	sched, err := utils.ParseCron(src.Schedule)
+	if err != nil {
+		return nil
+	}
+	next := sched.Next(after)
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// List ดึง source ทั้งหมด (ซ่อน token และรหัสผ่าน)
+func (s *ImportSourceService) List() ([]models.ImportSource, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	list, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].Auth = list[i].Auth.Redacted()
+	}
+	return list, nil
+}
+
+// Get ดึง source ตาม id (ซ่อน token และรหัสผ่าน)
+func (s *ImportSourceService) Get(id string) (*models.ImportSource, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	src, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	src.Auth = src.Auth.Redacted()
+	return src, nil
+}
+
+func (s *ImportSourceService) get(ctx context.Context, id string) (*models.ImportSource, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, repositories.ErrImportSourceNotFound
+	}
+	return s.repo.Get(ctx, oid)
+}
+
+// Create ตรวจข้อมูลแล้วสร้าง source ใหม่ ตั้งเวลารันครั้งแรกตาม schedule
+func (s *ImportSourceService) Create(req dto.ImportSourceRequest) (*models.ImportSource, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	src := &models.ImportSource{Enabled: true}
+	if err := s.apply(src, req); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	src.CreatedAt, src.UpdatedAt = now, now
+	src.NextRunAt = nextRun(src, now)
+
+	if err := s.repo.Insert(ctx, src); err != nil {
+		return nil, err
+	}
+	src.Auth = src.Auth.Redacted()
+	return src, nil
+}
+
+// Update แก้ไข source ถ้าเปลี่ยน URL จะล้าง ETag/Last-Modified เดิม และคำนวณเวลารันครั้งถัดไปใหม่
+func (s *ImportSourceService) Update(id string, req dto.ImportSourceRequest) (*models.ImportSource, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	oldURL := src.URL
+	if err := s.apply(src, req); err != nil {
+		return nil, err
+	}
+	if src.URL != oldURL {
+		src.ETag, src.LastModified = "", ""
+	}
+	now := time.Now()
+	src.UpdatedAt = now
+	src.NextRunAt = nextRun(src, now)
+
+	if err := s.repo.Replace(ctx, src); err != nil {
+		return nil, err
+	}
+	src.Auth = src.Auth.Redacted()
+	return src, nil
+}
+
+// Delete ลบ source และประวัติการรัน
+func (s *ImportSourceService) Delete(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return repositories.ErrImportSourceNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.repo.Delete(ctx, oid)
+}
+
+// Runs ดึงประวัติการรันของ source เรียงจากใหม่ไปเก่า
+func (s *ImportSourceService) Runs(id string, limit int) ([]models.ImportSourceRun, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	src, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListRuns(ctx, src.ID, limit)
+}
+
+// RunNow สั่งรัน source ทันทีโดยไม่สนเวลาใน schedule (รันได้แม้ source จะปิดอยู่) actor บันทึกไว้ใน changeset
+func (s *ImportSourceService) RunNow(id, actor string) (*dto.ImportJobResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	src, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.startLocked(src, TriggerManual, actor)
+}
+
+// apply ตรวจ req แล้วใส่ค่าลงใน src (ต้องเรียกก่อนบันทึกทุกครั้ง)
+func (s *ImportSourceService) apply(src *models.ImportSource, req dto.ImportSourceRequest) error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidImportSource, fmt.Sprintf(format, args...))
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return invalid("name is required")
+	}
+	if req.URL == "" {
+		return invalid("url is required")
+	}
+	if err := s.importer.CheckURL(req.URL); err != nil {
+		return invalid("%v", err)
+	}
+	if _, err := utils.ParseCron(req.Schedule); err != nil {
+		return invalid("%v", err)
+	}
+	format := strings.ToLower(strings.TrimSpace(req.Format))
+	if format == "auto" {
+		format = ""
+	}
+	if format != "" && !parsers.IsFormat(format) {
+		return invalid("unsupported format %q use one of %s", req.Format, strings.Join(parsers.Formats, ", "))
+	}
+	if req.Profile != "" {
+		if _, err := s.importer.Profile(req.Profile); err != nil {
+			return invalid("%v", err)
+		}
+	}
+	if req.Sheet != "" && req.AllSheets {
+		return invalid("use either sheet or all_sheets, not both")
+	}
+	if _, err := parsers.ParseEncoding(req.Encoding); err != nil {
+		return invalid("%v", err)
+	}
+	if _, err := parsers.ParseDelimiter(req.Delimiter); err != nil {
+		return invalid("%v", err)
+	}
+	mode := strings.ToLower(strings.TrimSpace(req.Mode))
+	if mode != "" && mode != ImportModeUpsert && mode != ImportModeSync {
+		return invalid("mode must be upsert or sync")
+	}
+	if req.MaxRemovePercent < 0 || req.MaxRemovePercent > 100 {
+		return invalid("max_remove_percent must be between 0 and 100")
+	}
+	if err := CheckAuth(req.Auth); err != nil {
+		return invalid("%v", err)
+	}
+
+	src.Name, src.URL, src.Format, src.Profile, src.Schedule = name, req.URL, format, req.Profile, strings.TrimSpace(req.Schedule)
+	src.Strict, src.Sheet, src.AllSheets = req.Strict, req.Sheet, req.AllSheets
+	src.Encoding, src.Delimiter = req.Encoding, req.Delimiter
+	src.Mode, src.MaxRemovePercent = mode, req.MaxRemovePercent
+	if req.Enabled != nil {
+		src.Enabled = *req.Enabled
+	}
+	if req.Auth != nil {
+		src.Auth = req.Auth
+		if t := strings.ToLower(req.Auth.Type); t == "" || t == "none" {
+			src.Auth = nil
+		}
+	}
+	return nil
+}
+
+// startLocked ส่งการรันของ src เข้า job (ต้องถือ mu ก่อนเรียก)
+// ถ้าการรันครั้งก่อนยังไม่จบคืน ErrImportSourceRunning
+func (s *ImportSourceService) startLocked(src *models.ImportSource, trigger, actor string) (*dto.ImportJobResponse, error) {
+	if jobID, ok := s.running[src.ID]; ok {
+		if job, err := s.jobs.Get(jobID); err == nil && (job.State == JobQueued || job.State == JobRunning) {
+			return nil, ErrImportSourceRunning
+		}
+		delete(s.running, src.ID)
+	}
+
+	id, name := src.ID, src.Name
+	job, err := s.jobs.Submit("source", name, func(ctx context.Context, progress *ImportProgress) (*dto.ImportStationResponse, error) {
+		return s.runSource(ctx, id, trigger, actor, progress)
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.running[id] = job.ID
+	return job, nil
+}
+
+// runSource รัน source 1 ครั้ง บันทึกประวัติ และอัปเดตสถานะล่าสุดของ source
+func (s *ImportSourceService) runSource(ctx context.Context, id primitive.ObjectID, trigger, actor string, progress *ImportProgress) (*dto.ImportStationResponse, error) {
+	s.mu.Lock()
+	run := models.ImportSourceRun{SourceID: id, JobID: s.running[id], Trigger: trigger, StartedAt: time.Now()}
+	s.mu.Unlock()
+
+	var fetched *FetchedFile
+	src, err := s.repo.Get(ctx, id)
+	var res *dto.ImportStationResponse
+	if err == nil {
+		res, fetched, err = s.importSource(ctx, src, actor, progress)
+	}
+
+	switch {
+	case errors.Is(err, ErrNotModified):
+		run.Status = SourceRunNotModified
+		res, err = &dto.ImportStationResponse{Status: 200, Message: "Source not modified since last import"}, nil
+	case err == nil:
+		run.Status = JobSucceeded
+	case errors.Is(err, context.Canceled):
+		run.Status = JobCancelled
+		run.Error = err.Error()
+	default:
+		run.Status = JobFailed
+		run.Error = err.Error()
+	}
+	if res != nil {
+		run.Inserted, run.Updated, run.Unchanged, run.Rejected = res.Inserted, res.Updated, res.Unchanged, res.Rejected
+		//sync ที่ถูกยกเลิกเพราะเกิน threshold รายงานจำนวนที่จะปิด แต่ยังไม่ได้ปิดจริง
+		if err == nil {
+			run.Deactivated = res.Deactivated
+		}
+	}
+	run.FinishedAt = time.Now()
+
+	//บันทึกด้วย context ใหม่ เพราะ ctx อาจถูก cancel ไปแล้ว
+	saveCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if e := s.repo.AddRun(saveCtx, &run); e != nil {
+		log.Printf("import source %s: save run: %v", id.Hex(), e)
+	}
+
+	s.mu.Lock()
+	e := s.updateLocked(saveCtx, id, func(latest *models.ImportSource) {
+		latest.LastRunAt, latest.LastStatus = &run.FinishedAt, run.Status
+		//เก็บ validator เฉพาะตอน import สำเร็จ ครั้งที่ล้มเหลวจะได้ดึงใหม่ทั้งหมดในครั้งถัดไป
+		if run.Status == JobSucceeded && fetched != nil && latest.URL == src.URL {
+			latest.ETag, latest.LastModified = fetched.ETag, fetched.LastModified
+		}
+	})
+	s.mu.Unlock()
+	if e != nil && !errors.Is(e, repositories.ErrImportSourceNotFound) {
+		log.Printf("import source %s: update status: %v", id.Hex(), e)
+	}
+
+	return res, err
+}
+
+// importSource ดาวน์โหลดข้อมูลของ src (ส่ง validator จากครั้งก่อนไปด้วย) แล้ว import
+func (s *ImportSourceService) importSource(ctx context.Context, src *models.ImportSource, actor string, progress *ImportProgress) (*dto.ImportStationResponse, *FetchedFile, error) {
+	opts := ImportOptions{Strict: src.Strict, Sheet: src.Sheet, AllSheets: src.AllSheets,
+		Sync: src.Mode == ImportModeSync, MaxRemovePercent: src.MaxRemovePercent, Actor: actor}
+	if src.Profile != "" {
+		profile, err := s.importer.Profile(src.Profile)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts.Profile = profile
+	}
+	enc, err := parsers.ParseEncoding(src.Encoding)
+	if err != nil {
+		return nil, nil, err
+	}
+	if enc != parsers.EncodingAuto {
+		opts.Encoding = enc
+	}
+	if opts.Delimiter, err = parsers.ParseDelimiter(src.Delimiter); err != nil {
+		return nil, nil, err
+	}
+
+	file, err := s.importer.fetcher.Fetch(ctx, src.URL, src.Auth, &CacheValidators{ETag: src.ETag, LastModified: src.LastModified})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.Remove(file.Path)
+
+	res, err := s.importer.importPath(ctx, file.Path, file.Filename, file.ContentType, src.Format, opts, progress)
+	return res, file, err
+}
+
+// updateLocked อ่าน source ล่าสุด แก้ด้วย fn แล้วบันทึก (ต้องถือ mu ก่อนเรียก)
+func (s *ImportSourceService) updateLocked(ctx context.Context, id primitive.ObjectID, fn func(src *models.ImportSource)) error {
+	latest, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	fn(latest)
+	return s.repo.Replace(ctx, latest)
+}
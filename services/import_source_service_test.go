@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Teneieiza/go-spinsolf-test/dto"
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"github.com/Teneieiza/go-spinsolf-test/repositories"
+)
+
+// staleSourceRepo คืนรายการ source ที่อ่านไว้ก่อนหน้าจาก List เหมือน source ถูกแก้ระหว่างที่ scheduler วนรายการอยู่
+type staleSourceRepo struct {
+	*repositories.MemoryImportSourceRepository
+	snapshot []models.ImportSource
+}
+
+func (r *staleSourceRepo) List(ctx context.Context) ([]models.ImportSource, error) {
+	return r.snapshot, nil
+}
+
+func TestRunDueUsesLatestSource(t *testing.T) {
+	ctx := context.Background()
+
+	//ให้ worker ตัวเดียวติดอยู่กับ job อื่น source ที่ถูกส่งเข้าคิวจะยังไม่รันจริง
+	jobs := NewImportJobService(1, 8, 0)
+	started := make(chan struct{})
+	_, err := jobs.Submit("file", "blocker", func(ctx context.Context, _ *ImportProgress) (*dto.ImportStationResponse, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	defer jobs.Shutdown()
+
+	repo := repositories.NewMemoryImportSourceRepository()
+	past := time.Now().Add(-time.Minute)
+	ids := map[string]*models.ImportSource{}
+	for _, name := range []string{"edited", "disabled", "deleted", "rescheduled"} {
+		src := &models.ImportSource{Name: name, URL: "https://example.com/" + name + ".csv", Schedule: "@hourly", Enabled: true, NextRunAt: &past}
+		if err := repo.Insert(ctx, src); err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = src
+	}
+	stale := &staleSourceRepo{MemoryImportSourceRepository: repo}
+	if stale.snapshot, err = repo.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	//แก้ source หลังจาก scheduler อ่านรายการไปแล้ว
+	edit := func(name string, fn func(src *models.ImportSource)) {
+		src, err := repo.Get(ctx, ids[name].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn(src)
+		if err := repo.Replace(ctx, src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	edit("edited", func(src *models.ImportSource) { src.Name = "edited v2" })
+	edit("disabled", func(src *models.ImportSource) { src.Enabled = false })
+	future := time.Now().Add(time.Hour)
+	edit("rescheduled", func(src *models.ImportSource) { src.NextRunAt = &future })
+	if err := repo.Delete(ctx, ids["deleted"].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewImportSourceService(stale, nil, jobs, time.Hour)
+	svc.runDue()
+
+	var ran []string
+	for _, job := range jobs.List() {
+		if job.Type == "source" {
+			ran = append(ran, job.Source)
+		}
+	}
+	sort.Strings(ran)
+	if want := []string{"edited v2"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("scheduled jobs = %v, want %v", ran, want)
+	}
+
+	if _, err := repo.Get(ctx, ids["deleted"].ID); !errors.Is(err, repositories.ErrImportSourceNotFound) {
+		t.Errorf("deleted source was written back (err %v)", err)
+	}
+	disabled, err := repo.Get(ctx, ids["disabled"].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disabled.Enabled {
+		t.Error("disabled source was enabled again by the scheduler")
+	}
+	edited, err := repo.Get(ctx, ids["edited"].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.NextRunAt == nil || !edited.NextRunAt.After(time.Now()) {
+		t.Errorf("edited source next run = %v, want a time in the future", edited.NextRunAt)
+	}
+}
@@ -2,179 +2,312 @@ package services
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
-	"path/filepath"
-	"strings"
-	"time"
+	"os"
+	"path"
+	"sort"
+	"sync/atomic"
 
-	"github.com/Teneieiza/go-spinsolf-test/config"
+	"github.com/Teneieiza/go-spinsolf-test/dto"
+	"github.com/Teneieiza/go-spinsolf-test/models"
 	"github.com/Teneieiza/go-spinsolf-test/parsers"
+	"github.com/Teneieiza/go-spinsolf-test/repositories"
 	"github.com/Teneieiza/go-spinsolf-test/utils"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-// Import ข้อมูลผ่านไฟล์
-func ImportFileStations(filename string, data []byte) (inserted int, updated int, corrupted int, totalImported int, err error) {
-	//เลือก parser ตามนามสกุลของไฟล์
-	//รองรับ .csv .json .xlsx
-	//ถ้าไม่รองรับให้ return error
-	ext := strings.ToLower(filepath.Ext(filename))
-	var parser parsers.Parser
-
-	switch ext {
-	case ".csv":
-		parser = &parsers.CSVParser{}
-	case ".json":
-		parser = &parsers.JSONParser{}
-	case ".xlsx":
-		parser = &parsers.XLSXParser{}
-	default:
-		return 0, 0, 0, 0, errors.New("unsupported file format use file with .csv, .json, .xlsx")
-	}
-
-	//parse data เข้าไปใน raw ซึ่งเป็น slice ของ map[string]interface{}
-	//โดยใช้ parser ที่เลือกมา
-	//raw จะมีโครงสร้างคล้ายๆ กับ []models.Station แต่ยังไม่ใช่
-	var raw []map[string]interface{}
-	if err := parser.Parse(data, &raw); err != nil {
-		return 0, 0, 0, 0, err
-	}
-
-	// Insert เข้า database mongodb
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-	defer cancel()
-	col := config.DB.Collection
-
-	//ประกาศ models สำหรับทำ buckwrite
-	var models []mongo.WriteModel
-
-	for _, item := range raw {
-		st := utils.MapToStation(item)
-
-		// นับ corrupted
-		if strings.Contains(st.Comment, "[corrupted]") {
-			corrupted++
-		}
+// ImportStationService รวม logic การ import ข้อมูลสถานีจากไฟล์และ URL
+// changesets เก็บค่าก่อน/หลังของทุกสถานีที่ import เปลี่ยน ใช้ดูประวัติและ rollback
+// history บันทึกประวัติของแต่ละสถานีหลังเขียนสำเร็จ
+// key คือกฎที่ใช้ระบุว่าแถวไหนคือสถานีเดิม (ดู IMPORT_KEY ใน config)
+// batchSize คือจำนวนแถวต่อรอบของการ prefetch และ bulk write (ดู IMPORT_BATCH_SIZE ใน config)
+// gtfsIDStrategy คือวิธีแปลง id ของ GTFS เป็น station_code (ดู GTFS_ID_STRATEGY ใน config)
+// profiles คือ mapping profile ที่เลือกใช้ได้ด้วย profile= (ดู MAPPING_PROFILES_FILE ใน config)
+// fetcher ใช้ดาวน์โหลดข้อมูลตอน import ผ่าน URL (ดู IMPORT_URL_* ใน config)
+// syncMaxRemove คือ % สูงสุดของสถานีที่ active ที่ mode=sync ปิดได้ในครั้งเดียว (ดู IMPORT_SYNC_MAX_REMOVE_PERCENT ใน config)
+type ImportStationService struct {
+	repo           repositories.StationRepository
+	changesets     repositories.ImportChangesetRepository
+	history        *StationHistoryService
+	key            utils.StationKey
+	batchSize      int
+	gtfsIDStrategy string
+	profiles       map[string]*utils.MappingProfile
+	fetcher        *URLFetcher
+	syncMaxRemove  float64
+}
 
-		// filter station_code เพื่อหาข้อมูลซ้ำ
-		filter := bson.M{"station_code": st.StationCode}
-
-		// query document เดิมจาก DB
-		var existingDoc map[string]interface{}
-		_ = col.FindOne(ctx, filter).Decode(&existingDoc)
-
-		//แปลง Station struct เป็น bson.M
-		updateData := utils.StationToBsonMap(st, existingDoc)
-
-		//สร้าง NewUpdateOneModel ไว้ใน model
-		//filter สร้างเงื่อนไข
-		//field ที่จะให้ update
-		//update or insert ถ้ามีข้อมูลตรงตาม filter ก็ update แต่ถ้าไม่มีก็ insert
-		if len(updateData) > 0 {
-			model := mongo.NewUpdateOneModel().
-				SetFilter(filter).
-				SetUpdate(bson.M{"$set": updateData}).
-				SetUpsert(true) // insert ถ้าไม่มี record
-			models = append(models, model)
-		}
+func NewImportStationService(repo repositories.StationRepository, changesets repositories.ImportChangesetRepository, history *StationHistoryService, key utils.StationKey, batchSize int, gtfsIDStrategy string, profiles map[string]*utils.MappingProfile, fetcher *URLFetcher, syncMaxRemove float64) *ImportStationService {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	if syncMaxRemove <= 0 || syncMaxRemove > 100 {
+		syncMaxRemove = 10
 	}
+	return &ImportStationService{repo: repo, changesets: changesets, history: history, key: key, batchSize: batchSize, gtfsIDStrategy: gtfsIDStrategy, profiles: profiles, fetcher: fetcher, syncMaxRemove: syncMaxRemove}
+}
 
-	//ส่ง models ไปทำ buckwrite ให้ทำ update/insert ทีเดียว
-	//ทำ buckwrite เพื่อลด round-trip
-	if len(models) > 0 {
-		res, err := col.BulkWrite(ctx, models)
-		if err != nil {
-			return 0, 0, 0, corrupted, err
-		}
-		inserted = int(res.UpsertedCount)
-		updated = int(res.ModifiedCount)
-		totalImported = len(models)
+// วิธี import ที่เลือกได้ด้วย mode=
+const (
+	// ImportModeUpsert เพิ่มและแก้ไขสถานีตามไฟล์อย่างเดียว (ค่าเริ่มต้น)
+	ImportModeUpsert = "upsert"
+	// ImportModeSync ถือว่าไฟล์คือข้อมูลทั้งหมด สถานีที่ไม่มีในไฟล์จะถูกปิด (active = 0)
+	ImportModeSync = "sync"
+)
+
+// ImportOptions ตัวเลือกของการ import ที่ส่งมาจาก query param
+type ImportOptions struct {
+	// DryRun parse และเปรียบเทียบกับข้อมูลเดิมอย่างเดียว ไม่เขียนลง database
+	DryRun bool
+	// Strict ถ้ามีแถวไหน validate ไม่ผ่าน จะไม่ import เลยทั้งไฟล์
+	Strict bool
+	// Profile mapping profile ที่ใช้แปลงชื่อ column และค่าก่อน validate (nil คือไม่ใช้)
+	Profile *utils.MappingProfile
+	// Sheet ชื่อหรือลำดับ (เริ่มที่ 0) ของ sheet ใน xlsx/xls/ods ที่จะ import ว่างคือ sheet แรก
+	Sheet string
+	// AllSheets import ทุก sheet ใน xlsx/xls/ods
+	AllSheets bool
+	// Encoding ของไฟล์ csv (ชื่อจาก parsers.ParseEncoding) ว่างคือเดาจากไฟล์
+	Encoding string
+	// Delimiter ตัวคั่นของไฟล์ csv 0 คือเดาจากไฟล์
+	Delimiter rune
+	// Sync ปิดสถานีที่ active อยู่แต่ไม่มีในไฟล์ (mode=sync)
+	Sync bool
+	// MaxRemovePercent % สูงสุดของสถานีที่ active ที่ sync ปิดได้ 0 คือใช้ค่าจาก config
+	// ใช้ได้เฉพาะเมื่อน้อยกว่าค่าจาก config (เข้มขึ้นได้ ผ่อนไม่ได้)
+	MaxRemovePercent float64
+	// Actor คนหรือระบบที่สั่ง import บันทึกไว้ใน changeset
+	Actor string
+}
+
+// Mode ชื่อของวิธี import ที่ใช้รายงานผล
+func (o ImportOptions) Mode() string {
+	if o.Sync {
+		return ImportModeSync
 	}
+	return ImportModeUpsert
+}
 
-	// สร้าง index location 2dsphere หลัง import
-	indexModel := mongo.IndexModel{
-		Keys: bson.M{"location": "2dsphere"},
+var (
+	// ErrImportValidation ใช้เมื่อ strict mode เจอแถวที่ validate ไม่ผ่าน
+	ErrImportValidation = errors.New("import rejected: some rows failed validation")
+	// ErrProfileNotFound ใช้เมื่อ profile= ไม่ตรงกับ profile ที่ตั้งค่าไว้
+	ErrProfileNotFound = errors.New("mapping profile not found")
+	// ErrSyncThreshold ใช้เมื่อ mode=sync จะปิดสถานีมากกว่า % ที่ยอมให้
+	ErrSyncThreshold = errors.New("sync aborted: too many stations would be deactivated")
+	// ErrSyncUnkeyedRows ใช้เมื่อ mode=sync เจอแถวที่อ่าน key ไม่ได้ สถานีของแถวนั้นจะถูกนับว่าไม่มีในไฟล์และถูกปิดผิดตัว
+	ErrSyncUnkeyedRows = errors.New("sync aborted: some rows have no usable key")
+)
+
+// Profile หา mapping profile ตามชื่อ
+func (s *ImportStationService) Profile(name string) (*utils.MappingProfile, error) {
+	p, ok := s.profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
 	}
-	_, _ = col.Indexes().CreateOne(ctx, indexModel)
+	return p, nil
+}
 
-	return inserted, updated, corrupted, totalImported, nil
+// Profiles คืน mapping profile ทั้งหมด เรียงตามชื่อ
+func (s *ImportStationService) Profiles() []*utils.MappingProfile {
+	list := make([]*utils.MappingProfile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
 }
 
-// Import ข้อมูลผ่าน Url
-func ImportUrlStations(apiURL string) (inserted int, updated int, corrupted int, totalImported int, err error) {
-	//ส่ง HTTP GET ไปหา URL เพื่อดึง JSON
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Get(apiURL)
-	if err != nil {
-		return 0, 0, 0, 0, err
+// ImportProgress เก็บความคืบหน้าระหว่าง import
+// อัปเดตจาก goroutine ของ job และถูกอ่านจาก request ที่มาถามสถานะ เลยใช้ atomic
+// ส่ง nil มาได้ถ้าไม่ต้องการติดตามความคืบหน้า
+type ImportProgress struct {
+	rowsParsed  atomic.Int64
+	rowsWritten atomic.Int64
+}
+
+func (p *ImportProgress) addParsed(n int) {
+	if p != nil {
+		p.rowsParsed.Add(int64(n))
 	}
-	defer resp.Body.Close()
+}
 
-	// parse JSON ให้เป็น slice ของ map[string]interface{}
-	var raw []map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
-		return 0, 0, 0, 0, err
+func (p *ImportProgress) addWritten(n int) {
+	if p != nil {
+		p.rowsWritten.Add(int64(n))
 	}
+}
 
-	// Insert เข้า database mongodb
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-	defer cancel()
-	col := config.DB.Collection
+// Snapshot คืนค่าความคืบหน้า ณ ตอนที่เรียก
+func (p *ImportProgress) Snapshot() dto.ImportProgress {
+	if p == nil {
+		return dto.ImportProgress{}
+	}
+	return dto.ImportProgress{
+		RowsParsed:  p.rowsParsed.Load(),
+		RowsWritten: p.rowsWritten.Load(),
+	}
+}
 
-	//ประกาศ models สำหรับทำ buckwrite
-	var models []mongo.WriteModel
+// Import ข้อมูลผ่านไฟล์
+// path คือไฟล์ที่ controller บันทึกไว้ชั่วคราว filename และ contentType มาจากไฟล์ที่ upload ใช้ช่วยเลือก parser
+func (s *ImportStationService) ImportFileStations(ctx context.Context, filename, contentType, path string, opts ImportOptions, progress *ImportProgress) (*dto.ImportStationResponse, error) {
+	return s.importPath(ctx, path, filename, contentType, "", opts, progress)
+}
 
-	for _, item := range raw {
-		st := utils.MapToStation(item)
+// Import ข้อมูลผ่าน Url
+// รองรับทุกรูปแบบเหมือน import ผ่านไฟล์ เลือก parser จากเนื้อข้อมูล Content-Type และชื่อไฟล์ใน URL
+// ดาวน์โหลดผ่าน URLFetcher ซึ่งตรวจ allowlist, IP ภายใน และขนาดของ response
+func (s *ImportStationService) ImportUrlStations(ctx context.Context, apiURL string, auth *models.FetchAuth, opts ImportOptions, progress *ImportProgress) (*dto.ImportStationResponse, error) {
+	//บันทึก response ลงไฟล์ชั่วคราวก่อน จะได้อ่านแบบ stream ได้หลายรอบโดยไม่ต้องเก็บทั้งก้อนไว้ใน memory
+	file, err := s.fetcher.Fetch(ctx, apiURL, auth, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Path)
 
-		// นับ corrupted
-		if strings.Contains(st.Comment, "[corrupted]") {
-			corrupted++
-		}
+	return s.importPath(ctx, file.Path, file.Filename, file.ContentType, "", opts, progress)
+}
 
-		// filter station_code เพื่อหาข้อมูลซ้ำ
-		filter := bson.M{"station_code": st.StationCode}
-
-		// query document เดิมจาก DB
-		var existingDoc map[string]interface{}
-		_ = col.FindOne(ctx, filter).Decode(&existingDoc)
-
-		//แปลง Station struct เป็น bson.M
-		updateData := utils.StationToBsonMap(st, existingDoc)
-
-		//สร้าง NewUpdateOneModel ไว้ใน model
-		//filter สร้างเงื่อนไข
-		//field ที่จะให้ update
-		//update or insert ถ้ามีข้อมูลตรงตาม filter ก็ update แต่ถ้าไม่มีก็ insert
-		if len(updateData) > 0 {
-			model := mongo.NewUpdateOneModel().
-				SetFilter(filter).
-				SetUpdate(bson.M{"$set": updateData}).
-				SetUpsert(true) // insert ถ้าไม่มี record
-			models = append(models, model)
+// CheckURL ตรวจว่า URL อยู่ใน allowlist ก่อนส่งงานเข้า job
+func (s *ImportStationService) CheckURL(apiURL string) error {
+	return s.fetcher.CheckURL(apiURL)
+}
+
+// responseFilename ชื่อไฟล์ของ response จาก Content-Disposition ถ้าไม่มีใช้ส่วนท้ายของ path ใน URL
+func responseFilename(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return params["filename"]
+	}
+	return path.Base(resp.Request.URL.Path)
+}
+
+// importPath import ไฟล์ที่ path ด้วย parser ของ format ถ้า format ว่างจะหารูปแบบจากไฟล์เอง
+func (s *ImportStationService) importPath(ctx context.Context, path, filename, contentType, format string, opts ImportOptions, progress *ImportProgress) (*dto.ImportStationResponse, error) {
+	if format == "" {
+		var err error
+		if format, err = parsers.DetectFileFormat(path, contentType, filename); err != nil {
+			return nil, err
 		}
 	}
+	parser := s.parserFor(format, opts)
+	if parser == nil {
+		return nil, errors.New("unsupported file format use file with .csv, .json, .geojson, .kml, .kmz, .xlsx, .xls, .ods, GTFS .zip or stops.txt")
+	}
+
+	res, err := s.runImport(ctx, fileRows(path, parser), opts, progress)
+	if res != nil {
+		res.Format = format
+	}
+	return res, err
+}
 
-	//ส่ง models ไปทำ buckwrite ให้ทำ update/insert ทีเดียว
-	//ทำ buckwrite เพื่อลด round-trip
-	if len(models) > 0 {
-		res, err := col.BulkWrite(ctx, models)
+// parserFor สร้าง parser ของรูปแบบไฟล์ (ค่าจาก parsers.DetectFileFormat) คืน nil ถ้าไม่รองรับ
+func (s *ImportStationService) parserFor(format string, opts ImportOptions) parsers.Parser {
+	//csv และ spreadsheet จับคู่ชื่อ column กับ field เอง column ของ mapping profile (ถ้ามี) ใช้ก่อน
+	var profileColumns map[string]string
+	if opts.Profile != nil {
+		profileColumns = opts.Profile.Columns
+	}
+	headers := utils.NewHeaderResolver(profileColumns)
+
+	switch format {
+	case parsers.FormatCSV:
+		return &parsers.CSVParser{Headers: headers, Encoding: opts.Encoding, Delimiter: opts.Delimiter}
+	case parsers.FormatJSON:
+		return &parsers.JSONParser{}
+	case parsers.FormatGeoJSON:
+		return &parsers.GeoJSONParser{}
+	case parsers.FormatKML:
+		return &parsers.KMLParser{}
+	case parsers.FormatKMZ:
+		return &parsers.KMZParser{}
+	case parsers.FormatGTFS:
+		return &parsers.GTFSParser{IDStrategy: s.gtfsIDStrategy}
+	case parsers.FormatXLSX:
+		return &parsers.XLSXParser{Headers: headers, Sheet: opts.Sheet, AllSheets: opts.AllSheets}
+	case parsers.FormatXLS:
+		return &parsers.XLSParser{Headers: headers, Sheet: opts.Sheet, AllSheets: opts.AllSheets}
+	case parsers.FormatODS:
+		return &parsers.ODSParser{Headers: headers, Sheet: opts.Sheet, AllSheets: opts.AllSheets}
+	}
+	return nil
+}
+
+// fileRows สร้าง rowOpener ที่เปิดไฟล์ใหม่ทุกครั้งแล้วอ่านด้วย parser
+// parser ที่เปิดไฟล์เองได้ (parsers.FileParser) จะอ่านจาก path ตรงๆ
+func fileRows(path string, parser parsers.Parser) rowOpener {
+	return func() (parsers.RowReader, error) {
+		if fp, ok := parser.(parsers.FileParser); ok {
+			return fp.OpenFile(path)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := parsers.OpenRows(parser, f)
 		if err != nil {
-			return 0, 0, corrupted, 0, err
+			f.Close()
+			return nil, err
 		}
-		inserted = int(res.UpsertedCount)
-		updated = len(models) - inserted
-		totalImported = len(models)
+		return &fileRowReader{RowReader: rows, file: f}, nil
 	}
+}
+
+// fileRowReader ปิดไฟล์ไปพร้อมกับ RowReader
+type fileRowReader struct {
+	parsers.RowReader
+	file *os.File
+}
+
+// HeaderInfo ส่งต่อข้อมูล header ของ RowReader ข้างใน (ถ้ามี)
+func (r *fileRowReader) HeaderInfo() (int, []dto.HeaderMapping) {
+	if hr, ok := r.RowReader.(parsers.HeaderReporter); ok {
+		return hr.HeaderInfo()
+	}
+	return 0, nil
+}
+
+// EncodingInfo ส่งต่อ encoding และตัวคั่นของ RowReader ข้างใน (ถ้ามี)
+func (r *fileRowReader) EncodingInfo() (string, string) {
+	if er, ok := r.RowReader.(parsers.EncodingReporter); ok {
+		return er.EncodingInfo()
+	}
+	return "", ""
+}
 
-	// สร้าง index location 2dsphere หลัง import
-	indexModel := mongo.IndexModel{
-		Keys: bson.M{"location": "2dsphere"},
+// Sheets ส่งต่อสรุปของแต่ละ sheet ของ RowReader ข้างใน (ถ้ามี)
+func (r *fileRowReader) Sheets() []dto.SheetSummary {
+	if sr, ok := r.RowReader.(parsers.SheetReporter); ok {
+		return sr.Sheets()
 	}
-	_, _ = col.Indexes().CreateOne(ctx, indexModel)
+	return nil
+}
+
+func (r *fileRowReader) Close() error {
+	err := r.RowReader.Close()
+	r.file.Close()
+	return err
+}
 
-	return inserted, updated, corrupted, totalImported, nil
+// SpoolToTempFile คัดลอกข้อมูลจาก r ลงไฟล์ชั่วคราวแล้วคืน path
+// คนเรียกต้องลบไฟล์เองเมื่อใช้เสร็จ
+func SpoolToTempFile(r io.Reader) (string, error) {
+	f, err := os.CreateTemp("", "station-import-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
 }
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Teneieiza/go-spinsolf-test/dto"
+	"github.com/Teneieiza/go-spinsolf-test/repositories"
+	"github.com/Teneieiza/go-spinsolf-test/utils"
+)
+
+// importFixture service import ที่ใช้ repository ใน memory ทั้งหมด
+type importFixture struct {
+	svc      *ImportStationService
+	stations *repositories.MemoryStationRepository
+	history  *StationHistoryService
+}
+
+func newImportFixture(t *testing.T, profiles map[string]*utils.MappingProfile) *importFixture {
+	t.Helper()
+	key, err := utils.ParseStationKey("station_code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stations := repositories.NewMemoryStationRepository()
+	history := NewStationHistoryService(repositories.NewMemoryStationHistoryRepository(), stations)
+	svc := NewImportStationService(stations, repositories.NewMemoryImportChangesetRepository(), history, key, 100, "", profiles, nil, 10)
+	return &importFixture{svc: svc, stations: stations, history: history}
+}
+
+// importCSV เขียน content เป็นไฟล์ csv ชั่วคราวแล้ว import
+func (f *importFixture) importCSV(t *testing.T, content string, opts ImportOptions) (*dto.ImportStationResponse, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stations.csv")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return f.svc.ImportFileStations(context.Background(), "stations.csv", "text/csv", path, opts, nil)
+}
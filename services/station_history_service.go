@@ -0,0 +1,311 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Teneieiza/go-spinsolf-test/dto"
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"github.com/Teneieiza/go-spinsolf-test/repositories"
+	"github.com/Teneieiza/go-spinsolf-test/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StationHistoryService บันทึกและอ่านประวัติการเปลี่ยนแปลงของสถานี
+// ทุกทางที่เขียนข้อมูลสถานี (import, rollback, แก้ไขผ่าน API) ต้องบันทึกผ่าน service นี้หลังเขียนสำเร็จ
+type StationHistoryService struct {
+	history  repositories.StationHistoryRepository
+	stations repositories.StationRepository
+}
+
+func NewStationHistoryService(history repositories.StationHistoryRepository, stations repositories.StationRepository) *StationHistoryService {
+	return &StationHistoryService{history: history, stations: stations}
+}
+
+// History ดึงประวัติของสถานีเรียงจากใหม่ไปเก่าแบบแบ่งหน้า (page เริ่มที่ 1)
+// สถานีที่ถูกลบไปแล้วยังดูประวัติได้ ถ้าไม่มีทั้งประวัติและสถานีคืน ErrStationNotFound
+func (s *StationHistoryService) History(code, page, limit int) (*dto.PaginatedResponse[models.StationVersion], error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	total, err := s.history.CountByStation(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if total == 0 {
+		if _, err := s.stations.FindByCode(ctx, code); err != nil {
+			return nil, err
+		}
+	}
+
+	start := (page - 1) * limit
+	versions, err := s.history.ListByStation(ctx, code, start, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.PaginatedResponse[models.StationVersion]{
+		Page:     page,
+		PageSize: limit,
+		Total:    int(total),
+		Start:    start + 1,
+		End:      start + len(versions),
+		Data:     versions,
+	}, nil
+}
+
+// record บันทึกประวัติหลายรายการ รายการที่ไม่ได้ใส่เวลาจะใช้เวลาปัจจุบัน
+func (s *StationHistoryService) record(ctx context.Context, versions []models.StationVersion) error {
+	if len(versions) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for i := range versions {
+		if versions[i].ChangedAt.IsZero() {
+			versions[i].ChangedAt = now
+		}
+	}
+	if err := s.history.Append(ctx, versions); err != nil {
+		return fmt.Errorf("record station history: %w", err)
+	}
+	return nil
+}
+
+// recordStation บันทึกการแก้ไขสถานี 1 แห่งผ่าน API
+// before เป็น nil คือเพิ่มใหม่ after เป็น nil คือลบจริง ถ้าไม่มี field ไหนเปลี่ยนจะไม่บันทึก
+func (s *StationHistoryService) recordStation(ctx context.Context, before, after *models.Station, actor string) error {
+	v, ok := stationVersion(before, after)
+	if !ok {
+		return nil
+	}
+	v.Source, v.Actor = models.HistorySourceAPI, actor
+	return s.record(ctx, []models.StationVersion{v})
+}
+
+// stationVersion สร้างประวัติจากค่าก่อน/หลังของสถานี (ยังไม่มี Source และ Actor) คืน false ถ้าไม่มี field ไหนเปลี่ยน
+func stationVersion(before, after *models.Station) (models.StationVersion, bool) {
+	var v models.StationVersion
+	switch {
+	case before == nil:
+		v.StationCode, v.Action = after.StationCode, models.ChangeInsert
+		v.After = utils.StationToBsonMap(*after, nil)
+	case after == nil:
+		v.StationCode, v.Action = before.StationCode, models.ChangeDelete
+		v.Before = utils.StationToBsonMap(*before, nil)
+		v.Before["_id"] = before.ID
+		if before.DeletedAt != nil {
+			v.Before["deleted_at"], v.Before["deleted_by"] = *before.DeletedAt, before.DeletedBy
+		}
+	default:
+		changes := utils.StationChanges(*after, utils.StationToBsonMap(*before, nil))
+		v.StationCode, v.Action = after.StationCode, models.ChangeUpdate
+		v.Before = make(map[string]interface{}, len(changes))
+		v.After = make(map[string]interface{}, len(changes))
+		for k, c := range changes {
+			v.Before[k], v.After[k] = c.Old, c.New
+		}
+
+		//สถานะการลบไม่อยู่ใน StationToBsonMap (import ต้องไม่เปลี่ยน) เทียบแยก
+		switch {
+		case before.DeletedAt == nil && after.DeletedAt != nil:
+			v.Action = models.ChangeSoftDelete
+			v.Before["deleted_at"], v.Before["deleted_by"] = nil, nil
+			v.After["deleted_at"], v.After["deleted_by"] = *after.DeletedAt, after.DeletedBy
+		case before.DeletedAt != nil && after.DeletedAt == nil:
+			v.Action = models.ChangeRestore
+			v.Before["deleted_at"], v.Before["deleted_by"] = *before.DeletedAt, before.DeletedBy
+			v.After["deleted_at"], v.After["deleted_by"] = nil, nil
+		}
+		if len(v.Before) == 0 {
+			return v, false
+		}
+	}
+	return v, true
+}
+
+// versionsFromChanges แปลงรายการใน changeset เป็นประวัติของสถานี
+func versionsFromChanges(changes []models.StationChange, source, actor string, cs *models.ImportChangeset) []models.StationVersion {
+	versions := make([]models.StationVersion, 0, len(changes))
+	for _, c := range changes {
+		v := models.StationVersion{
+			StationCode: c.StationCode,
+			Action:      c.Action,
+			Before:      c.Before,
+			After:       c.After,
+			Source:      source,
+			Actor:       actor,
+		}
+		if cs != nil {
+			v.JobID, v.ChangesetID = cs.JobID, &cs.ID
+		}
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+// maxAsOfVersions จำนวนประวัติหลังเวลา as_of สูงสุดที่ยอมย้อนในการค้นหาครั้งเดียว
+// as_of ที่ย้อนไปไกลจนมีการเปลี่ยนแปลงหลังจากนั้นมากกว่านี้จะได้ ErrAsOfTooOld
+const maxAsOfVersions = 50000
+
+// ErrAsOfTooOld ใช้เมื่อมีประวัติหลังเวลา as_of มากเกิน maxAsOfVersions
+var ErrAsOfTooOld = fmt.Errorf("as_of is too far back: more than %d changes since then", maxAsOfVersions)
+
+// changedAsOf ข้อมูล ณ เวลา asOf ของสถานีที่มีการเปลี่ยนแปลงหลัง asOf
+// สถานีที่ไม่อยู่ใน codes มีค่า ณ เวลานั้นเท่ากับค่าปัจจุบัน
+type changedAsOf struct {
+	codes   map[int]bool            // station_code ปัจจุบันของสถานีที่เปลี่ยนหลัง asOf
+	states  map[int]*models.Station // ค่า ณ เวลา asOf ไม่มี key คือยังไม่มีสถานีนั้น
+	current int                     // จำนวนสถานีใน codes ที่ active และไม่ถูกลบในตอนนี้
+}
+
+// stationsChangedSince สร้างข้อมูล ณ เวลา asOf ของเฉพาะสถานีที่มีประวัติหลัง asOf
+// อ่านประวัติหลัง asOf (ไม่เกิน maxAsOfVersions) ดึงค่าปัจจุบันของสถานีเหล่านั้น แล้วย้อนทีละรายการจากใหม่ไปเก่า
+func (s *StationHistoryService) stationsChangedSince(ctx context.Context, asOf time.Time) (*changedAsOf, error) {
+	var versions []models.StationVersion
+	changed := &changedAsOf{codes: make(map[int]bool), states: make(map[int]*models.Station)}
+	err := s.history.ForEachSince(ctx, asOf, func(v models.StationVersion) error {
+		if len(versions) >= maxAsOfVersions {
+			return ErrAsOfTooOld
+		}
+		versions = append(versions, v)
+		changed.codes[v.StationCode] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return changed, nil
+	}
+
+	codes := make([]int, 0, len(changed.codes))
+	for code := range changed.codes {
+		codes = append(codes, code)
+	}
+	current, err := s.stations.FindByCodes(ctx, codes)
+	if err != nil {
+		return nil, err
+	}
+	for i := range current {
+		st := &current[i]
+		if st.Active == 1 && st.DeletedAt == nil {
+			changed.current++
+		}
+		changed.states[st.StationCode] = st
+	}
+
+	states := changed.states
+	for _, v := range versions {
+		switch v.Action {
+		case models.ChangeInsert:
+			delete(states, v.StationCode)
+		case models.ChangeDelete:
+			st := &models.Station{}
+			applyVersionFields(st, v.Before)
+			states[v.StationCode] = st
+		default:
+			st, ok := states[v.StationCode]
+			if !ok {
+				//ไม่มีสถานะที่จะย้อน (ข้อมูลถูกลบโดยไม่ผ่าน service) ข้ามไป
+				continue
+			}
+			applyVersionFields(st, v.Before)
+			//import ที่ใช้ key อื่นเปลี่ยน station_code ได้ ต้องย้ายไปอยู่ใต้ code เดิม
+			if st.StationCode != v.StationCode {
+				delete(states, v.StationCode)
+				states[st.StationCode] = st
+			}
+		}
+	}
+	return changed, nil
+}
+
+// nearbyAsOf สถานีที่ active และไม่ถูกลบ ณ เวลา asOf เรียงจากใกล้ไปไกล คืนรายการช่วง skip, limit และจำนวนที่ active ทั้งหมด
+// สถานีที่ไม่เปลี่ยนหลัง asOf มาจากการค้นหาตามระยะทางปกติ (ดึงเผื่อจำนวนสถานีที่เปลี่ยนซึ่งต้องตัดออก)
+// ส่วนสถานีที่เปลี่ยนใช้ค่าที่ย้อนแล้วจาก stationsChangedSince ไม่ต้องอ่านสถานีทั้งหมด
+func (s *StationHistoryService) nearbyAsOf(ctx context.Context, lat, long float64, asOf time.Time, skip, limit int) ([]dto.StationWithDistance, int, error) {
+	changed, err := s.stationsChangedSince(ctx, asOf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	nearest, err := s.stations.FindNearby(ctx, lat, long, 0, skip+limit+changed.current)
+	if err != nil {
+		return nil, 0, err
+	}
+	active := make([]models.Station, 0, len(nearest)+len(changed.states))
+	for _, st := range nearest {
+		if !changed.codes[st.StationCode] {
+			active = append(active, st)
+		}
+	}
+	activeChanged := 0
+	for _, st := range changed.states {
+		if st.Active == 1 && st.DeletedAt == nil {
+			active = append(active, *st)
+			activeChanged++
+		}
+	}
+
+	results := toStationsWithDistance(lat, long, active)
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].DistanceKM != results[j].DistanceKM {
+			return results[i].DistanceKM < results[j].DistanceKM
+		}
+		return results[i].StationCode < results[j].StationCode
+	})
+
+	count, err := s.stations.CountActive(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	total := int(count) - changed.current + activeChanged
+
+	if skip > len(results) {
+		skip = len(results)
+	}
+	end := skip + limit
+	if end > len(results) {
+		end = len(results)
+	}
+	return results[skip:end], total, nil
+}
+
+// applyVersionFields ใส่ค่าของ field ในประวัติลงใน Station แบบเดียวกับการแก้ไขผ่าน API
+func applyVersionFields(st *models.Station, fields map[string]interface{}) {
+	//ค่า nil คือ field ที่ยังไม่มีใน document ตอนนั้น แปลงเป็นค่าว่างจะได้เป็น zero value ของแต่ละชนิด
+	item := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		if v == nil {
+			v = ""
+		}
+		item[k] = v
+	}
+	normalized, _ := utils.NormalizeStationFields(item)
+	utils.ApplyStationFields(st, normalized)
+
+	if id, ok := fields["_id"].(primitive.ObjectID); ok {
+		st.ID = id
+	}
+	if v, ok := fields["deleted_at"]; ok {
+		st.DeletedAt = historyTime(v)
+	}
+	if v, ok := fields["deleted_by"]; ok {
+		st.DeletedBy, _ = v.(string)
+	}
+}
+
+// historyTime แปลงเวลาในประวัติ (time.Time หรือ DateTime ที่อ่านจาก mongo) nil คือไม่มีค่า
+func historyTime(v interface{}) *time.Time {
+	switch t := v.(type) {
+	case time.Time:
+		return &t
+	case primitive.DateTime:
+		tt := t.Time()
+		return &tt
+	default:
+		return nil
+	}
+}
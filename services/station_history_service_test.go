@@ -0,0 +1,85 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nearbyCodes station_code ของทุกหน้าจาก GetNearbyStationsPage และจำนวนทั้งหมด
+func nearbyCodes(t *testing.T, svc *StationService, asOf time.Time) ([]int, int) {
+	t.Helper()
+	var codes []int
+	total := 0
+	for page := 1; page <= 6; page++ {
+		res, err := svc.GetNearbyStationsPage(13.75, 100.50, page, 3, asOf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total = res.Total
+		for _, st := range res.Data {
+			codes = append(codes, st.StationCode)
+		}
+	}
+	return codes, total
+}
+
+func TestNearbyAsOfMatchesPastState(t *testing.T) {
+	f := newImportFixture(t, nil)
+	svc := NewStationService(f.stations, f.history)
+
+	var b strings.Builder
+	b.WriteString("station_code,name,lat,long,active\n")
+	for i := 0; i < 15; i++ {
+		fmt.Fprintf(&b, "%d,สถานี%d,%.2f,100.50,1\n", 1001+i, i, 13.75+float64(i)*0.01)
+	}
+	if _, err := f.importCSV(t, b.String(), ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	wantCodes, wantTotal := nearbyCodes(t, svc, time.Time{})
+	time.Sleep(2 * time.Millisecond)
+	asOf := time.Now()
+	time.Sleep(2 * time.Millisecond)
+
+	//หลัง asOf: 1013 ย้ายไปไกล 1014 ถูกปิด 1015 ถูกลบ 1012 ย้ายมาใกล้ และมีสถานีใหม่ที่ใกล้ที่สุด 2 แห่ง
+	//สถานีที่ใกล้ที่สุดตอนนี้จึงเปลี่ยนหลัง asOf ทั้งหมด ต้องดึงสถานีที่ไม่เปลี่ยนเผื่อไว้
+	file := "station_code,name,lat,long,active\n1012,สถานี11,13.75,100.51,1\n1013,สถานี12,18.00,99.00,1\n1014,สถานี13,13.88,100.50,0\n" +
+		"2001,ใหม่,13.75,100.50,1\n2002,ใหม่2,13.75,100.49,1\n"
+	if _, err := f.importCSV(t, file, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteStation(1015, "tester"); err != nil {
+		t.Fatal(err)
+	}
+
+	nowCodes, _ := nearbyCodes(t, svc, time.Time{})
+	if reflect.DeepEqual(nowCodes, wantCodes) {
+		t.Fatalf("current result %v did not change, test does not cover anything", nowCodes)
+	}
+
+	gotCodes, gotTotal := nearbyCodes(t, svc, asOf)
+	if !reflect.DeepEqual(gotCodes, wantCodes) || gotTotal != wantTotal {
+		t.Errorf("as_of result = %v total %d, want %v total %d", gotCodes, gotTotal, wantCodes, wantTotal)
+	}
+}
+
+func TestStationsChangedSinceOnlyChangedStations(t *testing.T) {
+	f := newImportFixture(t, nil)
+	asOf := time.Now()
+	time.Sleep(2 * time.Millisecond)
+	if _, err := f.importCSV(t, threeStations, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := f.history.stationsChangedSince(t.Context(), asOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//ก่อน import ยังไม่มีสถานีไหนเลย แต่ทั้ง 3 สถานี active อยู่ตอนนี้
+	if len(changed.states) != 0 || len(changed.codes) != 3 || changed.current != 3 {
+		t.Errorf("states %d codes %d current %d, want 0, 3, 3", len(changed.states), len(changed.codes), changed.current)
+	}
+}
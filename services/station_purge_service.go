@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"github.com/Teneieiza/go-spinsolf-test/repositories"
+)
+
+// StationPurgeService ลบจริงสถานีที่ถูก soft delete นานเกิน retention ทำงานเป็น background job ทุก interval
+// ข้อมูลก่อนลบเก็บไว้ในประวัติของสถานี (source purge)
+type StationPurgeService struct {
+	repo      repositories.StationRepository
+	history   *StationHistoryService
+	retention time.Duration
+	interval  time.Duration
+	batchSize int
+
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// NewStationPurgeService สร้าง service retention คือระยะเวลาที่เก็บสถานีที่ถูกลบไว้ก่อนลบจริง
+func NewStationPurgeService(repo repositories.StationRepository, history *StationHistoryService, retention, interval time.Duration, batchSize int) *StationPurgeService {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	return &StationPurgeService{
+		repo:      repo,
+		history:   history,
+		retention: retention,
+		interval:  interval,
+		batchSize: batchSize,
+		ctx:       ctx,
+		stop:      stop,
+	}
+}
+
+// Start เริ่ม purge ทันที 1 ครั้งแล้วทำซ้ำทุก interval จนกว่าจะ Shutdown
+func (s *StationPurgeService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		s.runOnce()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				s.runOnce()
+			}
+		}
+	}()
+}
+
+// Shutdown หยุด purge (รอรอบที่กำลังทำอยู่ให้จบ batch ก่อน)
+func (s *StationPurgeService) Shutdown() {
+	s.stop()
+	s.wg.Wait()
+}
+
+func (s *StationPurgeService) runOnce() {
+	n, err := s.Purge(time.Now().Add(-s.retention))
+	if err != nil {
+		log.Printf("station purge: %v", err)
+	}
+	if n > 0 {
+		log.Printf("station purge: purged %d stations deleted more than %s ago", n, s.retention)
+	}
+}
+
+// Purge ลบจริงสถานีที่ถูกลบก่อนเวลา before ทีละ batch คืนจำนวนที่ลบ
+func (s *StationPurgeService) Purge(before time.Time) (int, error) {
+	total := 0
+	for {
+		ctx, cancel := context.WithTimeout(s.ctx, 60*time.Second)
+		purged, err := s.repo.PurgeDeleted(ctx, before, s.batchSize)
+		//บันทึกประวัติของที่ลบไปแล้วเสมอ แม้ batch จะล้มเหลวกลางทาง
+		versions := make([]models.StationVersion, 0, len(purged))
+		for i := range purged {
+			v, _ := stationVersion(&purged[i], nil)
+			v.Source, v.Actor = models.HistorySourcePurge, "retention"
+			versions = append(versions, v)
+		}
+		if recErr := s.history.record(ctx, versions); recErr != nil && err == nil {
+			err = recErr
+		}
+		cancel()
+
+		total += len(purged)
+		if err != nil || len(purged) < s.batchSize {
+			return total, err
+		}
+	}
+}
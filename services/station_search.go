@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Teneieiza/go-spinsolf-test/dto"
+	"github.com/Teneieiza/go-spinsolf-test/models"
+	"github.com/Teneieiza/go-spinsolf-test/utils"
+)
+
+// คะแนนของการค้นหาด้วยข้อความ เรียงจากตรงที่สุด
+const (
+	scoreShortExact  = 1000 // คำค้นตรงกับชื่อย่อทั้งหมด
+	scoreNameExact   = 900  // คำค้นตรงกับชื่อทั้งหมด
+	scoreShortPrefix = 800  // ชื่อย่อขึ้นต้นด้วยคำค้น
+	scoreNamePrefix  = 700  // ชื่อขึ้นต้นด้วยคำค้น
+	scoreWordPrefix  = 500  // ทุก term เป็น prefix ของคำใน field เดียวกัน
+	scoreInnerPrefix = 300  // ทุก term เป็น prefix ของคำหรือพยางค์กลางคำ (ไทย จีน) ใน field เดียวกัน
+	scoreCrossField  = 100  // ทุก term เจอแต่อยู่คนละ field
+
+	scoreFuzzy        = 200 // ตรงแบบไม่เข้มงวด (ไม่สนช่องว่าง ตัวสะกดอักษรโรมันที่ต่างกัน หรือชื่อไทยที่ถอดเป็นอักษรโรมัน)
+	scoreFuzzyPerEdit = 40  // หักคะแนนต่อตัวอักษรที่ต่างจากชื่อ
+)
+
+// SearchStations ค้นหาสถานีด้วยชื่อไทย อังกฤษ จีน และชื่อย่อ เรียงจากตรงที่สุด ไม่รวมสถานีที่ถูกลบ
+// repository หาตัวเลือกที่มีทุก term อยู่ในข้อความ แล้วจัดอันดับด้วยการจับคู่แบบ prefix ที่นี่
+// จึงให้ผลเหมือนกันทั้ง MongoDB และ memory
+// ถ้าผลยังไม่ครบ limit จะค้นต่อแบบไม่เข้มงวด (ดู fuzzyScore) ต่อท้ายด้วยคะแนนที่ต่ำกว่า
+func (s *StationService) SearchStations(q string, limit int) ([]dto.StationSearchResult, error) {
+	terms := utils.SearchTerms(q)
+	results := []dto.StationSearchResult{}
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	candidates, err := s.repo.FindByText(ctx, terms)
+	if err != nil {
+		return nil, err
+	}
+
+	query := strings.Join(terms, " ")
+	matchedLen := make(map[int]int, len(candidates))
+	for _, st := range candidates {
+		score, field, length := searchScore(st, query, terms)
+		if score == 0 {
+			//มีทุก term อยู่ในข้อความแต่ไม่ได้อยู่ต้นคำ เช่น "ang" ใน "bangkok"
+			continue
+		}
+		matchedLen[st.StationCode] = length
+		results = append(results, searchResult(st, score, field))
+	}
+
+	if len(results) < limit {
+		fq := newFuzzyQuery(query)
+		//ตัวเลือกคือสถานีที่ค่าสำหรับค้นหาขึ้นต้นด้วยตัวอักษรเดียวกับคำค้น จึงไม่ต้องอ่านทุกสถานี
+		//แลกกับการหาไม่เจอเมื่อพิมพ์ตัวแรกผิด
+		fuzzy, err := s.repo.FindByFuzzyPrefix(ctx, firstRune(fq.name), firstRune(fq.roman))
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range fuzzy {
+			if _, found := matchedLen[st.StationCode]; found {
+				continue
+			}
+			score, field, length := fuzzyScore(st, fq)
+			if score > 0 {
+				matchedLen[st.StationCode] = length
+				results = append(results, searchResult(st, score, field))
+			}
+		}
+	}
+
+	//คะแนนเท่ากันให้สถานีที่ active ก่อน แล้วชื่อที่สั้นกว่า (ตรงกับคำค้นมากกว่า)
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		switch {
+		case a.Score != b.Score:
+			return a.Score > b.Score
+		case (a.Active == 1) != (b.Active == 1):
+			return a.Active == 1
+		case matchedLen[a.StationCode] != matchedLen[b.StationCode]:
+			return matchedLen[a.StationCode] < matchedLen[b.StationCode]
+		default:
+			return a.StationCode < b.StationCode
+		}
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func searchResult(st models.Station, score int, field string) dto.StationSearchResult {
+	return dto.StationSearchResult{
+		ID:              st.ID,
+		StationCode:     st.StationCode,
+		Name:            st.Name,
+		EnName:          st.EnName,
+		EnNameGenerated: st.EnNameGenerated,
+		ChName:          st.ChName,
+		ThShort:         st.ThShort,
+		EnShort:         st.EnShort,
+		Active:          st.Active,
+		Lat:             st.Lat,
+		Long:            st.Long,
+		Score:           score,
+		MatchedField:    field,
+	}
+}
+
+// searchScore คะแนนที่ดีที่สุดของสถานีกับคำค้น พร้อม field ที่ได้คะแนนและความยาวของข้อความใน field นั้น
+// query คือ terms ที่ต่อกันด้วยช่องว่าง
+func searchScore(st models.Station, query string, terms []string) (score int, field string, length int) {
+	values := map[string]string{
+		"name":     st.Name,
+		"en_name":  st.EnName,
+		"chname":   st.ChName,
+		"th_short": st.ThShort,
+		"en_short": st.EnShort,
+	}
+
+	var allTokens []string
+	for _, f := range models.SearchFields {
+		value := utils.NormalizeSearchText(values[f])
+		if value == "" {
+			continue
+		}
+		short := f == "th_short" || f == "en_short"
+		words, inner := utils.SearchTokens(value)
+		allTokens = append(allTokens, words...)
+		allTokens = append(allTokens, inner...)
+
+		s := 0
+		switch {
+		case value == query && short:
+			s = scoreShortExact
+		case value == query:
+			s = scoreNameExact
+		case strings.HasPrefix(value, query) && short:
+			s = scoreShortPrefix
+		case strings.HasPrefix(value, query):
+			s = scoreNamePrefix
+		case termsArePrefixes(terms, words):
+			s = scoreWordPrefix
+		case termsArePrefixes(terms, append(words, inner...)):
+			s = scoreInnerPrefix
+		}
+		if s > score {
+			score, field, length = s, f, len([]rune(value))
+		}
+	}
+
+	if score == 0 && termsArePrefixes(terms, allTokens) {
+		score, field = scoreCrossField, ""
+	}
+	return score, field, length
+}
+
+// termsArePrefixes เช็คว่าทุก term เป็น prefix ของ token อย่างน้อย 1 ตัว
+func termsArePrefixes(terms, tokens []string) bool {
+	for _, term := range terms {
+		found := false
+		for _, tok := range tokens {
+			if strings.HasPrefix(tok, term) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// fuzzyQuery คำค้นที่แปลงแล้วสำหรับเทียบกับค่าที่ใช้ค้นหาของสถานี (ดู utils.ApplySearchKeys) ค่าว่างคือไม่เทียบ
+type fuzzyQuery struct {
+	name  string // คำค้นภาษาไทยแบบ utils.CompactSearchText เทียบกับ FuzzyName
+	roman string // คำค้นอักษรโรมัน (หรือคำค้นภาษาไทยที่ถอดแล้ว) แบบ utils.FuzzyKey เทียบกับ FuzzyEnName และ FuzzyRoman
+}
+
+func newFuzzyQuery(query string) fuzzyQuery {
+	if utils.HasThai(query) {
+		return fuzzyQuery{
+			name:  utils.CompactSearchText(query),
+			roman: utils.FuzzyKey(utils.RomanizeThai(query)),
+		}
+	}
+	return fuzzyQuery{roman: utils.FuzzyKey(query)}
+}
+
+// firstRune ตัวอักษรแรกของ s ค่าว่างถ้า s ว่าง
+func firstRune(s string) string {
+	for _, r := range s {
+		return string(r)
+	}
+	return ""
+}
+
+// fuzzyScore คะแนนของการจับคู่แบบไม่เข้มงวด 0 คือไม่ตรง
+// เทียบคำค้นกับส่วนต้นของชื่อโดยไม่สนช่องว่าง ยอมให้ต่างได้ตาม utils.FuzzyTolerance
+//   - คำค้นภาษาไทย เทียบกับชื่อไทย และถอดเป็นอักษรโรมัน (RTGS) เทียบกับ en_name เช่น "หัวลำโพง" กับ "Hua Lamphong"
+//   - คำค้นอักษรโรมัน เทียบกับ en_name และชื่อไทยที่ถอดเป็นอักษรโรมัน ด้วย utils.FuzzyKey เช่น "Hua Lampong" กับ "Hualamphong"
+func fuzzyScore(st models.Station, q fuzzyQuery) (score int, field string, length int) {
+	pairs := []struct{ field, q, value string }{
+		{"name", q.name, st.FuzzyName},
+		{"en_name", q.roman, st.FuzzyEnName},
+		{"name", q.roman, st.FuzzyRoman},
+	}
+
+	best := -1
+	for _, p := range pairs {
+		if p.q == "" || p.value == "" {
+			continue
+		}
+		d := utils.PrefixEditDistance(p.q, p.value)
+		if d > utils.FuzzyTolerance(len([]rune(p.q))) {
+			continue
+		}
+		if best == -1 || d < best {
+			best, field, length = d, p.field, len([]rune(p.value))
+		}
+	}
+	if best == -1 {
+		return 0, "", 0
+	}
+	return scoreFuzzy - best*scoreFuzzyPerEdit, field, length
+}
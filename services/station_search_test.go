@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Teneieiza/go-spinsolf-test/repositories"
+)
+
+func TestSearchStationsFuzzy(t *testing.T) {
+	f := newImportFixture(t, nil)
+	file := "station_code,name,en_name,lat,long,active\n" +
+		"1001,หัวลำโพง,,13.74,100.51,1\n" +
+		"1002,ดอนเมือง,Don Muang,13.91,100.60,1\n" +
+		"1003,บางซื่อ,Bang Sue,13.80,100.54,1\n"
+	if _, err := f.importCSV(t, file, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	//ชื่อที่ถอดจากชื่อไทยเก็บแยก en_name ยังว่างอยู่
+	st, err := f.stations.FindByCode(context.Background(), 1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.EnName != "" || st.EnNameGenerated != "Hualamphong" {
+		t.Errorf("station 1001 en_name %q en_name_generated %q, want empty and Hualamphong", st.EnName, st.EnNameGenerated)
+	}
+
+	svc := NewStationService(f.stations, f.history)
+	tests := []struct {
+		q    string
+		want int
+	}{
+		{"Hua Lampong", 1001},
+		{"Hualamphong", 1001},
+		{"หัวลำโพง", 1001},
+		{"hualampon", 1001},
+		{"Donmueang", 1002},
+		{"ดอนเมือง", 1002},
+		{"Bangsue", 1003},
+		{"บางซือ", 1003},
+	}
+	for _, tt := range tests {
+		results, err := svc.SearchStations(tt.q, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) == 0 || results[0].StationCode != tt.want {
+			t.Errorf("SearchStations(%q) = %+v, want station %d first", tt.q, results, tt.want)
+		}
+	}
+
+	//ตัวแรกต่างกันไม่อยู่ในตัวเลือกของการค้นหาแบบไม่เข้มงวด
+	results, err := svc.SearchStations("Xualamphong", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("SearchStations(Xualamphong) = %+v, want no results", results)
+	}
+
+	//มี en_name จริงแล้ว ชื่อที่ถอดไว้ถูกล้าง
+	if _, err := f.importCSV(t, "station_code,name,en_name,lat,long,active\n1001,หัวลำโพง,Krung Thep,13.74,100.51,1\n", ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	st, err = f.stations.FindByCode(context.Background(), 1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.EnNameGenerated != "" {
+		t.Errorf("station 1001 en_name_generated %q after en_name was set, want empty", st.EnNameGenerated)
+	}
+}
+
+func TestRefreshSearchKeys(t *testing.T) {
+	f := newImportFixture(t, nil)
+	if _, err := f.importCSV(t, threeStations, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewStationService(f.stations, f.history)
+
+	//ทุกสถานีมีค่าแล้วตั้งแต่ตอน import
+	if n, err := svc.RefreshSearchKeys(); err != nil || n != 0 {
+		t.Fatalf("RefreshSearchKeys = %d, %v, want 0", n, err)
+	}
+
+	//document ที่เขียนก่อนมี field เหล่านี้
+	stale := map[string]interface{}{"fuzzy_name": "", "fuzzy_en_name": "", "fuzzy_roman": "", "en_name_generated": ""}
+	if _, err := f.stations.BulkUpdate(context.Background(), []repositories.StationUpsert{{Filter: map[string]interface{}{"station_code": 1003}, Fields: stale}}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := svc.RefreshSearchKeys(); err != nil || n != 1 {
+		t.Fatalf("RefreshSearchKeys = %d, %v, want 1", n, err)
+	}
+	results, err := svc.SearchStations("Bangsue", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 || results[0].StationCode != 1003 {
+		t.Errorf("SearchStations(Bangsue) after refresh = %+v, want station 1003", results)
+	}
+}
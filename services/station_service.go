@@ -2,147 +2,357 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"sort"
 	"time"
 
-	"github.com/Teneieiza/go-spinsolf-test/config"
 	"github.com/Teneieiza/go-spinsolf-test/dto"
 	"github.com/Teneieiza/go-spinsolf-test/models"
+	"github.com/Teneieiza/go-spinsolf-test/parsers"
+	"github.com/Teneieiza/go-spinsolf-test/repositories"
 	"github.com/Teneieiza/go-spinsolf-test/utils"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// StationService รวม business logic ของการค้นหาสถานี
+// ใช้ StationRepository แทนการเรียก collection ของ mongo ตรงๆ
+// history บันทึกทุกการแก้ไขผ่าน API และใช้ตอบค้นหาแบบย้อนเวลา (as_of)
+type StationService struct {
+	repo    repositories.StationRepository
+	history *StationHistoryService
+}
+
+func NewStationService(repo repositories.StationRepository, history *StationHistoryService) *StationService {
+	return &StationService{repo: repo, history: history}
+}
+
+// ErrStationDeleted ใช้เมื่อจะเพิ่มสถานีที่ station_code ซ้ำกับสถานีที่ถูกลบ (ยังไม่ถูก purge)
+var ErrStationDeleted = errors.New("station with this station_code is deleted, restore it instead")
+
+// ValidationError คือ error ที่เกิดจากข้อมูลที่ส่งเข้ามาไม่ถูกต้อง
+// controller ใช้แยกว่าควรตอบ 400 แทน 500
+type ValidationError struct {
+	Errors []utils.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	return utils.JoinFieldErrors(e.Errors)
+}
+
 // GetNearbyStations ดึงสถานีใกล้ที่สุด
 // รับ lat long และ limit คืนค่าเป็น slice ของ StationWithDistance(มาจากไฟล์ dto/station_response.go นะจ้ะ)
-func GetNearbyStations(lat, long float64, limit int) ([]dto.StationWithDistance, error) {
+// ถ้า asOf ไม่ใช่ค่าว่างจะตอบจากข้อมูล ณ เวลานั้นแทนข้อมูลปัจจุบัน
+func (s *StationService) GetNearbyStations(lat, long float64, limit int, asOf time.Time) ([]dto.StationWithDistance, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	col := config.DB.Collection
-
-  //filter เฉพาะสถานีที่ active และ ใช้ &near เพื่อดูว่าตัวไหนใกล้เคียงกับ lat long ที่ใส่มา
-	filter := bson.M{
-		"active": 1,
-		"location": bson.M{
-			"$near": bson.M{
-				"$geometry": bson.M{
-					"type": "Point",
-					"coordinates": []float64{long, lat},
-				},
-			},
-		},
+	if !asOf.IsZero() {
+		results, _, err := s.history.nearbyAsOf(ctx, lat, long, asOf, 0, limit)
+		return results, err
+	}
+
+	//ดึงสถานีที่ active เรียงจากใกล้ไปไกล ตามจำนวณของ limit
+	stations, err := s.repo.FindNearby(ctx, lat, long, 0, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return toStationsWithDistance(lat, long, stations), nil
+}
+
+// GetNearbyStations ดึงสถานีที่ใกล้ที่สุดที่มี pagination
+// รับ lat long page และ limit คืนค่าเป็น slice ของ StationWithDistance ในรูปแบบของ PaginatedResponse(มาจากไฟล์ dto/station_response.go นะจ้ะ)
+// ถ้า asOf ไม่ใช่ค่าว่างจะตอบจากข้อมูล ณ เวลานั้นแทนข้อมูลปัจจุบัน
+func (s *StationService) GetNearbyStationsPage(lat, long float64, page, limit int, asOf time.Time) (*dto.PaginatedResponse[dto.StationWithDistance], error) {
+	// ตั้ง context set timeout กัน query ค้าง
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	//set start ไว้
+	start := (page - 1) * limit
+
+	if !asOf.IsZero() {
+		results, total, err := s.history.nearbyAsOf(ctx, lat, long, asOf, start, limit)
+		if err != nil {
+			return nil, err
+		}
+		return &dto.PaginatedResponse[dto.StationWithDistance]{
+			Page:     page,
+			PageSize: limit,
+			Total:    total,
+			Start:    start + 1,
+			End:      start + len(results),
+			Data:     results,
+		}, nil
 	}
 
-	//setlimit ไว้เป็นจำนวณของ limit
-	cur, err := col.Find(ctx, filter,
-		options.Find().SetLimit(int64(limit)),
-	)
+	//ให้ไปค้าหาข้อมูลที่เริ่มต้นด้วย start และสิ้นสุดที่จำนวณ limit
+	stations, err := s.repo.FindNearby(ctx, lat, long, start, limit)
 	if err != nil {
 		return nil, err
 	}
-	defer cur.Close(ctx)
 
-	var stations []models.Station
-	if err := cur.All(ctx, &stations); err != nil {
+	results := toStationsWithDistance(lat, long, stations)
+
+	//เรียงลำดับข้อมูล results จากน้อยไปมาก
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].DistanceKM < results[j].DistanceKM
+	})
+
+	total, err := s.repo.CountActive(ctx)
+	if err != nil {
 		return nil, err
 	}
 
+	return &dto.PaginatedResponse[dto.StationWithDistance]{
+		Page:     page,
+		PageSize: limit,
+		Total:    int(total),
+		Start:    start + 1,
+		End:      start + len(results),
+		Data:     results,
+	}, nil
+}
+
+// toStationsWithDistance แปลง station เป็น StationWithDistance พร้อมคำนวณระยะทาง
+// loop stations ทั้งหมดโดยแทน st คือ station แต่ละตัว
+// โดยเข้า func คำนวณระยะทาง lat long คือค่าที่รับมา st.lat st.long คือค่าใน station
+func toStationsWithDistance(lat, long float64, stations []models.Station) []dto.StationWithDistance {
 	results := make([]dto.StationWithDistance, 0, len(stations))
-	for _, s := range stations {
-		dist := utils.Haversine(lat, long, s.Lat, s.Long)
+	for _, st := range stations {
+		dist := utils.Haversine(lat, long, st.Lat, st.Long)
 		results = append(results, dto.StationWithDistance{
-			ID:          s.ID,
-			StationCode: s.StationCode,
-			Name:        s.Name,
-			EnName:      s.EnName,
-			Lat:         s.Lat,
-			Long:        s.Long,
+			ID:          st.ID,
+			StationCode: st.StationCode,
+			Name:        st.Name,
+			EnName:      st.EnName,
+			Lat:         st.Lat,
+			Long:        st.Long,
 			DistanceKM:  dist,
 		})
 	}
+	return results
+}
 
-	return results, nil
+// GetStation ดึงสถานีตาม station_code
+func (s *StationService) GetStation(code int) (*models.Station, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return s.repo.FindByCode(ctx, code)
 }
 
+// GetStationHistory ดึงประวัติการเปลี่ยนแปลงของสถานีเรียงจากใหม่ไปเก่า
+func (s *StationService) GetStationHistory(code, page, limit int) (*dto.PaginatedResponse[models.StationVersion], error) {
+	return s.history.History(code, page, limit)
+}
+
+// CreateStation เพิ่มสถานีใหม่จาก body (ใช้ชื่อ field ตาม models.FieldTypes)
+// ต้องมี station_code และห้ามซ้ำกับที่มีอยู่แล้ว actor คือคนที่แก้ไข บันทึกไว้ในประวัติของสถานี
+func (s *StationService) CreateStation(item map[string]interface{}, actor string) (*models.Station, error) {
+	fields, errs := utils.NormalizeStationFields(item)
+	//ถ้า station_code แปลงไม่ได้จะมี error อยู่ใน errs แล้ว ไม่ต้องเพิ่มซ้ำ
+	_, sent := item["station_code"]
+	if code, ok := fields["station_code"].(int); (!sent || ok) && code == 0 {
+		errs = append(errs, utils.FieldError{Field: "station_code", Value: item["station_code"], Reason: "is required"})
+	}
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+
+	st := &models.Station{}
+	utils.ApplyStationFields(st, fields)
 
-// GetNearbyStations ดึงสถานีที่ใกล้ที่สุดที่มี pagination
-// รับ lat long page และ limit คืนค่าเป็น slice ของ StationWithDistance ในรูปแบบของ PaginatedResponse(มาจากไฟล์ dto/station_response.go นะจ้ะ)
-func GetNearbyStationsPage(lat, long float64, page, limit int) (*dto.PaginatedResponse[dto.StationWithDistance], error) {
-	// ตั้ง context set timeout กัน query ค้าง
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	col := config.DB.Collection
-
-	//filter เฉพาะสถานีที่ active และ ใช้ &near เพื่อดูว่าตัวไหนใกล้เคียงกับ lat long ที่ใส่มา
-	filter := bson.M{
-		"active": 1,
-		"location": bson.M{
-			"$near": bson.M{
-				"$geometry": bson.M{
-					"type": "Point",
-					"coordinates": []float64{long, lat},
-				},
-			},
-		},
+	if err := s.repo.InsertStation(ctx, st); err != nil {
+		//station_code ชนกับสถานีที่ถูกลบ บอกให้ restore แทน
+		if errors.Is(err, repositories.ErrStationExists) {
+			if _, findErr := s.repo.FindDeletedByCode(ctx, st.StationCode); findErr == nil {
+				return nil, ErrStationDeleted
+			}
+		}
+		return nil, err
+	}
+	if err := s.history.recordStation(ctx, nil, st, actor); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// ReplaceStation แทนที่ข้อมูลสถานีทั้งก้อน (PUT) field ที่ไม่ได้ส่งมาจะเป็นค่าว่าง
+func (s *StationService) ReplaceStation(code int, item map[string]interface{}, actor string) (*models.Station, error) {
+	fields, errs := normalizeForCode(code, item)
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
 	}
 
-	//set start ไว้
-	start := int64((page - 1) * limit)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	//ค้นหาข้อมูลใน collection โดยใส่ context ไว้ว่าถ้าเวลาเกิน 10วิ ให้ cancel ไป
-	//ให้ไปค้าหาข้อมูลที่เริ่มต้นด้วย start และสิ้นสุดที่จำนวณ limit
-	cur, err := col.Find(ctx, filter,
-		options.Find().SetSkip(start).SetLimit(int64(limit)),
-	)
+	//อ่านค่าเดิมไว้บันทึกประวัติ
+	before, err := s.repo.FindByCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &models.Station{}
+	utils.ApplyStationFields(st, fields)
+	st.StationCode = code
+
+	if err := s.repo.ReplaceByCode(ctx, code, st); err != nil {
+		return nil, err
+	}
+	if err := s.history.recordStation(ctx, before, st, actor); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// PatchStation แก้ไขเฉพาะ field ที่ส่งมา (PATCH) แล้วสร้าง location ใหม่ให้ตรงกับ lat long
+func (s *StationService) PatchStation(code int, item map[string]interface{}, actor string) (*models.Station, error) {
+	fields, errs := normalizeForCode(code, item)
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	st, err := s.repo.FindByCode(ctx, code)
 	if err != nil {
 		return nil, err
 	}
-	defer cur.Close(ctx)
+	before := *st
+	utils.ApplyStationFields(st, fields)
 
-	//สร้าง slice มาเก็บข้อมูล ให้อยู่ในรูปแบบของ station_model.go
-	//แปลงข้อมูล cur ตาม station_model.go
-	var stations []models.Station
-	if err := cur.All(ctx, &stations); err != nil {
+	if err := s.repo.ReplaceByCode(ctx, code, st); err != nil {
+		return nil, err
+	}
+	if err := s.history.recordStation(ctx, &before, st, actor); err != nil {
 		return nil, err
 	}
+	return st, nil
+}
 
-	//สร้าง slice มาเก็บข้อมูล ให้อยู่ในรูปแบบของ station_response.go
-	results := make([]dto.StationWithDistance, 0, len(stations))
-	// loop stations ทั้งหมดโดยแทน s คือ station แต่ละตัว
-	// โดยเข้า func คำนวณระยะทาง lat long คือค่าที่รับมา s.lat s.long คือค่าใน station
-	for _, s := range stations {
-		dist := utils.Haversine(lat, long, s.Lat, s.Long)
-		//ถ้าระยะทางที่คำนวณได้น้อยกว่าหรือเท่ากับรัศมีที่กำหนดไว้
-		//เพิ่มข้อมูลที่ผ่านการคำนวณระยะทางแล้ว ไปใส่ไว้ใน results ตามรูปแบบของ struct station_response.go
-		results = append(results, dto.StationWithDistance{
-			ID:          s.ID,
-			StationCode: s.StationCode,
-			Name:        s.Name,
-			EnName:      s.EnName,
-			Lat:         s.Lat,
-			Long:        s.Long,
-			DistanceKM:  dist,
-		})
+// DeleteStation ลบสถานีตาม station_code แบบ soft delete (ใส่ deleted_at และ deleted_by)
+// สถานีที่ถูกลบจะไม่แสดงในทุก query จนกว่าจะ restore หรือถูก purge เมื่อเกินระยะเวลาที่เก็บไว้
+func (s *StationService) DeleteStation(code int, actor string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	before, err := s.repo.FindByCode(ctx, code)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := s.repo.SoftDelete(ctx, code, now, actor); err != nil {
+		return err
 	}
+	after := *before
+	after.DeletedAt, after.DeletedBy = &now, actor
+	return s.history.recordStation(ctx, before, &after, actor)
+}
 
-	//เรียงลำดับข้อมูล results จากน้อยไปมาก
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].DistanceKM < results[j].DistanceKM
-	})
+// RestoreStation คืนสถานีที่ถูกลบ (soft delete) กลับมา ถ้าไม่มีสถานีที่ถูกลบตาม station_code คืน ErrStationNotFound
+func (s *StationService) RestoreStation(code int, actor string) (*models.Station, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	total, err := col.CountDocuments(ctx, bson.M{"active": 1})
+	before, err := s.repo.FindDeletedByCode(ctx, code)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.repo.Restore(ctx, code); err != nil {
+		return nil, err
+	}
+	after := *before
+	after.DeletedAt, after.DeletedBy = nil, ""
+	if err := s.history.recordStation(ctx, before, &after, actor); err != nil {
+		return nil, err
+	}
+	return &after, nil
+}
 
-	return &dto.PaginatedResponse[dto.StationWithDistance]{
-		Page:     page,
-		PageSize: limit,
-		Total:    int(total),
-		Start:    int(start) + 1,
-		End:      int(start) + len(results),
-		Data:     results,
-	}, nil
+// ExportGTFSStops เขียนสถานีที่ active ทั้งหมดเป็นไฟล์ stops.txt ของ GTFS ลง w
+// stops.txt ไม่มีสถานะ active ถ้าใส่สถานีที่ปิดอยู่ไปด้วย feed จะบอกว่าสถานีนั้นยังใช้งานได้
+func (s *StationService) ExportGTFSStops(w io.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	stops, err := parsers.NewGTFSStopsWriter(w)
+	if err != nil {
+		return err
+	}
+	err = s.repo.ForEach(ctx, false, func(st models.Station) error {
+		if st.Active != 1 {
+			return nil
+		}
+		return stops.Write(st)
+	})
+	if err != nil {
+		return err
+	}
+	return stops.Flush()
+}
+
+// refreshBatchSize จำนวนสถานีที่เขียนต่อครั้งใน RefreshSearchKeys
+const refreshBatchSize = 1000
+
+// RefreshSearchKeys คำนวณค่าที่ใช้ค้นหา (utils.ApplySearchKeys) ของทุกสถานีรวมที่ถูกลบใหม่ แล้วเขียนเฉพาะสถานีที่ค่าไม่ตรง
+// ใช้ตอน start กับสถานีที่เขียนก่อนมี field เหล่านี้ หรือหลังเปลี่ยนวิธีถอดอักษร ไม่บันทึกประวัติเพราะข้อมูลของสถานีไม่ได้เปลี่ยน
+// คืนจำนวนสถานีที่เขียน
+func (s *StationService) RefreshSearchKeys() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	//เก็บรายการไว้ก่อนแล้วค่อยเขียนหลังอ่านครบ ไม่เขียนระหว่าง ForEach
+	var updates []repositories.StationUpsert
+	err := s.repo.ForEach(ctx, true, func(st models.Station) error {
+		next := st
+		utils.ApplySearchKeys(&next)
+		if next.EnNameGenerated == st.EnNameGenerated && next.FuzzyName == st.FuzzyName &&
+			next.FuzzyEnName == st.FuzzyEnName && next.FuzzyRoman == st.FuzzyRoman {
+			return nil
+		}
+		updates = append(updates, repositories.StationUpsert{
+			Filter: map[string]interface{}{"_id": st.ID},
+			Fields: map[string]interface{}{
+				"en_name_generated": next.EnNameGenerated,
+				"fuzzy_name":        next.FuzzyName,
+				"fuzzy_en_name":     next.FuzzyEnName,
+				"fuzzy_roman":       next.FuzzyRoman,
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	written := 0
+	for start := 0; start < len(updates); start += refreshBatchSize {
+		end := min(start+refreshBatchSize, len(updates))
+		if _, err := s.repo.BulkUpdate(ctx, updates[start:end]); err != nil {
+			return written, err
+		}
+		written = end
+	}
+	return written, nil
+}
+
+// normalizeForCode ตรวจ body ของ PUT/PATCH
+// station_code ใน body (ถ้ามี) ต้องตรงกับใน path เพราะไม่อนุญาตให้เปลี่ยน key ของสถานี
+func normalizeForCode(code int, item map[string]interface{}) (map[string]interface{}, []utils.FieldError) {
+	fields, errs := utils.NormalizeStationFields(item)
+	if bodyCode, ok := fields["station_code"].(int); ok && bodyCode != code {
+		errs = append(errs, utils.FieldError{
+			Field:  "station_code",
+			Value:  item["station_code"],
+			Reason: fmt.Sprintf("must match station_code in path (%d)", code),
+		})
+	}
+	return fields, errs
 }
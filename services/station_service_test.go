@@ -0,0 +1,49 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGTFSExportRoundTrip(t *testing.T) {
+	f := newImportFixture(t, nil)
+	ctx := context.Background()
+	file := "id,station_code,name,lat,long,active\n11,1001,กรุงเทพ,13.74,100.51,1\n12,1002,สามเสน,13.78,100.51,0\n13,1003,บางซื่อ,13.80,100.54,1\n"
+	if _, err := f.importCSV(t, file, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := NewStationService(f.stations, f.history).ExportGTFSStops(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "1002") {
+		t.Errorf("export contains inactive station 1002:\n%s", buf.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "stops.txt")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	res, err := f.svc.ImportFileStations(ctx, "stops.txt", "text/plain", path, ImportOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Format != "gtfs" || res.Updated != 0 || res.Inserted != 0 {
+		t.Errorf("re-import format %q inserted %d updated %d, want gtfs with nothing changed", res.Format, res.Inserted, res.Updated)
+	}
+
+	for code, want := range map[int]struct{ id, active int }{1001: {11, 1}, 1002: {12, 0}, 1003: {13, 1}} {
+		st, err := f.stations.FindByCode(ctx, code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.StationID != want.id || st.Active != want.active {
+			t.Errorf("station %d: id %d active %d, want id %d active %d", code, st.StationID, st.Active, want.id, want.active)
+		}
+	}
+}
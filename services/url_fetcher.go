@@ -0,0 +1,349 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+)
+
+var (
+	// ErrURLNotAllowed ใช้เมื่อ URL (หรือ URL ที่ถูก redirect ไป) ไม่อยู่ใน allowlist
+	ErrURLNotAllowed = errors.New("url is not allowed")
+	// ErrAddressBlocked ใช้เมื่อ host ชี้ไปที่ IP ภายใน (private, loopback, link-local)
+	ErrAddressBlocked = errors.New("address is not allowed")
+	// ErrResponseTooLarge ใช้เมื่อ response ใหญ่เกิน maxBytes
+	ErrResponseTooLarge = errors.New("response is too large")
+	// ErrNotModified ใช้เมื่อ server ตอบ 304 คือข้อมูลไม่เปลี่ยนตั้งแต่ครั้งก่อน (ดู CacheValidators)
+	ErrNotModified = errors.New("not modified")
+)
+
+// maxFetchRedirects จำนวน redirect สูงสุดที่ยอมตาม
+const maxFetchRedirects = 5
+
+// URLFetcher ดาวน์โหลดไฟล์จาก URL ที่ผู้ใช้ส่งมาอย่างปลอดภัย
+// ตรวจ scheme และ host กับ allowlist ทุกครั้งรวมถึงหลัง redirect
+// ไม่ยอมต่อไปที่ IP ภายใน (ตรวจตอน dial จึงครอบคลุม DNS ที่เปลี่ยนค่าและ redirect ด้วย)
+// จำกัดขนาดของ response และลองใหม่แบบ backoff เมื่อเจอ error ของ network หรือ 5xx/429
+type URLFetcher struct {
+	client       *http.Client
+	schemes      map[string]bool
+	hosts        []string // ว่างคือยอมทุก host ขึ้นต้นด้วย *. คือยอมทุก subdomain
+	allowPrivate bool
+	blocked      func(ip netip.Addr) bool // IP ที่ไม่ยอมให้ต่อ ค่าเริ่มต้นคือ isBlockedIP
+	maxBytes     int64
+	retries      int
+	backoff      time.Duration
+}
+
+// FetchedFile ไฟล์ที่ดาวน์โหลดมาเก็บไว้ที่ไฟล์ชั่วคราว Path คนเรียกต้องลบเองเมื่อใช้เสร็จ
+type FetchedFile struct {
+	Path         string
+	Filename     string
+	ContentType  string
+	ETag         string
+	LastModified string
+}
+
+// CacheValidators ETag และ Last-Modified จากครั้งก่อน ส่งไปเป็น If-None-Match และ If-Modified-Since
+type CacheValidators struct {
+	ETag         string
+	LastModified string
+}
+
+// NewURLFetcher สร้าง fetcher
+// schemes และ hosts คือ allowlist (hosts ว่างคือยอมทุก host) allowPrivate ยอมให้ต่อ IP ภายในได้ (ใช้ตอนทดสอบ)
+// maxBytes ขนาด response สูงสุด retries จำนวนครั้งที่ลองใหม่ timeout เวลาสูงสุดของแต่ละครั้ง
+func NewURLFetcher(schemes, hosts []string, allowPrivate bool, maxBytes int64, retries int, timeout time.Duration) *URLFetcher {
+	f := &URLFetcher{
+		schemes:      make(map[string]bool, len(schemes)),
+		allowPrivate: allowPrivate,
+		blocked:      isBlockedIP,
+		maxBytes:     maxBytes,
+		retries:      retries,
+		backoff:      500 * time.Millisecond,
+	}
+	for _, s := range schemes {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			f.schemes[s] = true
+		}
+	}
+	for _, h := range hosts {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			f.hosts = append(f.hosts, h)
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: f.checkDial}
+	f.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			//ไม่ใช้ proxy เพราะต้องตรวจ IP ปลายทางจริงตอน dial
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxFetchRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+			}
+			//ไม่ส่ง auth ต่อไปยัง host อื่น
+			if req.URL.Host != via[0].URL.Host {
+				req.Header.Del("Authorization")
+			}
+			return f.CheckURL(req.URL.String())
+		},
+	}
+	return f
+}
+
+// CheckURL ตรวจ scheme และ host ของ URL กับ allowlist (ยังไม่ได้ตรวจ IP ซึ่งจะตรวจตอนต่อจริง)
+func (f *URLFetcher) CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if !f.schemes[strings.ToLower(u.Scheme)] {
+		return fmt.Errorf("%w: scheme %q", ErrURLNotAllowed, u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("invalid url: missing host")
+	}
+	if len(f.hosts) == 0 {
+		return nil
+	}
+	for _, h := range f.hosts {
+		if host == h || strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:]) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: host %q", ErrURLNotAllowed, host)
+}
+
+// checkDial ตรวจ IP ที่กำลังจะต่อ ถูกเรียกหลัง resolve DNS แล้วทุกครั้งที่เปิด connection
+func (f *URLFetcher) checkDial(network, address string, _ syscall.RawConn) error {
+	if f.allowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if f.blocked(ip.Unmap()) {
+		return fmt.Errorf("%w: %s", ErrAddressBlocked, ip)
+	}
+	return nil
+}
+
+// ช่วง IP ภายในที่ netip ไม่นับเป็น private
+var (
+	// sharedAddressSpace 100.64.0.0/10 (carrier-grade NAT)
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+	// thisNetwork 0.0.0.0/8 บน Linux การต่อไปที่ 0.0.0.0 คือต่อเข้าเครื่องตัวเอง
+	thisNetwork = netip.MustParsePrefix("0.0.0.0/8")
+	// nat64Prefix 64:ff9b::/96 บนเครือข่ายที่มี NAT64 จะถูกแปลงเป็น IPv4 ข้างใน เช่น 64:ff9b::7f00:1 คือ 127.0.0.1
+	nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+)
+
+func isBlockedIP(ip netip.Addr) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip) || thisNetwork.Contains(ip) || nat64Prefix.Contains(ip)
+}
+
+// Fetch ดาวน์โหลด rawURL ลงไฟล์ชั่วคราว auth และ validators เป็น nil ได้ถ้าไม่ต้องใช้
+// ถ้าส่ง validators มาและ server ตอบ 304 จะคืน ErrNotModified
+// status อื่นที่ไม่ใช่ 2xx เป็น error ลองใหม่เฉพาะ error ของ network, 5xx และ 429
+func (f *URLFetcher) Fetch(ctx context.Context, rawURL string, auth *models.FetchAuth, validators *CacheValidators) (*FetchedFile, error) {
+	if err := f.CheckURL(rawURL); err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= f.retries; attempt++ {
+		if attempt > 0 {
+			if err := sleepContext(ctx, f.retryDelay(attempt, lastErr)); err != nil {
+				return nil, err
+			}
+		}
+
+		file, err := f.fetchOnce(ctx, rawURL, auth, validators)
+		if err == nil {
+			return file, nil
+		}
+		lastErr = err
+		if !isRetryable(err) || ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// statusError response ที่ status ไม่ใช่ 2xx
+type statusError struct {
+	code       int
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s", e.code, http.StatusText(e.code))
+}
+
+func (f *URLFetcher) fetchOnce(ctx context.Context, rawURL string, auth *models.FetchAuth, validators *CacheValidators) (*FetchedFile, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := setAuth(req, auth); err != nil {
+		return nil, err
+	}
+	if validators != nil {
+		if validators.ETag != "" {
+			req.Header.Set("If-None-Match", validators.ETag)
+		}
+		if validators.LastModified != "" {
+			req.Header.Set("If-Modified-Since", validators.LastModified)
+		}
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && validators != nil {
+		return nil, ErrNotModified
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		return nil, &statusError{code: resp.StatusCode, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+	if f.maxBytes > 0 && resp.ContentLength > f.maxBytes {
+		return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrResponseTooLarge, resp.ContentLength, f.maxBytes)
+	}
+
+	//อ่านเกิน maxBytes มา 1 byte เพื่อรู้ว่า response ใหญ่เกินหรือไม่ (กรณีไม่มี Content-Length)
+	body := io.Reader(resp.Body)
+	if f.maxBytes > 0 {
+		body = io.LimitReader(resp.Body, f.maxBytes+1)
+	}
+	path, err := SpoolToTempFile(body)
+	if err != nil {
+		return nil, err
+	}
+	if f.maxBytes > 0 {
+		if info, err := os.Stat(path); err == nil && info.Size() > f.maxBytes {
+			os.Remove(path)
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, f.maxBytes)
+		}
+	}
+
+	return &FetchedFile{
+		Path:         path,
+		Filename:     responseFilename(resp),
+		ContentType:  resp.Header.Get("Content-Type"),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// CheckAuth ตรวจว่า auth ครบตามชนิด (nil หรือ type none คือไม่ใช้ auth)
+func CheckAuth(auth *models.FetchAuth) error {
+	if auth == nil {
+		return nil
+	}
+	switch strings.ToLower(auth.Type) {
+	case "", "none":
+	case "bearer":
+		if auth.Token == "" {
+			return errors.New("bearer auth requires token")
+		}
+	case "basic":
+		if auth.Username == "" {
+			return errors.New("basic auth requires username")
+		}
+	default:
+		return fmt.Errorf("unsupported auth type %q use bearer or basic", auth.Type)
+	}
+	return nil
+}
+
+// setAuth ใส่ header Authorization ตาม auth
+func setAuth(req *http.Request, auth *models.FetchAuth) error {
+	if err := CheckAuth(auth); err != nil || auth == nil {
+		return err
+	}
+	switch strings.ToLower(auth.Type) {
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+	case "basic":
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+	return nil
+}
+
+// isRetryable error ที่ลองใหม่แล้วอาจสำเร็จ
+func isRetryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500 || se.code == http.StatusTooManyRequests
+	}
+	if errors.Is(err, ErrURLNotAllowed) || errors.Is(err, ErrAddressBlocked) || errors.Is(err, ErrResponseTooLarge) || errors.Is(err, ErrNotModified) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryDelay เวลารอก่อนลองครั้งที่ attempt เพิ่มเป็นเท่าตัวทุกครั้ง ถ้า server ส่ง Retry-After มาใช้ค่านั้น (ไม่เกิน 30 วินาที)
+func (f *URLFetcher) retryDelay(attempt int, lastErr error) time.Duration {
+	var se *statusError
+	if errors.As(lastErr, &se) && se.retryAfter > 0 {
+		return min(se.retryAfter, 30*time.Second)
+	}
+	return f.backoff << (attempt - 1)
+}
+
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if n, err := strconv.Atoi(v); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
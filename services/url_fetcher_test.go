@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIsBlockedIP(t *testing.T) {
+	tests := []struct {
+		addr    string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"0.1.2.3", true},
+		{"224.0.0.1", true},
+		{"::1", true},
+		{"::", true},
+		{"fe80::1", true},
+		{"fc00::1", true},
+		{"64:ff9b::7f00:1", true},
+		{"64:ff9b::a9fe:a9fe", true},
+		{"8.8.8.8", false},
+		{"1.1.1.1", false},
+		{"100.128.0.1", false},
+		{"2001:4860:4860::8888", false},
+		{"64:ff9c::1", false},
+	}
+	for _, tt := range tests {
+		ip := netip.MustParseAddr(tt.addr)
+		if got := isBlockedIP(ip); got != tt.blocked {
+			t.Errorf("isBlockedIP(%s) = %v, want %v", tt.addr, got, tt.blocked)
+		}
+	}
+
+	//IPv4 ที่เขียนแบบ IPv6 (::ffff:a.b.c.d) ถูกตรวจแบบ IPv4 ตอน dial
+	if !isBlockedIP(netip.MustParseAddr("::ffff:127.0.0.1").Unmap()) {
+		t.Errorf("isBlockedIP(::ffff:127.0.0.1) = false, want true")
+	}
+}
+
+func TestURLFetcherBlocksAddressAfterRedirect(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("station_code\n1001\n"))
+	}))
+	defer target.Close()
+
+	tests := []struct {
+		location string
+		blocked  bool
+	}{
+		{target.URL + "/stations.csv", false},
+		{"http://0.1.2.3/stations.csv", true},
+		{"http://[64:ff9b::7f00:1]/stations.csv", true},
+		{"http://169.254.169.254/latest/meta-data/", true},
+		{"http://10.0.0.1/stations.csv", true},
+	}
+	for _, tt := range tests {
+		origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, tt.location, http.StatusFound)
+		}))
+
+		f := NewURLFetcher([]string{"http"}, nil, false, 1<<20, 0, 5*time.Second)
+		//ยอมให้ต่อ server ของเทสที่ 127.0.0.1 ได้ ที่เหลือตรวจตามปกติ
+		loopback := netip.MustParseAddr("127.0.0.1")
+		f.blocked = func(ip netip.Addr) bool {
+			return ip != loopback && isBlockedIP(ip)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		file, err := f.Fetch(ctx, origin.URL, nil, nil)
+		cancel()
+		origin.Close()
+
+		if tt.blocked {
+			if !errors.Is(err, ErrAddressBlocked) {
+				t.Errorf("redirect to %s: got %v, want ErrAddressBlocked", tt.location, err)
+			}
+		} else if err != nil {
+			t.Errorf("redirect to %s: %v", tt.location, err)
+		}
+		if file != nil {
+			os.Remove(file.Path)
+		}
+	}
+}
+
+func TestURLFetcherBlocksLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached blocked address")
+	}))
+	defer srv.Close()
+
+	f := NewURLFetcher([]string{"http"}, nil, false, 1<<20, 0, 5*time.Second)
+	if _, err := f.Fetch(context.Background(), srv.URL, nil, nil); !errors.Is(err, ErrAddressBlocked) {
+		t.Errorf("Fetch(%s) = %v, want ErrAddressBlocked", srv.URL, err)
+	}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	//วันพฤหัสบดีที่ 1 มกราคม 2026 เวลา 10:30
+	from := time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", at(1, 1, 10, 45)},
+		{"0 * * * *", at(1, 1, 11, 0)},
+		{"@hourly", at(1, 1, 11, 0)},
+		{"@daily", at(1, 2, 0, 0)},
+		{"30 2 * * mon-fri", at(1, 2, 2, 30)},
+		{"0 0 * * 0", at(1, 4, 0, 0)},
+		{"0 0 * * 7", at(1, 4, 0, 0)},
+		{"@weekly", at(1, 4, 0, 0)},
+		{"0 0 15 * *", at(1, 15, 0, 0)},
+		{"0 0 1 feb *", at(2, 1, 0, 0)},
+		//กำหนดทั้งวันที่และวันในสัปดาห์ รันเมื่อตรงอย่างใดอย่างหนึ่ง: วันที่ 15 หรือวันจันทร์ (5 มกราคม)
+		{"0 0 15 * mon", at(1, 5, 0, 0)},
+		//วันที่ 2 มาก่อนวันจันทร์
+		{"0 0 2 * mon", at(1, 2, 0, 0)},
+		//วันในสัปดาห์เป็น * ใช้แค่วันที่
+		{"0 0 5 * *", at(1, 5, 0, 0)},
+		//วันที่เป็น */n ถือว่าเป็น * ใช้แค่วันในสัปดาห์
+		{"0 0 */1 * fri", at(1, 2, 0, 0)},
+		//ไม่มีวันที่ 30 กุมภาพันธ์
+		{"0 0 30 2 *", time.Time{}},
+		{"@every 90m", at(1, 1, 12, 0)},
+		{"@every 1h30m15s", from.Add(90*time.Minute + 15*time.Second)},
+	}
+	for _, tt := range tests {
+		s, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("ParseCron(%q).Next(%s) = %s, want %s", tt.expr, from, got, tt.want)
+		}
+	}
+}
+
+func TestCronEveryKeepsInterval(t *testing.T) {
+	s, err := ParseCron("@every 10m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//@every นับจากเวลาที่ส่งเข้ามา ไม่ปัดไปที่ต้นนาที
+	from := time.Date(2026, 1, 1, 10, 0, 42, 500, time.UTC)
+	if got, want := s.Next(from), time.Date(2026, 1, 1, 10, 10, 42, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next(%s) = %s, want %s", from, got, want)
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@every 30s",
+		"@every soon",
+		"@often",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want error", expr)
+		}
+	}
+}
@@ -24,8 +24,12 @@ func httpStatusText(status int) string {
 		return "Forbidden"
 	case fiber.StatusNotFound:
 		return "Not Found"
+	case fiber.StatusConflict:
+		return "Conflict"
 	case fiber.StatusInternalServerError:
 		return "Internal Server Error"
+	case fiber.StatusServiceUnavailable:
+		return "Service Unavailable"
 	default:
 		return "Error"
 	}
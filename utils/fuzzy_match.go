@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+)
+
+// ตัวสะกดอักษรโรมันที่คนเขียนชื่อไทยต่างกันบ่อย แปลงให้เป็นแบบเดียวกันก่อนเทียบ
+// เช่น "Lampong" กับ "Lamphong", "Muang" กับ "Mueang", "Jatujak" กับ "Chatuchak"
+var romanVariants = strings.NewReplacer(
+	"ph", "p", "th", "t", "kh", "k", "ch", "c", "j", "c",
+	"ue", "u", "oe", "o", "ae", "a", "v", "w",
+)
+
+// FuzzyKey ทำข้อความอักษรโรมันให้เทียบแบบไม่เข้มงวดได้ ตัวพิมพ์เล็ก ไม่มีช่องว่างและเครื่องหมาย
+// ตัวสะกดที่ต่างกันบ่อยถูกแปลงเป็นแบบเดียวกันและตัวอักษรซ้ำติดกันเหลือตัวเดียว
+// เช่น "Hua Lampong" และ "Hualamphong" ได้ "hualampong" ทั้งคู่
+func FuzzyKey(s string) string {
+	key := romanVariants.Replace(CompactSearchText(s))
+	var b strings.Builder
+	var prev rune
+	for _, r := range key {
+		if r != prev || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+		prev = r
+	}
+	return b.String()
+}
+
+// ApplySearchKeys ใส่ค่าที่คำนวณจากชื่อของสถานี เรียกทุกครั้งที่เขียนสถานี (ผ่าน ApplyStationFields) ค่าจึงตรงกับชื่อเสมอ
+//   - EnNameGenerated ชื่อไทยที่ถอดเป็นอักษรโรมัน เฉพาะสถานีที่ไม่มี en_name
+//   - FuzzyName FuzzyEnName FuzzyRoman ใช้หาตัวเลือกของการค้นหาแบบไม่เข้มงวดใน database โดยไม่ต้องถอดอักษรทุกสถานีตอนค้น
+func ApplySearchKeys(st *models.Station) {
+	romanized := RomanizeThai(st.Name)
+	st.EnNameGenerated = ""
+	if st.EnName == "" {
+		st.EnNameGenerated = romanized
+	}
+	st.FuzzyName = CompactSearchText(st.Name)
+	st.FuzzyEnName = FuzzyKey(st.EnName)
+	st.FuzzyRoman = FuzzyKey(romanized)
+}
+
+// CompactSearchText ข้อความตัวพิมพ์เล็กที่ตัดช่องว่างและเครื่องหมายออกทั้งหมด
+// ใช้เทียบชื่อที่เว้นวรรคต่างกัน เช่น "Hua Lamphong" กับ "Hualamphong"
+func CompactSearchText(s string) string {
+	return strings.Join(SearchTerms(s), "")
+}
+
+// HasThai เช็คว่าข้อความมีตัวอักษรไทย
+func HasThai(s string) bool {
+	return strings.IndexFunc(s, isThai) >= 0
+}
+
+// FuzzyTolerance จำนวนตัวอักษรที่ยอมให้ต่างได้ตามความยาวของคำค้น (นับเป็นตัวอักษร)
+// คำค้นสั้นต้องตรงทั้งหมด ไม่อย่างนั้นจะเจอชื่อที่ไม่เกี่ยวข้องจำนวนมาก
+func FuzzyTolerance(n int) int {
+	switch {
+	case n < 5:
+		return 0
+	case n < 9:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// EditDistance ระยะ Levenshtein ระหว่าง a กับ b (จำนวนตัวอักษรที่ต้องเพิ่ม ลบ หรือแทนที่) นับเป็น rune
+func EditDistance(a, b string) int {
+	return editDistance([]rune(a), []rune(b), false)
+}
+
+// PrefixEditDistance ระยะ Levenshtein ที่น้อยที่สุดระหว่าง q กับส่วนต้นของ s
+// ใช้กับคำค้นที่พิมพ์ยังไม่จบ เช่น "hualam" กับ "hualamphong" ได้ 0
+func PrefixEditDistance(q, s string) int {
+	return editDistance([]rune(q), []rune(s), true)
+}
+
+// editDistance คำนวณแบบ dynamic programming ทีละแถวของ a
+// prefix เป็น true คือไม่คิดตัวที่เหลือท้าย b (เลือกค่าน้อยสุดของแถวสุดท้าย)
+func editDistance(a, b []rune, prefix bool) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		diag := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			next := min(row[j]+1, row[j-1]+1, diag+cost)
+			diag, row[j] = row[j], next
+		}
+	}
+	if !prefix {
+		return row[len(b)]
+	}
+	best := row[0]
+	for _, d := range row[1:] {
+		best = min(best, d)
+	}
+	return best
+}
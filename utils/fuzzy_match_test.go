@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+)
+
+func TestFuzzyKey(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Hua Lampong", "hualampong"},
+		{"Hualamphong", "hualampong"},
+		{"Hua-Lamphong", "hualampong"},
+		{"Don Muang", "donmuang"},
+		{"Donmueang", "donmuang"},
+		{"Jatujak", "catucak"},
+		{"Chatuchak", "catucak"},
+		{"Thanon", "tanon"},
+		{"Ayutthaya", "ayutaya"},
+		//ตัวเลขซ้ำกันไม่ถูกรวม
+		{"KM 11", "km11"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := FuzzyKey(tt.in); got != tt.want {
+			t.Errorf("FuzzyKey(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyTolerance(t *testing.T) {
+	tests := []struct {
+		n, want int
+	}{
+		{0, 0}, {4, 0}, {5, 1}, {8, 1}, {9, 2}, {20, 2},
+	}
+	for _, tt := range tests {
+		if got := FuzzyTolerance(tt.n); got != tt.want {
+			t.Errorf("FuzzyTolerance(%d) = %d, want %d", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b         string
+		full, prefix int
+	}{
+		{"", "", 0, 0},
+		{"abc", "", 3, 3},
+		{"", "abc", 3, 0},
+		{"kitten", "sitting", 3, 2},
+		{"hualam", "hualampong", 4, 0},
+		{"hualanpong", "hualampong", 1, 1},
+		{"hulampong", "hualampong", 1, 1},
+		{"หัวลำโพง", "หัวลำโพง", 0, 0},
+		//นับเป็น rune ไม่ใช่ byte
+		{"บางซือ", "บางซื่อ", 1, 1},
+	}
+	for _, tt := range tests {
+		if got := EditDistance(tt.a, tt.b); got != tt.full {
+			t.Errorf("EditDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.full)
+		}
+		if got := PrefixEditDistance(tt.a, tt.b); got != tt.prefix {
+			t.Errorf("PrefixEditDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.prefix)
+		}
+	}
+}
+
+func TestApplySearchKeys(t *testing.T) {
+	tests := []struct {
+		name, enName                         string
+		generated, fuzzyName, fuzzyEn, roman string
+	}{
+		{"หัวลำโพง", "", "Hualamphong", "หัวลำโพง", "", "hualampong"},
+		{"หัวลำโพง", "Hua Lamphong", "", "หัวลำโพง", "hualampong", "hualampong"},
+		{"ดอน เมือง", "Don Muang", "", "ดอนเมือง", "donmuang", "donmuang"},
+		{"", "", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		//ค่าเดิมที่ค้างอยู่ต้องถูกแทนที่ทั้งหมด
+		st := models.Station{Name: tt.name, EnName: tt.enName, EnNameGenerated: "old", FuzzyEnName: "old"}
+		ApplySearchKeys(&st)
+		if st.EnNameGenerated != tt.generated || st.FuzzyName != tt.fuzzyName || st.FuzzyEnName != tt.fuzzyEn || st.FuzzyRoman != tt.roman {
+			t.Errorf("ApplySearchKeys(%q, %q) = generated %q name %q en %q roman %q, want %q %q %q %q",
+				tt.name, tt.enName, st.EnNameGenerated, st.FuzzyName, st.FuzzyEnName, st.FuzzyRoman,
+				tt.generated, tt.fuzzyName, tt.fuzzyEn, tt.roman)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/Teneieiza/go-spinsolf-test/dto"
+	"github.com/Teneieiza/go-spinsolf-test/models"
+)
+
+// วิธีที่ใช้จับคู่ header กับ field
+const (
+	HeaderProfile    = "profile"    // ตรงกับ column ใน mapping profile
+	HeaderExact      = "exact"      // ตรงกับชื่อ field
+	HeaderNormalized = "normalized" // ตรงกับชื่อ field หลังตัดตัวพิมพ์/ช่องว่าง/เครื่องหมาย
+	HeaderSynonym    = "synonym"    // ตรงกับคำพ้องใน headerSynonyms
+	HeaderDuplicate  = "duplicate"  // จับคู่ได้แต่ field นี้ถูก column ก่อนหน้าใช้ไปแล้ว
+	HeaderUnmapped   = "unmapped"   // จับคู่ไม่ได้ ใช้ชื่อเดิม
+)
+
+// headerSynonyms คำที่มักใช้เป็นชื่อ column ของแต่ละ field (รวมชื่อภาษาไทย)
+// เขียนแบบไหนก็ได้ จะถูก NormalizeHeader ก่อนเทียบ
+var headerSynonyms = map[string][]string{
+	"id":              {"station id", "stationid", "ไอดี", "ไอดีสถานี"},
+	"station_code":    {"code", "station no", "station number", "stn code", "stop code", "รหัสสถานี", "รหัส"},
+	"name":            {"station name", "name th", "th name", "thai name", "station name th", "ชื่อสถานี", "ชื่อ", "ชื่อภาษาไทย", "ชื่อสถานีภาษาไทย"},
+	"en_name":         {"english name", "name en", "eng name", "station name en", "station name english", "ชื่อภาษาอังกฤษ", "ชื่อสถานีภาษาอังกฤษ"},
+	"th_short":        {"thai short", "short name th", "short th", "ชื่อย่อ", "ชื่อย่อภาษาไทย"},
+	"en_short":        {"english short", "short name en", "short en", "ชื่อย่อภาษาอังกฤษ"},
+	"chname":          {"chinese name", "ch name", "name cn", "name zh", "ชื่อภาษาจีน"},
+	"controldivision": {"control division", "division", "แขวงควบคุม", "เขตควบคุม"},
+	"exact_distance":  {"distance", "ระยะทาง"},
+	"km":              {"kilometer", "kilometre", "กม", "กิโลเมตร"},
+	"class":           {"station class", "ชั้นสถานี", "ชั้น"},
+	"lat":             {"latitude", "ละติจูด", "ลติจูด", "ละติจูต"},
+	"long":            {"lng", "lon", "longitude", "ลองจิจูด", "ลองติจูด"},
+	"active":          {"is active", "สถานะ", "เปิดใช้งาน"},
+	"giveway":         {"give way", "สถานีหลีก"},
+	"dual_track":      {"double track", "ทางคู่"},
+	"comment":         {"comments", "note", "notes", "remark", "remarks", "หมายเหตุ"},
+}
+
+// normalizedFields และ synonymFields คือชื่อ field และคำพ้องหลัง normalize -> ชื่อ field
+var normalizedFields, synonymFields = buildHeaderIndex()
+
+func buildHeaderIndex() (map[string]string, map[string]string) {
+	fields := make(map[string]string, len(models.FieldTypes))
+	for f := range models.FieldTypes {
+		fields[NormalizeHeader(f)] = f
+	}
+	synonyms := make(map[string]string)
+	for f, words := range headerSynonyms {
+		for _, w := range words {
+			synonyms[NormalizeHeader(w)] = f
+		}
+	}
+	return fields, synonyms
+}
+
+// NormalizeHeader ทำให้ชื่อ column เทียบกันได้ เปลี่ยนเป็นตัวพิมพ์เล็กแล้วเก็บเฉพาะตัวอักษรและตัวเลข
+// เช่น "Station Code", "STATION_CODE " และ "station-code" จะได้ "stationcode" เหมือนกัน
+// สระและวรรณยุกต์ภาษาไทยนับเป็นตัวอักษร
+func NormalizeHeader(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// HeaderResolver จับคู่ชื่อ column ในไฟล์กับ field ของเรา (models.FieldTypes)
+type HeaderResolver struct {
+	columns map[string]string
+}
+
+// NewHeaderResolver สร้าง resolver ถ้าส่ง columns ของ mapping profile มา จะใช้ก่อนกฎอื่น
+func NewHeaderResolver(columns map[string]string) *HeaderResolver {
+	return &HeaderResolver{columns: columns}
+}
+
+// Resolve จับคู่ header 1 ตัว คืนชื่อ field และวิธีที่ใช้จับคู่ ถ้าจับคู่ไม่ได้ field เป็น ""
+func (r *HeaderResolver) Resolve(header string) (field, method string) {
+	h := strings.TrimSpace(header)
+	if f, ok := r.columns[h]; ok {
+		return f, HeaderProfile
+	}
+	if _, ok := models.FieldTypes[h]; ok {
+		return h, HeaderExact
+	}
+	n := NormalizeHeader(h)
+	if f, ok := normalizedFields[n]; ok {
+		return f, HeaderNormalized
+	}
+	if f, ok := synonymFields[n]; ok {
+		return f, HeaderSynonym
+	}
+	return "", HeaderUnmapped
+}
+
+// ResolveHeaders จับคู่ header ทั้งแถว คืน key ที่ใช้กับแต่ละ column รายงานการจับคู่ และจำนวน column ที่จับคู่ได้
+// column ที่จับคู่ไม่ได้จะใช้ชื่อเดิม column ที่ field ซ้ำกับ column ก่อนหน้าจะได้ key เป็น "" (ไม่ต้องอ่าน)
+func (r *HeaderResolver) ResolveHeaders(headers []string) (keys []string, report []dto.HeaderMapping, matched int) {
+	keys = make([]string, len(headers))
+	report = make([]dto.HeaderMapping, 0, len(headers))
+	used := make(map[string]bool, len(headers))
+
+	for i, h := range headers {
+		field, method := r.Resolve(h)
+		if field != "" && used[field] {
+			field, method = "", HeaderDuplicate
+		}
+		switch {
+		case method == HeaderDuplicate:
+			keys[i] = ""
+		case field == "":
+			keys[i] = h
+		default:
+			keys[i] = field
+			used[field] = true
+			matched++
+		}
+		if strings.TrimSpace(h) != "" {
+			report = append(report, dto.HeaderMapping{Column: h, Field: field, Method: method})
+		}
+	}
+	return keys, report, matched
+}
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+)
+
+// MappingProfile กฎการแปลงแถวจากไฟล์ของ partner ให้เป็น field ของเรา (ตาม models.FieldTypes)
+// ทำตามลำดับ: เปลี่ยนชื่อ column -> transform -> ใส่ค่า default ให้ field ที่ไม่มีค่า
+type MappingProfile struct {
+	// Name ชื่อ profile ที่ใช้กับ profile= (มาจาก key ในไฟล์)
+	Name string `json:"name"`
+	// Columns ชื่อ column ในไฟล์ -> ชื่อ field ของเรา column ที่ไม่อยู่ในนี้จะใช้ชื่อเดิม
+	Columns map[string]string `json:"columns"`
+	// Defaults ค่าคงที่ของ field ที่ไม่มีในไฟล์หรือเป็นค่าว่าง
+	Defaults map[string]interface{} `json:"defaults"`
+	// Transforms แปลงค่าของ field ตามลำดับ
+	Transforms []MappingTransform `json:"transforms"`
+}
+
+// MappingTransform การแปลงค่า 1 ขั้น
+//   - trim ตัดช่องว่างหัวท้าย
+//   - upper, lower เปลี่ยนเป็นตัวพิมพ์ใหญ่/เล็ก
+//   - split แยกด้วย Sep แล้วใช้ส่วนที่ Index (เริ่มที่ 0)
+//   - scale คูณด้วย Factor เช่นแปลงหน่วย km เป็น m
+type MappingTransform struct {
+	Field  string  `json:"field"`
+	Op     string  `json:"op"`
+	Sep    string  `json:"sep,omitempty"`
+	Index  int     `json:"index,omitempty"`
+	Factor float64 `json:"factor,omitempty"`
+}
+
+// LoadMappingProfiles อ่าน profile ทั้งหมดจากไฟล์ JSON ในรูปแบบ {"ชื่อ profile": {...}}
+// ถ้า path ว่างจะคืน map ว่าง
+func LoadMappingProfiles(path string) (map[string]*MappingProfile, error) {
+	profiles := make(map[string]*MappingProfile)
+	if path == "" {
+		return profiles, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("invalid mapping profiles file %s: %w", path, err)
+	}
+	for name, p := range profiles {
+		p.Name = name
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("mapping profile %q: %w", name, err)
+		}
+	}
+	return profiles, nil
+}
+
+// validate ตรวจว่าทุก field ที่อ้างถึงมีอยู่ใน models.FieldTypes และ transform ถูกต้อง
+func (p *MappingProfile) validate() error {
+	known := func(f string) error {
+		if _, ok := models.FieldTypes[f]; !ok {
+			return fmt.Errorf("unknown field %q", f)
+		}
+		return nil
+	}
+	for _, target := range p.Columns {
+		if err := known(target); err != nil {
+			return err
+		}
+	}
+	for f := range p.Defaults {
+		if err := known(f); err != nil {
+			return err
+		}
+	}
+	for _, t := range p.Transforms {
+		if err := known(t.Field); err != nil {
+			return err
+		}
+		switch t.Op {
+		case "trim", "upper", "lower":
+		case "split":
+			if t.Sep == "" || t.Index < 0 {
+				return fmt.Errorf("split on %q needs sep and index >= 0", t.Field)
+			}
+		case "scale":
+			if t.Factor == 0 {
+				return fmt.Errorf("scale on %q needs a non-zero factor", t.Field)
+			}
+		default:
+			return fmt.Errorf("unknown transform %q", t.Op)
+		}
+	}
+	return nil
+}
+
+// Apply แปลงแถวข้อมูลตาม profile คืนแถวใหม่ (ไม่แก้ item เดิม) พร้อม error ของ transform ที่ทำไม่ได้
+// ใช้กับแถวที่ชื่อ column ยังเป็นชื่อในไฟล์ ถ้า parser จับคู่ header ด้วย Columns ไปแล้วให้ใช้ Convert
+func (p *MappingProfile) Apply(item map[string]interface{}) (map[string]interface{}, []FieldError) {
+	return p.Convert(p.Rename(item))
+}
+
+// Rename เปลี่ยนชื่อ column ตาม Columns คืนแถวใหม่ (ไม่แก้ item เดิม)
+// เปลี่ยนจากชื่อเดิมทั้งหมดพร้อมกัน จึงสลับชื่อกันได้ เช่น code -> station_code และ station_code -> id
+func (p *MappingProfile) Rename(item map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(item))
+
+	//column ที่ตั้งชื่อใหม่จะทับ column ที่ชื่อตรงกับ field อยู่แล้ว
+	for col, v := range item {
+		if _, renamed := p.Columns[col]; !renamed {
+			out[col] = v
+		}
+	}
+	cols := make([]string, 0, len(p.Columns))
+	for col := range p.Columns {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	for _, col := range cols {
+		if v, ok := item[col]; ok {
+			out[p.Columns[col]] = v
+		}
+	}
+	return out
+}
+
+// Convert ทำ transform และใส่ค่า default กับแถวที่ชื่อ column เป็นชื่อ field แล้ว คืนแถวใหม่ (ไม่แก้ item เดิม)
+func (p *MappingProfile) Convert(item map[string]interface{}) (map[string]interface{}, []FieldError) {
+	out := make(map[string]interface{}, len(item))
+	for k, v := range item {
+		out[k] = v
+	}
+
+	var errs []FieldError
+	for _, t := range p.Transforms {
+		v, ok := out[t.Field]
+		if !ok || v == nil {
+			continue
+		}
+		nv, err := t.apply(v)
+		if err != nil {
+			errs = append(errs, FieldError{Field: t.Field, Value: v, Reason: err.Error()})
+			continue
+		}
+		out[t.Field] = nv
+	}
+
+	for f, v := range p.Defaults {
+		if cur, ok := out[f]; !ok || cur == nil || NormalizeString(cur) == "" {
+			out[f] = v
+		}
+	}
+	return out, errs
+}
+
+func (t MappingTransform) apply(v interface{}) (interface{}, error) {
+	switch t.Op {
+	case "trim":
+		return NormalizeString(v), nil
+	case "upper":
+		return strings.ToUpper(NormalizeString(v)), nil
+	case "lower":
+		return strings.ToLower(NormalizeString(v)), nil
+	case "split":
+		parts := strings.Split(NormalizeString(v), t.Sep)
+		if t.Index >= len(parts) {
+			return nil, fmt.Errorf("split by %q has no part %d", t.Sep, t.Index)
+		}
+		return strings.TrimSpace(parts[t.Index]), nil
+	case "scale":
+		if NormalizeString(v) == "" {
+			return v, nil
+		}
+		f, err := NormalizeFloat(v)
+		if err != nil {
+			return nil, fmt.Errorf("scale needs a number")
+		}
+		f *= t.Factor
+		//field ที่เป็น int ให้ปัดเศษ ไม่ตัดทิ้ง
+		if models.FieldTypes[t.Field] == "int" {
+			return int(math.Round(f)), nil
+		}
+		return f, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMappingProfileSwappedColumns(t *testing.T) {
+	p := &MappingProfile{Columns: map[string]string{"code": "station_code", "station_code": "id"}}
+
+	//แถวจาก parser ที่ไม่จับคู่ header (json) ยังเป็นชื่อ column ในไฟล์ เปลี่ยนชื่อพร้อมกันทั้งหมด
+	got, errs := p.Apply(map[string]interface{}{"code": "1001", "station_code": "55"})
+	if len(errs) > 0 {
+		t.Fatalf("Apply errors: %v", errs)
+	}
+	if got["station_code"] != "1001" || got["id"] != "55" {
+		t.Fatalf("Apply = %v, want station_code=1001 id=55", got)
+	}
+
+	//csv/spreadsheet จับคู่ header ด้วย Columns ไปแล้ว Convert ต้องไม่เปลี่ยนชื่อซ้ำ
+	keys, _, _ := NewHeaderResolver(p.Columns).ResolveHeaders([]string{"code", "station_code"})
+	if keys[0] != "station_code" || keys[1] != "id" {
+		t.Fatalf("resolved headers = %v, want [station_code id]", keys)
+	}
+	got, _ = p.Convert(map[string]interface{}{keys[0]: "1001", keys[1]: "55"})
+	if got["station_code"] != "1001" || got["id"] != "55" {
+		t.Fatalf("Convert = %v, want station_code=1001 id=55", got)
+	}
+}
+
+func TestMappingProfileConvert(t *testing.T) {
+	p := &MappingProfile{
+		Transforms: []MappingTransform{
+			{Field: "en_short", Op: "upper"},
+			{Field: "km", Op: "scale", Factor: 1000},
+			{Field: "name", Op: "split", Sep: "/", Index: 1},
+		},
+		Defaults: map[string]interface{}{"active": 1, "class": "3"},
+	}
+	item := map[string]interface{}{"en_short": "bkk", "km": "1.5", "name": "x", "class": ""}
+	got, errs := p.Convert(item)
+
+	if got["en_short"] != "BKK" || fmt.Sprint(got["km"]) != "1500" {
+		t.Errorf("transforms = %v", got)
+	}
+	if got["active"] != 1 || got["class"] != "3" {
+		t.Errorf("defaults = %v", got)
+	}
+	if len(errs) != 1 || errs[0].Field != "name" {
+		t.Errorf("errors = %v, want split error on name", errs)
+	}
+	if item["en_short"] != "bkk" {
+		t.Errorf("Convert modified its input: %v", item)
+	}
+}
@@ -3,11 +3,13 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 )
 
 // NormalizeInt แปลงค่าให้เป็น int
+// float ที่มีเศษหรือเกินช่วงของ int จะคืน error ไม่ปัดทิ้งเงียบๆ (เช่น 1001.5 จาก json)
 func NormalizeInt(v interface{}) (int, error) {
 	switch val := v.(type) {
 	case string:
@@ -17,9 +19,19 @@ func NormalizeInt(v interface{}) (int, error) {
 		}
 		return strconv.Atoi(clean)
 	case float64:
+		if val != math.Trunc(val) {
+			return 0, errors.New("not a whole number")
+		}
+		if val < math.MinInt64 || val >= math.MaxInt64 {
+			return 0, errors.New("out of int range")
+		}
 		return int(val), nil
 	case int:
 		return val, nil
+	case int32:
+		return int(val), nil
+	case int64:
+		return int(val), nil
 	default:
 		return 0, errors.New("cannot convert to int")
 	}
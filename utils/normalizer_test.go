@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizeInt(t *testing.T) {
+	tests := []struct {
+		in      interface{}
+		want    int
+		wantErr bool
+	}{
+		{"1001", 1001, false},
+		{" 1,001 ", 1001, false},
+		{"", 0, false},
+		{"10O2", 0, true},
+		{"1001.5", 0, true},
+		{1001.0, 1001, false},
+		{-3.0, -3, false},
+		{1001.5, 0, true},
+		{0.1, 0, true},
+		{1e300, 0, true},
+		{-1e300, 0, true},
+		{math.NaN(), 0, true},
+		{math.Inf(1), 0, true},
+		{42, 42, false},
+		{int32(7), 7, false},
+		{int64(8), 8, false},
+		{true, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeInt(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeInt(%#v) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeInt(%#v) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeStationFieldsRejectsFractionalInt(t *testing.T) {
+	_, errs := NormalizeStationFields(map[string]interface{}{"station_code": 1001.5, "name": "กรุงเทพ"})
+	if len(errs) != 1 || errs[0].Field != "station_code" || errs[0].Reason != "must be int" {
+		t.Errorf("errors = %+v, want station_code must be int", errs)
+	}
+}
@@ -0,0 +1,406 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ตารางพยัญชนะตามหลักการถอดอักษรไทยเป็นอักษรโรมันแบบถ่ายเสียง (RTGS) ของราชบัณฑิตยสถาน
+// อ ต้นพยางค์ไม่มีเสียง พยัญชนะที่ไม่มีใน rtgsFinal ใช้เป็นตัวสะกดไม่ได้
+var rtgsInitial = map[rune]string{
+	'ก': "k", 'ข': "kh", 'ฃ': "kh", 'ค': "kh", 'ฅ': "kh", 'ฆ': "kh", 'ง': "ng",
+	'จ': "ch", 'ฉ': "ch", 'ช': "ch", 'ซ': "s", 'ฌ': "ch", 'ญ': "y",
+	'ฎ': "d", 'ฏ': "t", 'ฐ': "th", 'ฑ': "th", 'ฒ': "th", 'ณ': "n",
+	'ด': "d", 'ต': "t", 'ถ': "th", 'ท': "th", 'ธ': "th", 'น': "n",
+	'บ': "b", 'ป': "p", 'ผ': "ph", 'ฝ': "f", 'พ': "ph", 'ฟ': "f", 'ภ': "ph", 'ม': "m",
+	'ย': "y", 'ร': "r", 'ล': "l", 'ว': "w", 'ศ': "s", 'ษ': "s", 'ส': "s",
+	'ห': "h", 'ฬ': "l", 'อ': "", 'ฮ': "h",
+}
+
+var rtgsFinal = map[rune]string{
+	'ก': "k", 'ข': "k", 'ฃ': "k", 'ค': "k", 'ฅ': "k", 'ฆ': "k", 'ง': "ng",
+	'จ': "t", 'ช': "t", 'ซ': "t", 'ฌ': "t", 'ฎ': "t", 'ฏ': "t", 'ฐ': "t", 'ฑ': "t", 'ฒ': "t",
+	'ด': "t", 'ต': "t", 'ถ': "t", 'ท': "t", 'ธ': "t", 'ศ': "t", 'ษ': "t", 'ส': "t",
+	'บ': "p", 'ป': "p", 'พ': "p", 'ฟ': "p", 'ภ': "p",
+	'ญ': "n", 'ณ': "n", 'น': "n", 'ร': "n", 'ล': "n", 'ฬ': "n",
+	'ม': "m", 'ย': "i", 'ว': "o",
+}
+
+// RomanizeThai ถอดข้อความภาษาไทยเป็นอักษรโรมันตามหลัก RTGS เช่น "หัวลำโพง" ได้ "Hualamphong"
+// แยกพยางค์ด้วยกฎจากรูปเขียนโดยไม่ใช้พจนานุกรม คำที่อ่านไม่ตรงรูป (สระลดรูป อักษรนำบางแบบ) อาจได้ผลต่างจากชื่อทางการ
+// ภาษาไทยที่เขียนติดกันได้เป็นคำเดียวขึ้นต้นด้วยตัวพิมพ์ใหญ่ ข้อความที่ไม่ใช่ภาษาไทยคงไว้ตามเดิม
+func RomanizeThai(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		if !isThai(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isThai(runes[j]) {
+			j++
+		}
+		word := romanizeThaiRun(runes[i:j])
+		//ขึ้นต้นคำด้วยตัวพิมพ์ใหญ่ ถ้าไม่ได้เขียนต่อจากตัวอักษรอื่น
+		if word != "" && (i == 0 || !unicode.IsLetter(runes[i-1])) {
+			word = strings.ToUpper(word[:1]) + word[1:]
+		}
+		b.WriteString(word)
+		i = j
+	}
+	return b.String()
+}
+
+// romanizeThaiRun ถอดภาษาไทยที่เขียนติดกันทีละพยางค์
+func romanizeThaiRun(run []rune) string {
+	r := prepareThai(run)
+	var b strings.Builder
+	last := ""
+	for i := 0; i < len(r); {
+		switch c := r[i]; {
+		case c >= '๐' && c <= '๙':
+			last = string('0' + (c - '๐'))
+			i++
+		case c == 'ๆ':
+			//ไม้ยมก ซ้ำพยางค์ก่อนหน้า
+			i++
+		case c == 'ฤ':
+			last = "rue"
+			i++
+		case isThaiConsonant(c) || isThaiLeadingVowel(c):
+			var n int
+			last, n = thaiSyllable(r[i:])
+			i += n
+		default:
+			//สระหรือเครื่องหมายที่ไม่มีพยัญชนะนำ ข้ามไป
+			last = ""
+			i++
+		}
+		b.WriteString(last)
+	}
+	return b.String()
+}
+
+// prepareThai ตัดวรรณยุกต์ (RTGS ไม่แสดงเสียงวรรณยุกต์) และตัวการันต์ออกก่อนแยกพยางค์
+func prepareThai(run []rune) []rune {
+	r := make([]rune, 0, len(run))
+	for _, c := range run {
+		switch {
+		case c >= '่' && c <= '๋', c == 'ๅ', c == 'ฯ':
+			continue
+		case c == '์':
+			//ตัดพยัญชนะที่มีไม้ทัณฑฆาต (รวมสระที่ติดอยู่ เช่น "ธิ์" ใน "สิทธิ์")
+			for len(r) > 0 && isThaiDependentVowel(r[len(r)-1]) {
+				r = r[:len(r)-1]
+			}
+			if len(r) > 0 {
+				r = r[:len(r)-1]
+			}
+			//พยัญชนะ 2 ตัวซ้อนหลังตัวสะกด เช่น "ทร์" ใน "จันทร์" ไม่ออกเสียงทั้งคู่
+			if n := len(r); n >= 2 && isThaiConsonant(r[n-1]) && isThaiConsonant(r[n-2]) {
+				r = r[:n-1]
+			}
+			continue
+		}
+		r = append(r, c)
+	}
+	return r
+}
+
+// thaiSyllable ถอดพยางค์แรกของ r คืนอักษรโรมันและจำนวนตัวที่ใช้ไป
+// r ต้องขึ้นต้นด้วยพยัญชนะหรือสระหน้า
+func thaiSyllable(r []rune) (string, int) {
+	at := func(i int) rune {
+		if i < len(r) {
+			return r[i]
+		}
+		return 0
+	}
+
+	i := 0
+	lead := rune(0)
+	if isThaiLeadingVowel(r[0]) {
+		lead = r[0]
+		i++
+		if !isThaiConsonant(at(i)) {
+			return "", i
+		}
+	}
+
+	//พยัญชนะต้น ห นำอักษรต่ำเดี่ยวและ อ นำ ย (อย่า อยู่ อยาก อย่าง) ไม่ออกเสียง
+	//ยกเว้น ห ว ที่ไม่มีสระตาม ว เป็นสระ -ัว ลดรูป เช่น ห้วย
+	first := r[i]
+	i++
+	next := at(i)
+	switch {
+	case first == 'ห' && strings.ContainsRune("งญนมยรล", next),
+		first == 'ห' && next == 'ว' && isThaiDependentVowel(at(i+1)):
+		first = next
+		i++
+	case first == 'อ' && next == 'ย' && (at(i+1) == 'า' || at(i+1) == 'ู'):
+		first = next
+		i++
+	}
+	onset := rtgsInitial[first]
+
+	//อักษรควบกล้ำ ทร ออกเสียง ซ และ ร หลัง จ ส ศ ซ ไม่ออกเสียง
+	if second := at(i); second != 0 && thaiCluster(first, second, at(i+1), at(i+2), lead) {
+		switch {
+		case first == 'ท' && second == 'ร':
+			onset = "s"
+		case second == 'ร' && strings.ContainsRune("จสศซ", first):
+		default:
+			onset += rtgsInitial[second]
+		}
+		i++
+	}
+
+	vowel, closed := "", false
+	p0, p1, p2 := at(i), at(i+1), at(i+2)
+	switch lead {
+	case 'เ':
+		switch {
+		case p0 == 'ี' && p1 == 'ย' && p2 == 'ว' && !isThaiDependentVowel(at(i+3)):
+			vowel, closed = "iao", true
+			i += 3
+		case p0 == 'ี' && p1 == 'ย':
+			vowel = "ia"
+			i += 2
+		case p0 == 'ื' && p1 == 'อ' && p2 == 'ย':
+			vowel, closed = "ueai", true
+			i += 3
+		case p0 == 'ื' && p1 == 'อ':
+			vowel = "uea"
+			i += 2
+		case p0 == 'า' && p1 == 'ะ':
+			vowel, closed = "o", true
+			i += 2
+		case p0 == 'า':
+			vowel, closed = "ao", true
+			i++
+		case p0 == 'ิ':
+			vowel = "oe"
+			i++
+		case p0 == '็':
+			vowel = "e"
+			i++
+		case p0 == 'ะ':
+			vowel, closed = "e", true
+			i++
+		case p0 == 'อ' && !isThaiDependentVowel(p1):
+			vowel, closed = "oe", true
+			i++
+		case p0 == 'ย' && !isThaiDependentVowel(p1):
+			vowel, closed = "oei", true
+			i++
+		case p0 == 'ว' && !isThaiDependentVowel(p1):
+			vowel, closed = "eo", true
+			i++
+		default:
+			vowel = "e"
+		}
+	case 'แ':
+		switch {
+		case p0 == 'ะ':
+			vowel, closed = "ae", true
+			i++
+		case p0 == '็':
+			vowel = "ae"
+			i++
+		case p0 == 'ว' && !isThaiDependentVowel(p1):
+			vowel, closed = "aeo", true
+			i++
+		default:
+			vowel = "ae"
+		}
+	case 'โ':
+		vowel = "o"
+		if p0 == 'ะ' {
+			closed = true
+			i++
+		}
+	case 'ใ', 'ไ':
+		vowel, closed = "ai", true
+		//ย หลัง ไ- ไม่ออกเสียง เช่น ไทย
+		if p0 == 'ย' && !isThaiDependentVowel(p1) {
+			i++
+		}
+	default:
+		switch {
+		case p0 == 'ั' && p1 == 'ว':
+			vowel = "ua"
+			i += 2
+		case p0 == 'ั' && p1 == 'ย' && !isThaiDependentVowel(p2):
+			vowel, closed = "ai", true
+			i += 2
+		case p0 == 'ั':
+			vowel = "a"
+			i++
+		case p0 == 'ะ':
+			vowel, closed = "a", true
+			i++
+		case p0 == 'ำ':
+			vowel, closed = "am", true
+			i++
+		case p0 == 'า' && p1 == 'ย' && !isThaiDependentVowel(p2):
+			vowel, closed = "ai", true
+			i += 2
+		case p0 == 'า' && p1 == 'ว' && !isThaiDependentVowel(p2):
+			vowel, closed = "ao", true
+			i += 2
+		case p0 == 'า':
+			vowel = "a"
+			i++
+		case p0 == 'ิ' && p1 == 'ว' && !isThaiDependentVowel(p2):
+			vowel, closed = "io", true
+			i += 2
+		case p0 == 'ิ', p0 == 'ี':
+			vowel = "i"
+			i++
+		case p0 == 'ึ':
+			vowel = "ue"
+			i++
+		case p0 == 'ื' && p1 == 'อ':
+			vowel = "ue"
+			i += 2
+		case p0 == 'ื':
+			vowel = "ue"
+			i++
+		case p0 == 'ุ' && p1 == 'ย' && !isThaiDependentVowel(p2):
+			vowel, closed = "ui", true
+			i += 2
+		case p0 == 'ุ', p0 == 'ู':
+			vowel = "u"
+			i++
+		case p0 == '็':
+			vowel, closed = "o", true
+			i++
+		case p0 == 'อ' && p1 == 'ย' && !isThaiDependentVowel(p2):
+			vowel, closed = "oi", true
+			i += 2
+		case p0 == 'อ' && !isThaiDependentVowel(p1):
+			vowel = "o"
+			i++
+		case p0 == 'ว' && p1 == 'ย' && !isThaiDependentVowel(p2):
+			//สระ -ัวะ ลดรูปกับ ย สะกด เช่น ช่วย
+			vowel, closed = "uai", true
+			i += 2
+		case p0 == 'ว' && isThaiConsonant(p1) && p1 != 'อ' && !isThaiDependentVowel(p2):
+			//สระ -ัว ลดรูป เช่น สวน
+			vowel = "ua"
+			i++
+		case p0 == 'ร' && p1 == 'ร':
+			//ร หัน เช่น ธรรม บรรทัด
+			i += 2
+			if _, ok := rtgsFinal[p2]; ok && isThaiConsonant(p2) && !isThaiDependentVowel(at(i+1)) {
+				vowel = "a"
+			} else {
+				vowel, closed = "an", true
+			}
+		default:
+			vowel, closed = thaiImplicitVowel(p0, p1, p2), false
+			if vowel == "a" {
+				closed = true
+			}
+		}
+	}
+
+	syllable := onset + vowel
+	if closed {
+		return syllable, i
+	}
+
+	//ตัวสะกด ถ้าพยัญชนะถัดไปมีสระตามหรือควบกล้ำกับตัวถัดไป แปลว่าเป็นต้นพยางค์ใหม่
+	f := at(i)
+	final, ok := rtgsFinal[f]
+	if !ok || !thaiFinal(f, at(i+1), at(i+2), at(i+3)) {
+		return syllable, i
+	}
+	i++
+	//ร ท้ายคำหลังตัวสะกดไม่ออกเสียง เช่น สมุทร มิตร
+	if at(i) == 'ร' && at(i+1) == 0 {
+		i++
+	}
+	//สระที่ลงท้ายด้วยเสียง i หรือ o อยู่แล้วไม่ต้องเติมซ้ำ
+	if strings.HasSuffix(syllable, final) && (final == "i" || final == "o") {
+		final = ""
+	}
+	return syllable + final, i
+}
+
+// thaiImplicitVowel สระที่ไม่ปรากฏรูปของพยางค์ที่ไม่มีสระ n คือพยัญชนะถัดไป x y คือ 2 ตัวหลังจากนั้น
+// คืน "a" ถ้าเป็นพยางค์เปิด (เช่น ส ใน สบาย ถ ใน ถนน) หรือ "o" ถ้า n เป็นตัวสะกด (เช่น คน สมชาย)
+func thaiImplicitVowel(n, x, y rune) string {
+	if _, ok := rtgsFinal[n]; !ok || !isThaiConsonant(n) {
+		return "a"
+	}
+	switch {
+	case x == 0, isThaiLeadingVowel(x):
+		return "o"
+	case isThaiDependentVowel(x), x == 'อ':
+		return "a"
+	case isThaiConsonant(x) && (y == 0 || isThaiLeadingVowel(y)):
+		return "a"
+	default:
+		return "o"
+	}
+}
+
+// thaiCluster เช็คว่า first second เป็นอักษรควบกล้ำ x y คือ 2 ตัวถัดจาก second lead คือสระหน้า (0 คือไม่มี)
+func thaiCluster(first, second, x, y, lead rune) bool {
+	switch second {
+	case 'ร':
+		if !strings.ContainsRune("กขคตปพทจสศซ", first) {
+			return false
+		}
+	case 'ล':
+		if !strings.ContainsRune("กขคปพผ", first) {
+			return false
+		}
+	case 'ว':
+		//ว ควบได้เฉพาะเมื่อมีสระตาม ไม่อย่างนั้นเป็นสระ -ัว ลดรูป เช่น ควร
+		return strings.ContainsRune("กขค", first) && (isThaiDependentVowel(x) || lead != 0 && x != 0)
+	default:
+		return false
+	}
+	switch {
+	case x == 0:
+		return false
+	case lead != 0:
+		//สระหน้าใช้กับ า ได้เฉพาะ เ-า เช่น โคราช ไม่ใช่ควบกล้ำ
+		return x != 'า' || lead == 'เ'
+	case isThaiDependentVowel(x), x == 'อ':
+		return true
+	default:
+		//ควบกล้ำแบบไม่มีรูปสระ เช่น กรม พรม ตัวถัดไปต้องเป็นตัวสะกด
+		return isThaiConsonant(x) && !isThaiDependentVowel(y)
+	}
+}
+
+// thaiFinal เช็คว่าพยัญชนะ f เป็นตัวสะกดของพยางค์ปัจจุบัน x y z คือ 3 ตัวถัดไป
+func thaiFinal(f, x, y, z rune) bool {
+	if !isThaiConsonant(f) {
+		return false
+	}
+	switch {
+	case x == 0, isThaiLeadingVowel(x), !isThai(x):
+		return true
+	case isThaiDependentVowel(x):
+		return false
+	case x == 'อ':
+		//f อ ตามด้วยสระ คือ อ เป็นต้นพยางค์ถัดไป เช่น บางอ้อ หรือ อ เป็นพยางค์ที่ไม่มีรูปสระ เช่น เทพอภิวัฒน์
+		return isThaiDependentVowel(y) || y == 'อ' || isThaiConsonant(y) && isThaiDependentVowel(z)
+	case thaiCluster(f, x, y, 0, 0) && (isThaiDependentVowel(y) || y == 'อ'):
+		//ควบกล้ำกับตัวถัดไป เช่น ก ใน สถานีกลาง
+		return false
+	default:
+		return true
+	}
+}
+
+// isThaiDependentVowel สระหลัง สระบน/ล่าง และไม้ไต่คู้ ที่ต้องมีพยัญชนะนำ
+func isThaiDependentVowel(r rune) bool {
+	return r >= 0x0E30 && r <= 0x0E3A || r == 0x0E47
+}
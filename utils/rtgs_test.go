@@ -0,0 +1,30 @@
+package utils
+
+import "testing"
+
+func TestRomanizeThai(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"หัวลำโพง", "Hualamphong"},
+		{"กรุงเทพ", "Krungthep"},
+		{"บางซื่อ", "Bangsue"},
+		{"ดอนเมือง", "Donmueang"},
+		{"กรุงเทพอภิวัฒน์", "Krungthepaphiwat"},
+		{"ห้วยขวาง", "Huaikhwang"},
+		{"ถนน", "Thanon"},
+		{"สมชาย", "Somchai"},
+		{"จันทร์", "Chan"},
+		{"เชียงใหม่", "Chiangmai"},
+		//คำภาษาไทยที่มีช่องว่างหรือตัวอักษรอื่นปน ถอดแยกแต่ละช่วง
+		{"สถานี บางซื่อ", "Sathani Bangsue"},
+		{"ชุมทาง BKK", "Chumthang BKK"},
+		{"", ""},
+		{"Bang Sue", "Bang Sue"},
+	}
+	for _, tt := range tests {
+		if got := RomanizeThai(tt.in); got != tt.want {
+			t.Errorf("RomanizeThai(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
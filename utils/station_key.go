@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+)
+
+// keyFieldValues field ที่ใช้เป็น key ได้ ต้องเป็น field ตัวเลขที่ระบุตัวสถานีได้
+var keyFieldValues = map[string]func(st models.Station) int{
+	"station_code": func(st models.Station) int { return st.StationCode },
+	"id":           func(st models.Station) int { return st.StationID },
+}
+
+// StationKey กฎการระบุตัวตนของสถานีตอน import
+// เป็น field เดียว (station_code หรือ id) หรือหลาย field รวมกัน (composite)
+type StationKey struct {
+	Fields []string
+}
+
+// ParseStationKey แปลงค่า config เช่น "station_code", "id" หรือ "station_code,id" เป็น StationKey
+func ParseStationKey(s string) (StationKey, error) {
+	var key StationKey
+	seen := make(map[string]bool)
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '+' }) {
+		f = strings.TrimSpace(f)
+		if _, ok := keyFieldValues[f]; !ok {
+			return key, fmt.Errorf("invalid import key field %q (use station_code, id or both)", f)
+		}
+		if !seen[f] {
+			seen[f] = true
+			key.Fields = append(key.Fields, f)
+		}
+	}
+	if len(key.Fields) == 0 {
+		return key, fmt.Errorf("import key is empty")
+	}
+	return key, nil
+}
+
+// Filter สร้าง filter สำหรับหา document เดิมของสถานีนี้
+// คืน field ที่ไม่มีค่า (เป็น 0) กลับมาด้วย ถ้ามีแสดงว่าแถวนี้ใช้เป็น key ไม่ได้
+func (k StationKey) Filter(st models.Station) (filter map[string]interface{}, missing []string) {
+	filter = make(map[string]interface{}, len(k.Fields))
+	for _, f := range k.Fields {
+		v := keyFieldValues[f](st)
+		if v == 0 {
+			missing = append(missing, f)
+		}
+		filter[f] = v
+	}
+	return filter, missing
+}
+
+// Value คืนค่า key เป็น string ใช้ตรวจ key ซ้ำภายในไฟล์เดียวกัน
+func (k StationKey) Value(st models.Station) string {
+	parts := make([]string, 0, len(k.Fields))
+	for _, f := range k.Fields {
+		parts = append(parts, fmt.Sprintf("%d", keyFieldValues[f](st)))
+	}
+	return strings.Join(parts, "|")
+}
+
+// DocValue คืนค่า key ของ document ที่อ่านมาจาก repository ในรูปแบบเดียวกับ Value
+// ใช้จับคู่ document ที่ prefetch มากับแถวในไฟล์
+func (k StationKey) DocValue(doc map[string]interface{}) string {
+	parts := make([]string, 0, len(k.Fields))
+	for _, f := range k.Fields {
+		n, _ := NormalizeInt(doc[f])
+		parts = append(parts, fmt.Sprintf("%d", n))
+	}
+	return strings.Join(parts, "|")
+}
+
+func (k StationKey) String() string {
+	return strings.Join(k.Fields, ",")
+}
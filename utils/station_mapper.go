@@ -4,68 +4,32 @@ import (
 	"fmt"
 	"math"
 
+	"github.com/Teneieiza/go-spinsolf-test/dto"
 	"github.com/Teneieiza/go-spinsolf-test/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // MapToStation แปลง map[string]interface{} เป็น Station model
 // โดยใช้ StationFieldTypes ในการ normalize ค่า
+// ค่าที่แปลงไม่ได้จะกลายเป็นค่าว่าง ถ้าต้องการรู้ว่า field ไหนผิดให้ใช้ ValidateStation
 func MapToStation(item map[string]interface{}) models.Station {
+	st, _ := ValidateStation(item)
+	return st
+}
+
+// ValidateStation แปลง map[string]interface{} เป็น Station model พร้อมคืน error ของแต่ละ field
+// field ที่ผิดจะถูกแทนด้วยค่าว่าง (เหมือนพฤติกรรมเดิมของ MapToStation)
+// ถ้าพิกัดผิดจะถูกตั้งเป็น 0 และใส่ [corrupted] ไว้ใน comment
+func ValidateStation(item map[string]interface{}) (models.Station, []FieldError) {
 	st := models.Station{ID: primitive.NewObjectID()}
 
-	corrupted := false // flag ว่าข้อมูลนี้ถูกแก้
+	fields, errs := NormalizeStationFields(item)
+	ApplyStationFields(&st, fields)
 
-	for field, targetType := range models.FieldTypes {
-		if val, ok := item[field]; ok {
-			normVal, _ := NormalizeValue(val, targetType)
-			switch field {
-			case "id":
-				st.StationID = normVal.(int)
-			case "station_code":
-				st.StationCode = normVal.(int)
-			case "name":
-				st.Name = normVal.(string)
-			case "en_name":
-				st.EnName = normVal.(string)
-			case "th_short":
-				st.ThShort = normVal.(string)
-			case "en_short":
-				st.EnShort = normVal.(string)
-			case "chname":
-				st.ChName = normVal.(string)
-			case "controldivision":
-				st.ControlDiv = normVal.(int)
-			case "exact_km":
-				st.ExactKM = normVal.(int)
-			case "exact_distance":
-				st.ExactDistance = normVal.(int)
-			case "km":
-				st.KM = normVal.(int)
-			case "class":
-				st.Class = normVal.(int)
-			case "lat":
-				lat := normVal.(float64)
-				if lat < -90 || lat > 90 {
-					corrupted = true
-					lat = 0
-				}
-				st.Lat = lat
-			case "long":
-				long := normVal.(float64)
-				if long < -180 || long > 180 {
-					corrupted = true
-					long = 0
-				}
-				st.Long = long
-			case "active":
-				st.Active = normVal.(int)
-			case "giveway":
-				st.Giveway = normVal.(int)
-			case "dual_track":
-				st.DualTrack = normVal.(int)
-			case "comment":
-				st.Comment = normVal.(string)
-			}
+	corrupted := false // flag ว่าข้อมูลนี้ถูกแก้
+	for _, e := range errs {
+		if e.Field == "lat" || e.Field == "long" {
+			corrupted = true
 		}
 	}
 
@@ -75,16 +39,9 @@ func MapToStation(item map[string]interface{}) models.Station {
 		} else {
 			st.Comment = "[corrupted]"
 		}
-		fmt.Printf("Warning: station_id=%d has corrupted coordinates, set to [0,0]\n", st.StationID)
 	}
 
-	// สร้าง field location สำหรับ GeoJSON
-	st.Location = map[string]interface{}{
-		"type":        "Point",
-		"coordinates": []float64{st.Long, st.Lat},
-	}
-
-	return st
+	return st, errs
 }
 
 
@@ -119,18 +76,37 @@ func StationToBsonMap(st models.Station, existing map[string]interface{}) map[st
 	setIfChanged("dual_track", st.DualTrack)
 	setIfChanged("comment", st.Comment)
 	setIfChanged("location", st.Location)
+	setIfChanged("en_name_generated", st.EnNameGenerated)
+	setIfChanged("fuzzy_name", st.FuzzyName)
+	setIfChanged("fuzzy_en_name", st.FuzzyEnName)
+	setIfChanged("fuzzy_roman", st.FuzzyRoman)
 
 	return update
 }
 
+// StationChanges เทียบ station กับ document เดิมแบบเดียวกับ StationToBsonMap
+// แต่คืนทั้งค่าเก่าและค่าใหม่ของ field ที่เปลี่ยน ใช้ทำรายงานตอน dry run
+func StationChanges(st models.Station, existing map[string]interface{}) map[string]dto.FieldChange {
+	changes := make(map[string]dto.FieldChange)
+	for key, newVal := range StationToBsonMap(st, existing) {
+		changes[key] = dto.FieldChange{Old: existing[key], New: newVal}
+	}
+	return changes
+}
+
+// ValuesEqual เทียบค่าของ field แบบเดียวกับที่ใช้หา field ที่เปลี่ยนตอน import
+func ValuesEqual(a, b interface{}) bool {
+	return isEqual(a, b)
+}
+
 // ฟังก์ชันช่วยเปรียบเทียบค่าแบบ generic
+// ตัวเลขต่างชนิดกันถือว่าเท่ากันถ้าค่าเท่ากัน เพราะค่าที่อ่านจาก mongo เป็น int32 แต่ค่าใหม่เป็น int
 func isEqual(a, b interface{}) bool {
-	switch va := a.(type) {
-	case float64:
-		vb, ok := b.(float64)
-		return ok && math.Abs(va-vb) < 1e-9
-	case int, int32, int64:
-		return a == b
+	if fa, ok := toFloat64(a); ok {
+		fb, ok := toFloat64(b)
+		return ok && math.Abs(fa-fb) < 1e-9
+	}
+	switch a.(type) {
 	case string:
 		return a == b
 	case nil:
@@ -140,5 +116,18 @@ func isEqual(a, b interface{}) bool {
 	}
 }
 
-
-
+// toFloat64 แปลงตัวเลขทุกชนิดเป็น float64 เพื่อใช้เปรียบเทียบ
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
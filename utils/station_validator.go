@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Teneieiza/go-spinsolf-test/models"
+)
+
+// FieldError บอกว่า field ไหนผิด ค่าที่ส่งมาคืออะไร และผิดเพราะอะไร
+type FieldError struct {
+	Field  string      `json:"field"`
+	Value  interface{} `json:"value"`
+	Reason string      `json:"reason"`
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// JoinFieldErrors รวม error ของแต่ละ field เป็นข้อความเดียว
+func JoinFieldErrors(errs []FieldError) string {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidLat เช็คช่วงของ latitude (-90 ถึง 90)
+func ValidLat(lat float64) bool {
+	return lat >= -90 && lat <= 90
+}
+
+// ValidLong เช็คช่วงของ longitude (-180 ถึง 180)
+func ValidLong(long float64) bool {
+	return long >= -180 && long <= 180
+}
+
+// NormalizeStationFields แปลงค่าใน item ตาม models.FieldTypes แบบเดียวกับ MapToStation
+// แต่ไม่กลืน error คืนค่าที่แปลงแล้วพร้อม error ของแต่ละ field
+// field ที่ไม่อยู่ใน FieldTypes จะถูกข้ามไป
+func NormalizeStationFields(item map[string]interface{}) (map[string]interface{}, []FieldError) {
+	fields := make(map[string]interface{})
+	var errs []FieldError
+
+	//เรียง key ก่อนเพื่อให้ลำดับของ error คงที่
+	keys := make([]string, 0, len(item))
+	for k := range item {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, field := range keys {
+		targetType, ok := models.FieldTypes[field]
+		if !ok {
+			continue
+		}
+		val := item[field]
+		normVal, err := NormalizeValue(val, targetType)
+		if err != nil {
+			errs = append(errs, FieldError{Field: field, Value: val, Reason: fmt.Sprintf("must be %s", targetType)})
+			continue
+		}
+		switch field {
+		case "lat":
+			if !ValidLat(normVal.(float64)) {
+				errs = append(errs, FieldError{Field: field, Value: val, Reason: "must be between -90 and 90"})
+				continue
+			}
+		case "long":
+			if !ValidLong(normVal.(float64)) {
+				errs = append(errs, FieldError{Field: field, Value: val, Reason: "must be between -180 and 180"})
+				continue
+			}
+		}
+		fields[field] = normVal
+	}
+
+	return fields, errs
+}
+
+// ApplyStationFields เอาค่าที่ผ่าน NormalizeStationFields แล้วมาใส่ใน Station
+// และสร้าง location ใหม่ให้ตรงกับ lat long และค่าที่ใช้ค้นหา (ApplySearchKeys) ให้ตรงกับชื่อเสมอ
+func ApplyStationFields(st *models.Station, fields map[string]interface{}) {
+	for field, val := range fields {
+		switch field {
+		case "id":
+			st.StationID = val.(int)
+		case "station_code":
+			st.StationCode = val.(int)
+		case "name":
+			st.Name = val.(string)
+		case "en_name":
+			st.EnName = val.(string)
+		case "th_short":
+			st.ThShort = val.(string)
+		case "en_short":
+			st.EnShort = val.(string)
+		case "chname":
+			st.ChName = val.(string)
+		case "controldivision":
+			st.ControlDiv = val.(int)
+		case "exact_km":
+			st.ExactKM = val.(int)
+		case "exact_distance":
+			st.ExactDistance = val.(int)
+		case "km":
+			st.KM = val.(int)
+		case "class":
+			st.Class = val.(int)
+		case "lat":
+			st.Lat = val.(float64)
+		case "long":
+			st.Long = val.(float64)
+		case "active":
+			st.Active = val.(int)
+		case "giveway":
+			st.Giveway = val.(int)
+		case "dual_track":
+			st.DualTrack = val.(int)
+		case "comment":
+			st.Comment = val.(string)
+		}
+	}
+
+	st.Location = StationLocation(st.Lat, st.Long)
+	ApplySearchKeys(st)
+}
+
+// StationLocation สร้าง field location สำหรับ GeoJSON (coordinates เป็น [long, lat])
+func StationLocation(lat, long float64) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "Point",
+		"coordinates": []float64{long, lat},
+	}
+}
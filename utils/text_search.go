@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SearchTerms แยกคำค้นเป็น term ตัวพิมพ์เล็ก ตัดด้วยช่องว่างและเครื่องหมาย
+// สระและวรรณยุกต์ภาษาไทยนับเป็นตัวอักษร ภาษาไทยที่เขียนติดกันจะเป็น term เดียว
+func SearchTerms(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), isSearchSeparator)
+}
+
+// NormalizeSearchText ทำให้ข้อความเทียบกันได้ เป็นตัวพิมพ์เล็กและคั่นแต่ละคำด้วยช่องว่าง 1 ตัว
+// เช่น "Bang-Sue  Junction" ได้ "bang sue junction"
+func NormalizeSearchText(s string) string {
+	return strings.Join(SearchTerms(s), " ")
+}
+
+// SearchTokens แยกข้อความเป็น token สำหรับจับคู่แบบ prefix
+// words คือคำที่คั่นด้วยช่องว่างหรือเครื่องหมาย
+// inner คือส่วนท้ายของคำที่เริ่มกลางคำ เฉพาะภาษาที่ไม่เว้นวรรค (ไทย จีน)
+// ภาษาไทยเริ่มได้เฉพาะตำแหน่งที่ขึ้นพยางค์ได้ (พยัญชนะหรือสระหน้า) ไม่เริ่มที่สระหลัง สระบน/ล่าง หรือวรรณยุกต์
+// เช่น "กรุงเทพ" ได้ inner เป็น "รุงเทพ", "งเทพ", "เทพ", "พ" จึงค้น "เทพ" เจอ แต่ค้น "ุงเทพ" ไม่เจอ
+func SearchTokens(s string) (words, inner []string) {
+	words = SearchTerms(s)
+	for _, w := range words {
+		runes := []rune(w)
+		for i := 1; i < len(runes); i++ {
+			if clusterStart(runes, i) {
+				inner = append(inner, string(runes[i:]))
+			}
+		}
+	}
+	return words, inner
+}
+
+// clusterStart เช็คว่าตำแหน่ง i ในคำเริ่มพยางค์ใหม่ได้หรือไม่ (ใช้กับภาษาที่ไม่เว้นวรรค)
+func clusterStart(runes []rune, i int) bool {
+	r, prev := runes[i], runes[i-1]
+	switch {
+	case unicode.Is(unicode.Han, r):
+		return true
+	case isThaiLeadingVowel(r):
+		return true
+	case isThaiConsonant(r):
+		//พยัญชนะที่ตามหลังสระหน้าอยู่ในพยางค์เดียวกับสระนั้น
+		//และต้องอยู่ในคำภาษาไทย ไม่ตัดคำภาษาอังกฤษที่มีตัวไทยปน
+		return isThai(prev) && !isThaiLeadingVowel(prev)
+	default:
+		return false
+	}
+}
+
+// isSearchSeparator ตัวอักษรที่ใช้คั่นคำ (ไม่ใช่ตัวอักษร ตัวเลข หรือสระ/วรรณยุกต์)
+func isSearchSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+}
+
+func isThai(r rune) bool {
+	return r >= 0x0E01 && r <= 0x0E5B
+}
+
+// isThaiConsonant พยัญชนะไทย ก-ฮ
+func isThaiConsonant(r rune) bool {
+	return r >= 0x0E01 && r <= 0x0E2E
+}
+
+// isThaiLeadingVowel สระที่เขียนหน้าพยัญชนะ เ แ โ ใ ไ
+func isThaiLeadingVowel(r rune) bool {
+	return r >= 0x0E40 && r <= 0x0E44
+}
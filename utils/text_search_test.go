@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"Bang-Sue  Junction", []string{"bang", "sue", "junction"}},
+		{"  กรุงเทพ (หัวลำโพง) ", []string{"กรุงเทพ", "หัวลำโพง"}},
+		{"BKK/01", []string{"bkk", "01"}},
+		{"...", []string{}},
+	}
+	for _, tt := range tests {
+		got := SearchTerms(tt.in)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SearchTerms(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSearchTokens(t *testing.T) {
+	tests := []struct {
+		in           string
+		words, inner []string
+	}{
+		{"Bang Sue", []string{"bang", "sue"}, nil},
+		{"กรุงเทพ", []string{"กรุงเทพ"}, []string{"รุงเทพ", "งเทพ", "เทพ", "พ"}},
+		{"เชียงใหม่", []string{"เชียงใหม่"}, []string{"ยงใหม่", "งใหม่", "ใหม่", "ม่"}},
+		{"北京南", []string{"北京南"}, []string{"京南", "南"}},
+	}
+	for _, tt := range tests {
+		words, inner := SearchTokens(tt.in)
+		if !reflect.DeepEqual(words, tt.words) || !reflect.DeepEqual(inner, tt.inner) {
+			t.Errorf("SearchTokens(%q) = %q, %q, want %q, %q", tt.in, words, inner, tt.words, tt.inner)
+		}
+	}
+}
+
+func TestClusterStart(t *testing.T) {
+	tests := []struct {
+		word string
+		i    int
+		want bool
+	}{
+		{"กรุงเทพ", 1, true},   // ร ตามหลังพยัญชนะ
+		{"กรุงเทพ", 2, false},  // สระล่าง ุ
+		{"กรุงเทพ", 4, true},   // สระหน้า เ
+		{"กรุงเทพ", 5, false},  // ท ตามหลังสระหน้า อยู่พยางค์เดียวกับ เ
+		{"หัวลำโพง", 1, false}, // ไม้หันอากาศ
+		{"หัวลำโพง", 3, true},  // ล ตามหลังพยัญชนะ
+		{"หัวลำโพง", 4, false}, // สระอำ
+		{"bkkก", 3, false},     // พยัญชนะไทยที่ตามหลังอักษรอังกฤษ
+		{"北京", 1, true},
+		{"bangkok", 3, false},
+	}
+	for _, tt := range tests {
+		if got := clusterStart([]rune(tt.word), tt.i); got != tt.want {
+			t.Errorf("clusterStart(%q, %d) = %v, want %v", tt.word, tt.i, got, tt.want)
+		}
+	}
+}